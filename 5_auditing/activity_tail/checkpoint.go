@@ -0,0 +1,96 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpoint records how far the feed has been read. Activities can become
+// visible some time after they completed, so each poll looks back from Cursor
+// by a lag, and Seen holds the IDs already emitted within that window so they
+// aren't emitted twice.
+type checkpoint struct {
+	Cursor time.Time            `json:"cursor"`
+	Seen   map[string]time.Time `json:"seen"`
+}
+
+// loadCheckpoint reads a checkpoint file. A missing file is not an error; it
+// returns nil so the caller can pick a starting point.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	if cp.Seen == nil {
+		cp.Seen = map[string]time.Time{}
+	}
+	return &cp, nil
+}
+
+// Mark records an emitted activity and moves the cursor up to it.
+func (cp *checkpoint) Mark(id string, completedAt time.Time) {
+	cp.Seen[id] = completedAt
+	if completedAt.After(cp.Cursor) {
+		cp.Cursor = completedAt
+	}
+}
+
+// Prune forgets IDs that completed before the look-back window, since
+// polls no longer return them.
+func (cp *checkpoint) Prune(lag time.Duration) {
+	horizon := cp.Cursor.Add(-lag)
+	for id, completedAt := range cp.Seen {
+		if completedAt.Before(horizon) {
+			delete(cp.Seen, id)
+		}
+	}
+}
+
+// Save writes the checkpoint to a temporary file and renames it into place,
+// so a crash mid-write leaves the previous checkpoint intact.
+func (cp *checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/siem"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Follows the organization's activity feed, writing each new activity as a
+// JSON line, or as a CEF, LEEF or OCSF event for a SIEM. The position in the
+// feed is saved to a checkpoint file after every poll, so the tailer can be
+// stopped with Ctrl-C or SIGTERM and restarted without losing or repeating
+// activities:
+//
+//	activity_tail -checkpoint activities.checkpoint -out activities.jsonl
+//	activity_tail -format cef -out unix:///dev/log
+func main() {
+	log.SetFlags(0)
+	checkpointPath := flag.String("checkpoint", "activity_tail.checkpoint", "file recording the position in the feed")
+	outPath := flag.String("out", "-", "file to append activities to, - for stdout, or a syslog server such as udp://host:514 or unix:///dev/log")
+	format := flag.String("format", "json", "output format: json, or cef, leef or ocsf for SIEMs")
+	interval := flag.Duration("interval", 10*time.Second, "time between polls")
+	lag := flag.Duration("lag", 5*time.Minute, "how far back each poll looks for activities that became visible late")
+	from := flag.Duration("from", 0, "without a checkpoint, start this long ago instead of now")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
+	cp, err := loadCheckpoint(*checkpointPath)
+	if err != nil {
+		log.Fatalf("failed to read checkpoint: %v", err)
+	}
+	if cp == nil {
+		cp = &checkpoint{Cursor: time.Now().Add(-*from), Seen: map[string]time.Time{}}
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	out, err := siem.OpenOutput(*outPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE)
+	if err != nil {
+		log.Fatalf("failed to open output: %v", err)
+	}
+	defer out.Close()
+	write, err := newActivityWriter(out, *format, redactor)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Tailing activities from %v", cp.Cursor.Format(time.RFC3339))
+	for {
+		n, err := poll(ctx, client, cp, *lag, write)
+		if err != nil && ctx.Err() == nil {
+			// The API may be briefly unavailable; try again on the next poll.
+			log.Printf("failed to poll activities: %v", err)
+		}
+		if n > 0 {
+			// Activities are written before the checkpoint that covers them,
+			// so a crash in between repeats them rather than losing them.
+			if f, ok := out.(*os.File); ok {
+				if err := f.Sync(); err != nil {
+					log.Fatalf("failed to write output: %v", err)
+				}
+			}
+			cp.Prune(*lag)
+			if err := cp.Save(*checkpointPath); err != nil {
+				log.Fatalf("failed to save checkpoint: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopped at %v", cp.Cursor.Format(time.RFC3339))
+			return
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// poll writes the activities completed since the checkpoint that haven't
+// been written yet, oldest first, and returns how many it wrote.
+func poll(ctx context.Context, client *sdm.Client, cp *checkpoint, lag time.Duration, write func(*sdm.Activity) error) (int, error) {
+	activities, err := client.Activities().List(ctx, "after:?", cp.Cursor.Add(-lag))
+	if err != nil {
+		return 0, err
+	}
+	var batch []*sdm.Activity
+	for activities.Next() {
+		activity := activities.Value()
+		if _, seen := cp.Seen[activity.ID]; !seen {
+			batch = append(batch, activity)
+		}
+	}
+	if err := activities.Err(); err != nil {
+		// Write nothing from an incomplete page; the next poll will see it all.
+		return 0, err
+	}
+
+	sort.SliceStable(batch, func(i, j int) bool { return batch[i].CompletedAt.Before(batch[j].CompletedAt) })
+	for i, activity := range batch {
+		if err := write(activity); err != nil {
+			return i, fmt.Errorf("failed to write activity %v: %w", activity.ID, err)
+		}
+		cp.Mark(activity.ID, activity.CompletedAt)
+	}
+	return len(batch), nil
+}
+
+// newActivityWriter returns a function that writes an activity to w as a
+// line in the given format, with secrets in its description redacted.
+func newActivityWriter(w io.Writer, format string, redactor *redact.Redactor) (func(*sdm.Activity) error, error) {
+	var write func(*sdm.Activity) error
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		write = func(a *sdm.Activity) error {
+			return enc.Encode(newActivityRecord(a))
+		}
+	} else {
+		siemFormat, err := siem.ParseFormat(format)
+		if err != nil {
+			return nil, fmt.Errorf("unknown format %q, expected json, cef, leef or ocsf", format)
+		}
+		write = func(a *sdm.Activity) error {
+			line, err := siemFormat.Format(siem.ActivityEvent(a))
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, line+"\n")
+			return err
+		}
+	}
+	return func(a *sdm.Activity) error {
+		redactions := redactor.Session()
+		redacted := *a
+		redacted.Description = redactions.Text(a.Description)
+		if redactions.Total() > 0 {
+			log.Printf("Activity %v: %v", a.ID, redactions)
+		}
+		return write(&redacted)
+	}, nil
+}
+
+// activityRecord is the JSON written for each activity.
+type activityRecord struct {
+	ID          string         `json:"id"`
+	CompletedAt time.Time      `json:"completedAt"`
+	Verb        string         `json:"verb"`
+	Description string         `json:"description"`
+	Actor       *activityActor `json:"actor,omitempty"`
+	Entities    []activityItem `json:"entities"`
+	IPAddress   string         `json:"ipAddress,omitempty"`
+	UserAgent   string         `json:"userAgent,omitempty"`
+}
+
+type activityActor struct {
+	ID    string `json:"id"`
+	Email string `json:"email,omitempty"`
+}
+
+type activityItem struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+func newActivityRecord(a *sdm.Activity) activityRecord {
+	rec := activityRecord{
+		ID:          a.ID,
+		CompletedAt: a.CompletedAt,
+		Verb:        a.Verb,
+		Description: a.Description,
+		Entities:    []activityItem{},
+		IPAddress:   a.IPAddress,
+		UserAgent:   a.UserAgent,
+	}
+	if a.Actor != nil {
+		rec.Actor = &activityActor{ID: a.Actor.ID, Email: a.Actor.Email}
+	}
+	for _, e := range a.Entities {
+		rec.Entities = append(rec.Entities, activityItem{ID: e.ID, Type: e.Type, Name: e.Name, Email: e.Email})
+	}
+	return rec
+}
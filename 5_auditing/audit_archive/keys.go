@@ -0,0 +1,113 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// keygenCommand creates the Ed25519 key pair manifests are signed with. The
+// private key stays with the archiver; the public key is given to whoever
+// verifies the archive. Existing keys are never overwritten.
+//
+//	audit_archive keygen -key archive.key -public-key archive.pub
+func keygenCommand(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	keyPath := flags.String("key", "archive.key", "file to write the PEM private key to")
+	publicKeyPath := flags.String("public-key", "archive.pub", "file to write the PEM public key to")
+	flags.Parse(args)
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("failed to generate key: %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		log.Fatalf("failed to encode private key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		log.Fatalf("failed to encode public key: %v", err)
+	}
+	if err := writePEM(*keyPath, "PRIVATE KEY", privateDER, 0o600); err != nil {
+		log.Fatalf("failed to write private key: %v", err)
+	}
+	if err := writePEM(*publicKeyPath, "PUBLIC KEY", publicDER, 0o644); err != nil {
+		log.Fatalf("failed to write public key: %v", err)
+	}
+	fmt.Printf("Wrote %v and %v\n", *keyPath, *publicKeyPath)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%v has no %v block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+func loadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%v is not an Ed25519 key", path)
+	}
+	return privateKey, nil
+}
+
+func loadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%v is not an Ed25519 key", path)
+	}
+	return publicKey, nil
+}
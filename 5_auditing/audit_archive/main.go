@@ -0,0 +1,179 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Archives queries, their replays and activities into a local directory in
+// a form that shows whether anything was changed later. Each record is kept
+// as a JSON object named by its hash, the records are linked in a hash
+// chain, and each day's part of the chain is summarized in a manifest
+// signed with an Ed25519 key. Records are kept exactly as the API returns
+// them, so encrypted queries stay encrypted and nothing is redacted.
+//
+// Run it regularly, for example daily from cron, with a time range that
+// overlaps the previous run; records that are already archived are skipped:
+//
+//	audit_archive keygen -key archive.key -public-key archive.pub
+//	audit_archive -store /srv/audit -key archive.key -from 48h
+//	audit_archive verify -store /srv/audit -public-key archive.pub
+func main() {
+	log.SetFlags(0)
+	// Keys can be created and archives verified without API keys.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "keygen":
+			keygenCommand(os.Args[2:])
+			return
+		case "verify":
+			verifyCommand(os.Args[2:])
+			return
+		}
+	}
+
+	storeDir := flag.String("store", "audit_archive", "archive directory, created if it doesn't exist")
+	keyPath := flag.String("key", "archive.key", "PEM file of the Ed25519 private key to sign manifests with")
+	from := flag.String("from", "", "start of the time range, RFC 3339 or a duration ago such as 24h (default 24h)")
+	to := flag.String("to", "", "end of the time range, RFC 3339 or a duration ago (default now)")
+	flag.Parse()
+
+	now := time.Now()
+	start, err := timeflag.Parse(*from, now, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end, err := timeflag.Parse(*to, now, now)
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+	key, err := loadPrivateKey(*keyPath)
+	if err != nil {
+		log.Fatalf("failed to load private key: %v", err)
+	}
+	s, err := openStore(*storeDir, now, key)
+	if err != nil {
+		log.Fatalf("failed to open archive: %v", err)
+	}
+	defer s.Close()
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	before := s.seq
+	archiveErr := archive(ctx, client, s, start, end)
+	// Whatever was archived before an error is signed, so a failed run
+	// leaves a consistent archive to continue from.
+	m, err := s.Seal(time.Now())
+	if err != nil {
+		log.Fatalf("failed to sign manifest: %v", err)
+	}
+	fmt.Printf("Archived %v records; %v has %v records, ending with %v\n", s.seq-before, m.Day, m.Entries, m.Head)
+	if archiveErr != nil {
+		log.Fatalf("failed to archive: %v", archiveErr)
+	}
+}
+
+// archive adds the queries, replays and activities in a time range to the
+// store.
+func archive(ctx context.Context, client *sdm.Client, s *store, start, end time.Time) error {
+	queries, err := client.Queries().List(ctx, "after:? before:?", start, end)
+	if err != nil {
+		return fmt.Errorf("failed to list queries: %w", err)
+	}
+	var inProgress int
+	for queries.Next() {
+		q := queries.Value()
+		// A query that is still running will change, so it is archived by
+		// a later run once it has completed.
+		if q.CompletedAt.IsZero() {
+			inProgress++
+			continue
+		}
+		if err := s.Add(queryKind, q.ID, q.Timestamp, q); err != nil {
+			return fmt.Errorf("failed to archive query %v: %w", q.ID, err)
+		}
+		if q.Replayable && !s.Has(replayKind, q.ID) {
+			replay, err := replayOf(ctx, client, q.ID)
+			if err != nil {
+				return err
+			}
+			if err := s.Add(replayKind, q.ID, q.Timestamp, replay); err != nil {
+				return fmt.Errorf("failed to archive replay %v: %w", q.ID, err)
+			}
+		}
+	}
+	if err := queries.Err(); err != nil {
+		return fmt.Errorf("failed to iterate queries: %w", err)
+	}
+	if inProgress > 0 {
+		log.Printf("Skipped %v queries that are still in progress", inProgress)
+	}
+
+	activities, err := client.Activities().List(ctx, "after:? before:?", start, end)
+	if err != nil {
+		return fmt.Errorf("failed to list activities: %w", err)
+	}
+	for activities.Next() {
+		a := activities.Value()
+		if err := s.Add(activityKind, a.ID, a.CompletedAt, a); err != nil {
+			return fmt.Errorf("failed to archive activity %v: %w", a.ID, err)
+		}
+	}
+	if err := activities.Err(); err != nil {
+		return fmt.Errorf("failed to iterate activities: %w", err)
+	}
+	return nil
+}
+
+// replayOf returns every chunk of the replay of a query.
+func replayOf(ctx context.Context, client *sdm.Client, queryID string) ([]*sdm.ReplayChunk, error) {
+	replayParts, err := client.Replays().List(ctx, "id:?", queryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan replay %v: %w", queryID, err)
+	}
+	var chunks []*sdm.ReplayChunk
+	for replayParts.Next() {
+		chunks = append(chunks, replayParts.Value())
+	}
+	if err := replayParts.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate replay %v: %w", queryID, err)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,338 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of archived record.
+const (
+	queryKind    = "query"
+	replayKind   = "replay"
+	activityKind = "activity"
+)
+
+// The layout of a store directory:
+//
+//	objects/ab/cdef...   records as JSON, named by the SHA-256 of their content
+//	chain/2025-06-01.jsonl  the entries archived that day, one per line
+//	manifests/2025-06-01.json  the signed summary of that day's entries
+//	manifests/2025-06-01.sig   its Ed25519 signature, base64 encoded
+//
+// Days are UTC dates on which records were archived, not when they
+// happened, so a day's chain is only ever appended to on that day and
+// earlier days never change. The one exception is the manifest of a day
+// whose last run stopped before signing, which the next run writes.
+const (
+	objectsDir   = "objects"
+	chainDir     = "chain"
+	manifestsDir = "manifests"
+	dayFormat    = "2006-01-02"
+)
+
+// entry is a line of a chain file. Each entry commits to its record through
+// Object and to every entry before it through Prev, so changing, removing or
+// reordering a record changes the hash of every entry that follows.
+type entry struct {
+	Seq    int64     `json:"seq"`
+	Kind   string    `json:"kind"`
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Object string    `json:"object"`
+	Prev   string    `json:"prev"`
+	Hash   string    `json:"hash"`
+}
+
+// hash returns the hash an entry should have: the SHA-256 of its fields and
+// the hash of the entry before it.
+func (e *entry) hash() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s\n%s\n%s\n%s\n%s",
+		e.Seq, e.Kind, e.ID, e.Time.UTC().Format(time.RFC3339Nano), e.Object, e.Prev)))
+	return hex.EncodeToString(sum[:])
+}
+
+// manifest summarizes a day of the chain. It is signed, so the entries of a
+// day can't be changed, dropped or added to without the signature failing
+// to verify, and Prev links it to the day before.
+type manifest struct {
+	Day      string    `json:"day"`
+	FirstSeq int64     `json:"firstSeq"`
+	LastSeq  int64     `json:"lastSeq"`
+	Entries  int       `json:"entries"`
+	Prev     string    `json:"prev"`
+	Head     string    `json:"head"`
+	SignedAt time.Time `json:"signedAt"`
+}
+
+// store is an archive directory open for appending.
+type store struct {
+	dir string
+	day string
+	key ed25519.PrivateKey
+	// seq and head are those of the last entry in the chain.
+	seq  int64
+	head string
+	// dayStart is the head before the first entry of today.
+	dayStart string
+	today    []*entry
+	archived map[string]bool
+	chain    *os.File
+}
+
+// openStore opens or creates an archive directory, reading its chain to
+// find the last entry and which records are already archived. Entries
+// appended through it go into the chain file of day, and manifests are
+// signed with key.
+//
+// The chain is checked as it is read, and each day against its signed
+// manifest, so that records changed since they were archived are never
+// signed again. A store that fails the check isn't opened; audit_archive
+// verify shows what is wrong with it. Records left unsigned on an earlier
+// day by a run that stopped before Seal are signed now.
+func openStore(dir string, day time.Time, key ed25519.PrivateKey) (*store, error) {
+	s := &store{dir: dir, day: day.UTC().Format(dayFormat), key: key, archived: map[string]bool{}}
+	for _, sub := range []string{objectsDir, chainDir, manifestsDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	chainDays, err := listDays(filepath.Join(dir, chainDir), ".jsonl")
+	if err != nil {
+		return nil, err
+	}
+	manifestDays, err := listDays(filepath.Join(dir, manifestsDir), ".json")
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range union(chainDays, manifestDays) {
+		if d > s.day {
+			return nil, fmt.Errorf("the archive has records for %v, after %v", d, s.day)
+		}
+		if err := s.load(d, day); err != nil {
+			return nil, fmt.Errorf("%v: %w; run audit_archive verify for details", d, err)
+		}
+	}
+	if len(s.today) == 0 {
+		s.dayStart = s.head
+	}
+	s.chain, err = os.OpenFile(filepath.Join(dir, chainDir, s.day+".jsonl"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the chain of a day, checking that each entry follows the one
+// before it and that the entries signed for the day are unchanged.
+func (s *store) load(day string, now time.Time) error {
+	start := s.head
+	var entries []*entry
+	err := readChain(filepath.Join(s.dir, chainDir, day+".jsonl"), func(line int, e *entry) error {
+		if e.Seq != s.seq+1 || e.Prev != s.head {
+			return fmt.Errorf("line %v: record %v doesn't follow record %v", line, e.Seq, s.seq)
+		}
+		if e.hash() != e.Hash {
+			return fmt.Errorf("line %v: the entry for %v %v was changed", line, e.Kind, e.ID)
+		}
+		s.seq, s.head = e.Seq, e.Hash
+		s.archived[e.Kind+"/"+e.ID] = true
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	signed := 0
+	m, err := readManifest(s.dir, day, s.key.Public().(ed25519.PublicKey))
+	switch {
+	case err == nil:
+		if m.Prev != start || m.Entries > len(entries) ||
+			(m.Entries == 0 && m.Head != start) || (m.Entries > 0 && entries[m.Entries-1].Hash != m.Head) {
+			return errors.New("the chain doesn't match the signed manifest")
+		}
+		signed = m.Entries
+	case errors.Is(err, errNoManifest):
+		// The day's first run stopped before it signed anything.
+	default:
+		return err
+	}
+
+	if day == s.day {
+		s.dayStart, s.today = start, entries
+		return nil
+	}
+	if signed < len(entries) {
+		log.Printf("Signing %v records of %v left unsigned by an interrupted run", len(entries)-signed, day)
+		if _, err := s.sign(day, start, entries, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has reports whether a record is already archived.
+func (s *store) Has(kind, id string) bool {
+	return s.archived[kind+"/"+id]
+}
+
+// Add archives a record: v is written as a JSON object and an entry for it
+// is appended to the chain. Records that are already archived are skipped.
+func (s *store) Add(kind, id string, at time.Time, v interface{}) error {
+	if s.Has(kind, id) {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	object, err := s.putObject(data)
+	if err != nil {
+		return err
+	}
+	e := &entry{Seq: s.seq + 1, Kind: kind, ID: id, Time: at.UTC(), Object: object, Prev: s.head}
+	e.Hash = e.hash()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := s.chain.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	s.seq, s.head = e.Seq, e.Hash
+	s.today = append(s.today, e)
+	s.archived[kind+"/"+id] = true
+	return nil
+}
+
+// putObject writes data under its hash, unless it is already there, and
+// returns the hash.
+func (s *store) putObject(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	object := hex.EncodeToString(sum[:])
+	path := objectPath(s.dir, object)
+	if _, err := os.Stat(path); err == nil {
+		return object, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return object, writeFileAtomic(path, data)
+}
+
+// Seal syncs today's chain file and signs a manifest covering every entry
+// appended to it so far, replacing the one written by an earlier run today.
+// It returns the manifest.
+func (s *store) Seal(now time.Time) (*manifest, error) {
+	if err := s.chain.Sync(); err != nil {
+		return nil, err
+	}
+	return s.sign(s.day, s.dayStart, s.today, now)
+}
+
+// sign writes the signed manifest of a day's entries, which follow the
+// entry with hash prev.
+func (s *store) sign(day, prev string, entries []*entry, now time.Time) (*manifest, error) {
+	m := &manifest{Day: day, Entries: len(entries), Prev: prev, Head: prev, SignedAt: now.UTC()}
+	if len(entries) > 0 {
+		m.FirstSeq, m.LastSeq = entries[0].Seq, entries[len(entries)-1].Seq
+		m.Head = entries[len(entries)-1].Hash
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, data))
+	path := filepath.Join(s.dir, manifestsDir, day)
+	if err := writeFileAtomic(path+".json", data); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path+".sig", []byte(sig+"\n")); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *store) Close() error {
+	return s.chain.Close()
+}
+
+// writeFileAtomic writes to a temporary file first, so an interrupted run
+// can't leave a truncated file behind under the final name.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func objectPath(dir, object string) string {
+	return filepath.Join(dir, objectsDir, object[:2], object[2:])
+}
+
+// listDays returns the days that have a file with the given extension in
+// dir, in order.
+func listDays(dir, ext string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, f := range files {
+		day := strings.TrimSuffix(f.Name(), ext)
+		if f.Type().IsRegular() && day != f.Name() {
+			if _, err := time.Parse(dayFormat, day); err == nil {
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// readChain calls fn with each entry of a chain file and its line number.
+func readChain(path string, fn func(line int, e *entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%v:%v: %w", path, line, err)
+		}
+		if err := fn(line, &e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// verifyCommand checks every day of an archive against its signed manifest
+// and prints each record that was changed, removed, added or reordered. It
+// needs only the public key, so it can be run by an auditor, and exits with
+// status 1 if anything was found:
+//
+//	audit_archive verify -store /srv/audit -public-key archive.pub
+func verifyCommand(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	storeDir := flags.String("store", "audit_archive", "archive directory")
+	publicKeyPath := flags.String("public-key", "archive.pub", "PEM file of the Ed25519 public key the manifests are signed with")
+	flags.Parse(args)
+
+	publicKey, err := loadPublicKey(*publicKeyPath)
+	if err != nil {
+		log.Fatalf("failed to load public key: %v", err)
+	}
+	v, err := verifyStore(*storeDir, publicKey)
+	if err != nil {
+		log.Fatalf("failed to verify archive: %v", err)
+	}
+	for _, problem := range v.Problems {
+		fmt.Println(problem)
+	}
+	fmt.Printf("Checked %v records archived over %v days; the last is record %v with hash %v\n", v.Records, v.Days, v.Seq, v.Head)
+	if len(v.Problems) > 0 {
+		fmt.Printf("%v problems found\n", len(v.Problems))
+		os.Exit(1)
+	}
+	// Removing the most recent days along with their manifests leaves a
+	// shorter archive that is still consistent, so the head should also be
+	// recorded somewhere the archive's owner can't change.
+	fmt.Println("No problems found. Compare the hash above with one recorded outside the archive to detect removal of the latest days.")
+}
+
+// verification is the result of checking an archive.
+type verification struct {
+	Days    int
+	Records int
+	// Seq and Head are those of the last entry.
+	Seq      int64
+	Head     string
+	Problems []string
+}
+
+func (v *verification) problem(format string, args ...interface{}) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// verifyStore checks the chain of an archive entry by entry, the objects the
+// entries point to, and each day against its manifest. After a problem it
+// carries on from the record that follows, or from the signed manifest at
+// the end of a day, so each problem is reported once rather than at every
+// record after it.
+func verifyStore(dir string, publicKey ed25519.PublicKey) (*verification, error) {
+	chainDays, err := listDays(filepath.Join(dir, chainDir), ".jsonl")
+	if err != nil {
+		return nil, err
+	}
+	manifestDays, err := listDays(filepath.Join(dir, manifestsDir), ".json")
+	if err != nil {
+		return nil, err
+	}
+	days := union(chainDays, manifestDays)
+
+	v := &verification{}
+	for _, day := range days {
+		v.Days++
+		m, err := readManifest(dir, day, publicKey)
+		if err != nil {
+			v.problem("%v: %v", day, err)
+		} else if m.Prev != v.Head {
+			v.problem("%v: the manifest doesn't follow the day before it; days are missing or out of order", day)
+		}
+
+		var entries int
+		var firstSeq int64
+		path := filepath.Join(dir, chainDir, day+".jsonl")
+		err = readChain(path, func(line int, e *entry) error {
+			where := fmt.Sprintf("%v line %v", day, line)
+			if e.Seq != v.Seq+1 {
+				v.problem("%v: record %v follows record %v; records are missing or out of order", where, e.Seq, v.Seq)
+			} else if e.Prev != v.Head {
+				v.problem("%v: record %v doesn't follow the record before it", where, e.Seq)
+			}
+			if e.hash() != e.Hash {
+				v.problem("%v: the entry for %v %v was changed", where, e.Kind, e.ID)
+			}
+			if err := checkObject(dir, e.Object); err != nil {
+				v.problem("%v: %v %v: %v", where, e.Kind, e.ID, err)
+			}
+			if entries == 0 {
+				firstSeq = e.Seq
+			}
+			entries++
+			v.Records++
+			v.Seq, v.Head = e.Seq, e.Hash
+			return nil
+		})
+		if errors.Is(err, os.ErrNotExist) {
+			v.problem("%v: the chain file is missing", day)
+		} else if err != nil {
+			v.problem("%v: %v", day, err)
+		}
+
+		if m == nil {
+			continue
+		}
+		if m.Entries != entries || m.Head != v.Head || (entries > 0 && (m.FirstSeq != firstSeq || m.LastSeq != v.Seq)) {
+			v.problem("%v: the chain has %v records ending with %v, but the signed manifest has %v ending with %v; records were added, removed or changed after it was signed",
+				day, entries, short(v.Head), m.Entries, short(m.Head))
+		}
+		// Carry on from what was signed.
+		v.Head = m.Head
+		if m.Entries > 0 {
+			v.Seq = m.LastSeq
+		}
+	}
+	return v, nil
+}
+
+// errNoManifest is returned by readManifest for a day without a manifest.
+var errNoManifest = errors.New("the manifest is missing")
+
+// readManifest reads the manifest of a day and checks its signature.
+func readManifest(dir, day string, publicKey ed25519.PublicKey) (*manifest, error) {
+	path := filepath.Join(dir, manifestsDir, day)
+	data, err := os.ReadFile(path + ".json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoManifest
+	} else if err != nil {
+		return nil, err
+	}
+	sigText, err := os.ReadFile(path + ".sig")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("the manifest signature is missing")
+	} else if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sigText)))
+	if err != nil || !ed25519.Verify(publicKey, data, sig) {
+		return nil, fmt.Errorf("the manifest signature doesn't verify; the manifest was changed or signed with another key")
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Day != day {
+		return nil, fmt.Errorf("the manifest is for %v; manifests were renamed", m.Day)
+	}
+	return &m, nil
+}
+
+// checkObject checks that an object exists and still has the content its
+// name is the hash of.
+func checkObject(dir, object string) error {
+	if len(object) != sha256.Size*2 {
+		return fmt.Errorf("invalid object name %q", object)
+	}
+	data, err := os.ReadFile(objectPath(dir, object))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("object %v is missing", short(object))
+	} else if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != object {
+		return fmt.Errorf("object %v was changed", short(object))
+	}
+	return nil
+}
+
+func union(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = true
+	}
+	all := make([]string, 0, len(set))
+	for s := range set {
+		all = append(all, s)
+	}
+	sort.Strings(all)
+	return all
+}
+
+// short abbreviates a hash for messages.
+func short(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	if hash == "" {
+		return "nothing"
+	}
+	return hash
+}
@@ -0,0 +1,315 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test archive has two days: q-1 and q-2 on the first and q-3 on the
+// second.
+const (
+	day1 = "2025-06-01"
+	day2 = "2025-06-02"
+)
+
+func TestVerifyStore(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, dir string)
+		// problems are substrings of the problems expected, in order.
+		problems []string
+	}{
+		{
+			"untouched",
+			func(t *testing.T, dir string) {},
+			nil,
+		},
+		{
+			"object changed",
+			func(t *testing.T, dir string) {
+				writeFile(t, objectPath(dir, chainEntry(t, dir, day1, 1).Object), `{"id":"q-2","body":"DROP TABLE x"}`)
+			},
+			[]string{day1 + " line 2: query q-2: object"},
+		},
+		{
+			"object removed",
+			func(t *testing.T, dir string) {
+				if err := os.Remove(objectPath(dir, chainEntry(t, dir, day2, 0).Object)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			[]string{day2 + " line 1: query q-3: object"},
+		},
+		{
+			"entry changed",
+			func(t *testing.T, dir string) {
+				editChain(t, dir, day1, func(lines []string) []string {
+					lines[0] = strings.Replace(lines[0], `"id":"q-1"`, `"id":"q-9"`, 1)
+					return lines
+				})
+			},
+			[]string{day1 + " line 1: the entry for query q-9 was changed"},
+		},
+		{
+			"entry removed",
+			func(t *testing.T, dir string) {
+				editChain(t, dir, day1, func(lines []string) []string { return lines[:1] })
+			},
+			// The next day carries on from what was signed, so the gap is
+			// reported once.
+			[]string{day1 + ": the chain has 1 records"},
+		},
+		{
+			"entries reordered",
+			func(t *testing.T, dir string) {
+				editChain(t, dir, day1, func(lines []string) []string {
+					return []string{lines[1], lines[0]}
+				})
+			},
+			[]string{
+				day1 + " line 1: record 2 follows record 0",
+				day1 + " line 2: record 1 follows record 2",
+				day1 + ": the chain has 2 records",
+			},
+		},
+		{
+			"entry appended after signing",
+			func(t *testing.T, dir string) {
+				editChain(t, dir, day2, func(lines []string) []string {
+					e := chainEntry(t, dir, day2, 0)
+					extra := &entry{Seq: e.Seq + 1, Kind: queryKind, ID: "q-4", Time: e.Time, Object: e.Object, Prev: e.Hash}
+					extra.Hash = extra.hash()
+					line, err := json.Marshal(extra)
+					if err != nil {
+						t.Fatal(err)
+					}
+					return append(lines, string(line))
+				})
+			},
+			[]string{day2 + ": the chain has 2 records"},
+		},
+		{
+			"chain file removed",
+			func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, chainDir, day2+".jsonl")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			[]string{day2 + ": the chain file is missing", day2 + ": the chain has 0 records"},
+		},
+		{
+			"manifest changed",
+			func(t *testing.T, dir string) {
+				path := filepath.Join(dir, manifestsDir, day1+".json")
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, path, strings.Replace(string(data), `"entries": 2`, `"entries": 1`, 1))
+			},
+			[]string{day1 + ": the manifest signature doesn't verify"},
+		},
+		{
+			"manifest removed",
+			func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, manifestsDir, day2+".json")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			[]string{day2 + ": the manifest is missing"},
+		},
+		{
+			"day removed",
+			func(t *testing.T, dir string) {
+				for _, path := range []string{
+					filepath.Join(dir, chainDir, day1+".jsonl"),
+					filepath.Join(dir, manifestsDir, day1+".json"),
+					filepath.Join(dir, manifestsDir, day1+".sig"),
+				} {
+					if err := os.Remove(path); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			[]string{day2 + ": the manifest doesn't follow the day before it", day2 + " line 1: record 3 follows record 0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, publicKey := newTestStore(t)
+			tt.tamper(t, dir)
+			v, err := verifyStore(dir, publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(v.Problems) != len(tt.problems) {
+				t.Fatalf("verifyStore() found %q, want %v problems", v.Problems, len(tt.problems))
+			}
+			for i, want := range tt.problems {
+				if !strings.Contains(v.Problems[i], want) {
+					t.Errorf("problem %v = %q, want it to contain %q", i, v.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyStoreWrongKey(t *testing.T) {
+	dir, _ := newTestStore(t)
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := verifyStore(dir, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Problems) != 2 {
+		t.Errorf("verifyStore() with another key found %q, want a problem with each manifest", v.Problems)
+	}
+}
+
+func TestOpenStoreSignsInterruptedDay(t *testing.T) {
+	dir := t.TempDir()
+	publicKey, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now, err := time.Parse(dayFormat, day1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The run on the first day signs q-1, then stops before signing q-2.
+	s, err := openStore(dir, now, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"q-1", "q-2"} {
+		if err := s.Add(queryKind, id, now, map[string]string{"id": id}); err != nil {
+			t.Fatal(err)
+		}
+		if id == "q-1" {
+			if _, err := s.Seal(now); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next day's run signs q-2 before carrying on.
+	s, err = openStore(dir, now.AddDate(0, 0, 1), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	m, err := readManifest(dir, day1, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Entries != 2 || m.Head != chainEntry(t, dir, day1, 1).Hash {
+		t.Errorf("manifest of %v = %+v, want both records signed", day1, m)
+	}
+	if !s.Has(queryKind, "q-2") || s.seq != 2 || len(s.today) != 0 {
+		t.Errorf("openStore() = seq %v with %v records today, want seq 2 with none", s.seq, len(s.today))
+	}
+	if err := s.Add(queryKind, "q-3", now, map[string]string{"id": "q-3"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Seal(now.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	v, err := verifyStore(dir, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Problems) != 0 || v.Records != 3 {
+		t.Errorf("verifyStore() found %v records and %q, want 3 and no problems", v.Records, v.Problems)
+	}
+}
+
+// newTestStore archives three queries over two days, sealing each day.
+func newTestStore(t *testing.T) (string, ed25519.PublicKey) {
+	dir := t.TempDir()
+	publicKey, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []struct {
+		date string
+		ids  []string
+	}{
+		{day1, []string{"q-1", "q-2"}},
+		{day2, []string{"q-3"}},
+	} {
+		now, err := time.Parse(dayFormat, day.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := openStore(dir, now, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range day.ids {
+			if err := s.Add(queryKind, id, now, map[string]string{"id": id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.Seal(now); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, publicKey
+}
+
+func chainEntry(t *testing.T, dir, day string, i int) *entry {
+	var found *entry
+	err := readChain(filepath.Join(dir, chainDir, day+".jsonl"), func(line int, e *entry) error {
+		if line == i+1 {
+			found = e
+		}
+		return nil
+	})
+	if err != nil || found == nil {
+		t.Fatalf("failed to read entry %v of %v: %v", i, day, err)
+	}
+	return found
+}
+
+func editChain(t *testing.T, dir, day string, edit func(lines []string) []string) {
+	path := filepath.Join(dir, chainDir, day+".jsonl")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := edit(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+	writeFile(t, path, strings.Join(lines, "\n")+"\n")
+}
+
+func writeFile(t *testing.T, path, data string) {
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,8 +1,12 @@
-module github.com/strongdm/strongdm-sdk-go-examples/4_managing_gateways/create_gateway
+module github.com/strongdm/strongdm-sdk-go-examples/5_auditing/audit_resource
 
-go 1.20
+go 1.24.5
 
-require github.com/strongdm/strongdm-sdk-go/v3 v3.7.1
+require (
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/auditwait v0.0.0
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache v0.0.0
+	github.com/strongdm/strongdm-sdk-go/v15 v15.21.0
+)
 
 require (
 	github.com/golang/protobuf v1.5.0 // indirect
@@ -13,3 +17,8 @@ require (
 	google.golang.org/grpc v1.33.1 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
 )
+
+replace (
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/auditwait => ../auditwait
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache => ../snapshotcache
+)
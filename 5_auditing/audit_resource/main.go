@@ -20,7 +20,9 @@ import (
 	"os"
 	"time"
 
-	sdm "github.com/strongdm/strongdm-sdk-go/v3"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/auditwait"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
 )
 
 func main() {
@@ -44,7 +46,7 @@ func main() {
 	}
 
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 	/* * * */
 	// Set up some audit records to read
@@ -79,43 +81,60 @@ func main() {
 	deletedAt := time.Now()
 	/* * * */
 
-	// Audit records may take a few seconds to be processed.
-	time.Sleep(4 * time.Second)
+	// Audit records may take a few seconds to be processed. Wait until the
+	// activity and history entry of each change are visible before reading
+	// the snapshots.
+	changes := []struct {
+		verb string
+		at   time.Time
+	}{
+		{sdm.ActivityVerbResourceAdded, start},
+		{sdm.ActivityVerbResourceUpdated, createdAt},
+		{sdm.ActivityVerbResourceDeleted, renamedAt},
+	}
+	for _, change := range changes {
+		activity, err := auditwait.ForActivity(ctx, client, resourceID, change.verb, change.at, auditwait.DefaultOptions)
+		if err != nil {
+			log.Fatalf("failed to wait for audit records: %v", err)
+		}
+		_, err = auditwait.ForResourceHistory(ctx, client, resourceID, activity.ID, auditwait.DefaultOptions)
+		if err != nil {
+			log.Fatalf("failed to wait for audit records: %v", err)
+		}
+	}
 
-	_, err = client.SnapshotAt(start).Resources().Get(ctx, resourceID)
+	resources := snapshotcache.New(client, 0)
+
+	_, err = resources.Resource(ctx, resourceID, start)
 	fmt.Printf("Attempting to retrieve resource before creation (%v): %v\n", start, err) // Does Not Exist
 
-	getResp, err := client.SnapshotAt(createdAt).Resources().Get(ctx, resourceID)
+	resource, err := resources.Resource(ctx, resourceID, createdAt)
 	if err != nil {
 		log.Fatalf("failed to retrieve created redis: %v", err)
 	}
 
-	fmt.Printf("Resource name after creation (%v): %v", createdAt, getResp.Resource.GetName()) // example-redis
+	fmt.Printf("Resource name after creation (%v): %v\n", createdAt, resource.GetName()) // example-redis
 
-	getResp, err = client.SnapshotAt(renamedAt).Resources().Get(ctx, resourceID)
+	resource, err = resources.Resource(ctx, resourceID, renamedAt)
 	if err != nil {
 		log.Fatalf("failed to retrieve renamed redis: %v", err)
 	}
 
-	fmt.Printf("Resource name after rename (%v): %v", renamedAt, getResp.Resource.GetName()) // example-redis-renamed
+	fmt.Printf("Resource name after rename (%v): %v\n", renamedAt, resource.GetName()) // example-redis-renamed
 
-	_, err = client.SnapshotAt(deletedAt).Resources().Get(ctx, resourceID)
+	_, err = resources.Resource(ctx, resourceID, deletedAt)
 	fmt.Printf("Attempting to retrieve resource after deletion (%v): %v\n", deletedAt, err) // Does Not Exist
 
 	fmt.Println("Full history of the resource:")
-	history, err := client.ResourcesHistory().List(ctx, "id:?", resourceID)
+	history, err := snapshotcache.History(ctx, client, "resource", resourceID)
 	if err != nil {
 		log.Fatalf("failed to list resource history: %v", err)
 	}
-	for history.Next() {
-		v := history.Value()
+	for _, v := range history {
 		activity, err := client.Activities().Get(ctx, v.ActivityID)
 		if err != nil {
 			log.Fatalf("failed to lookup history: %v", err)
 		}
 		fmt.Println(activity.Activity.Description) // created, updated, deleted resource; in order
 	}
-	if err := history.Err(); err != nil {
-		log.Fatalf("failed to finish listing resource history: %v", err)
-	}
 }
@@ -0,0 +1,186 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auditwait waits for audit records to become visible.
+//
+// Audit records are processed asynchronously, so an activity or history entry
+// may not be returned for a few seconds after the change that produced it.
+// Instead of sleeping for a fixed time and hoping, the functions in this
+// package poll with exponential backoff until the expected record appears or
+// a deadline passes.
+package auditwait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Options controls how long and how often to poll.
+type Options struct {
+	// Timeout is how long to wait before giving up.
+	Timeout time.Duration
+	// InitialInterval is the delay before the first retry. It doubles after
+	// every attempt, up to MaxInterval.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// DefaultOptions suits records that normally appear within a few seconds.
+var DefaultOptions = Options{
+	Timeout:         time.Minute,
+	InitialInterval: 250 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+}
+
+// clockSkew is how far the API server's clock may be behind the local one.
+// Activities completed up to this long before since are still accepted.
+const clockSkew = 5 * time.Second
+
+// TimeoutError reports that the expected record did not appear in time.
+type TimeoutError struct {
+	// What describes the record that was expected.
+	What     string
+	Waited   time.Duration
+	Attempts int
+	// Last is the error returned by the most recent attempt, if it failed.
+	Last error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("timed out after %v and %v attempts waiting for %v", e.Waited.Round(time.Millisecond), e.Attempts, e.What)
+	if e.Last != nil {
+		msg += fmt.Sprintf(" (last error: %v)", e.Last)
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Last
+}
+
+// ForActivity waits until an activity with the given verb, such as
+// sdm.ActivityVerbResourceUpdated, that involves the entity with the given
+// ID and completed at or after since, allowing for clockSkew, is visible.
+// It returns the first such activity.
+func ForActivity(ctx context.Context, client *sdm.Client, entityID, verb string, since time.Time, opts Options) (*sdm.Activity, error) {
+	var found *sdm.Activity
+	what := fmt.Sprintf("%q activity on %v", verb, entityID)
+	err := poll(ctx, what, opts, func(ctx context.Context) (bool, error) {
+		activities, err := client.Activities().List(ctx, "after:?", since.Add(-clockSkew))
+		if err != nil {
+			return false, err
+		}
+		for activities.Next() {
+			activity := activities.Value()
+			if matches(activity, entityID, verb, since) {
+				found = activity
+				return true, nil
+			}
+		}
+		return false, activities.Err()
+	})
+	return found, err
+}
+
+func matches(activity *sdm.Activity, entityID, verb string, since time.Time) bool {
+	if !strings.EqualFold(activity.Verb, verb) || activity.CompletedAt.Before(since.Add(-clockSkew)) {
+		return false
+	}
+	for _, entity := range activity.Entities {
+		if entity.ID == entityID {
+			return true
+		}
+	}
+	return false
+}
+
+// ForResourceHistory waits until the resource history entry recorded for an
+// activity is visible, so that snapshots taken after the activity reflect
+// it.
+func ForResourceHistory(ctx context.Context, client *sdm.Client, resourceID, activityID string, opts Options) (*sdm.ResourceHistory, error) {
+	var found *sdm.ResourceHistory
+	what := fmt.Sprintf("history of %v for activity %v", resourceID, activityID)
+	err := poll(ctx, what, opts, func(ctx context.Context) (bool, error) {
+		history, err := client.ResourcesHistory().List(ctx, "id:?", resourceID)
+		if err != nil {
+			return false, err
+		}
+		for history.Next() {
+			if h := history.Value(); h.ActivityID == activityID {
+				found = h
+				return true, nil
+			}
+		}
+		return false, history.Err()
+	})
+	return found, err
+}
+
+// poll calls check until it reports done, backing off between attempts.
+// Errors from check are treated as transient and retried; the last one is
+// kept in the TimeoutError. Each check runs under the timeout too, so a call
+// that hangs can't hold polling past it. Cancelling ctx stops polling with
+// ctx's error.
+func poll(ctx context.Context, what string, opts Options, check func(context.Context) (bool, error)) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.InitialInterval <= 0 {
+		opts.InitialInterval = DefaultOptions.InitialInterval
+	}
+	if opts.MaxInterval < opts.InitialInterval {
+		opts.MaxInterval = opts.InitialInterval
+	}
+
+	start := time.Now()
+	checkCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	timeout := &TimeoutError{What: what}
+	interval := opts.InitialInterval
+	for {
+		timeout.Attempts++
+		done, err := check(checkCtx)
+		if done {
+			return nil
+		}
+		timeout.Last = err
+
+		wait := time.NewTimer(interval)
+		select {
+		case <-wait.C:
+		case <-checkCtx.Done():
+			wait.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			timeout.Waited = time.Since(start)
+			return timeout
+		}
+		interval *= 2
+		if interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// IsTimeout reports whether err is a TimeoutError.
+func IsTimeout(err error) bool {
+	var timeout *TimeoutError
+	return errors.As(err, &timeout)
+}
@@ -0,0 +1,68 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auditwait
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	opts := Options{Timeout: 200 * time.Millisecond, InitialInterval: time.Millisecond, MaxInterval: 10 * time.Millisecond}
+	errNotYet := errors.New("not yet")
+
+	// A record that appears on the third attempt.
+	attempts := 0
+	err := poll(context.Background(), "record", opts, func(ctx context.Context) (bool, error) {
+		attempts++
+		if attempts < 3 {
+			return false, errNotYet
+		}
+		return true, nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("poll() = %v after %v attempts, want success after 3", err, attempts)
+	}
+
+	// A record that never appears.
+	err = poll(context.Background(), "record", opts, func(ctx context.Context) (bool, error) {
+		return false, errNotYet
+	})
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, errNotYet) || timeout.Attempts < 2 {
+		t.Errorf("poll() of a missing record = %v, want a timeout after several attempts", err)
+	}
+
+	// A check that hangs is cut off at the timeout.
+	start := time.Now()
+	err = poll(context.Background(), "record", opts, func(ctx context.Context) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	})
+	if !IsTimeout(err) || time.Since(start) > 5*time.Second {
+		t.Errorf("poll() of a hung check = %v after %v, want a timeout after %v", err, time.Since(start), opts.Timeout)
+	}
+
+	// Cancelling ctx stops polling with its error.
+	ctx, cancel := context.WithCancel(context.Background())
+	err = poll(ctx, "record", opts, func(context.Context) (bool, error) {
+		cancel()
+		return false, errNotYet
+	})
+	if !errors.Is(err, context.Canceled) || IsTimeout(err) {
+		t.Errorf("poll() after cancel = %v, want %v", err, context.Canceled)
+	}
+}
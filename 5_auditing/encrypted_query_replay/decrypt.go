@@ -0,0 +1,86 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+)
+
+// padding is the mode used by every query cipher. It is set from the
+// -padding flag.
+var padding = querycrypt.ZeroPadding
+
+func registerPaddingFlag(flags *flag.FlagSet) {
+	flags.Func("padding", "padding to remove after decryption: zero, pkcs7 or auto (default zero)", func(s string) error {
+		var err error
+		padding, err = querycrypt.ParsePadding(s)
+		return err
+	})
+}
+
+// corruptionReport counts decryption failures by kind, so that an export can
+// carry on past damaged records and summarize them at the end.
+type corruptionReport struct {
+	counts map[string]int
+}
+
+var errorKinds = []struct {
+	err  error
+	name string
+}{
+	{querycrypt.ErrTruncated, "truncated"},
+	{querycrypt.ErrMisaligned, "misaligned"},
+	{querycrypt.ErrWrongKey, "wrong key"},
+	{querycrypt.ErrBadPadding, "bad padding"},
+	{querycrypt.ErrInvalidEncoding, "invalid base64"},
+	{querycrypt.ErrInvalidJSON, "invalid JSON"},
+}
+
+// Add records a failure.
+func (r *corruptionReport) Add(err error) {
+	if r.counts == nil {
+		r.counts = map[string]int{}
+	}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind.err) {
+			r.counts[kind.name]++
+			return
+		}
+	}
+	r.counts["other"]++
+}
+
+// Total returns the number of failures recorded.
+func (r *corruptionReport) Total() int {
+	total := 0
+	for _, n := range r.counts {
+		total += n
+	}
+	return total
+}
+
+func (r *corruptionReport) String() string {
+	var parts []string
+	for name, n := range r.counts {
+		parts = append(parts, fmt.Sprintf("%v %v", n, name))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
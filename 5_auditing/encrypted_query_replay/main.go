@@ -14,26 +14,36 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
 	"time"
 
-	sdm "github.com/strongdm/strongdm-sdk-go/v3"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
 )
 
 func main() {
+	// Relay log files can be decrypted or re-encrypted for a new key offline,
+	// without API keys.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "decrypt-logs":
+			decryptLogsCommand(os.Args[2:])
+			return
+		case "rekey":
+			rekeyCommand(os.Args[2:])
+			return
+		}
+	}
+
 	//	Load the SDM API keys from the environment.
 	//	If these values are not set in your environment,
 	//	please follow the documentation here:
@@ -44,17 +54,11 @@ func main() {
 		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
 	}
 
-	// Load the private key for query and replay decryption.
-	// This environment variable should contain the path to the private encryption
-	// key configured for StrongDM remote log encryption.
-	privateKeyFile := os.Getenv("SDM_LOG_PRIVATE_KEY_FILE")
-	if privateKeyFile == "" {
-		log.Fatal("SDM_LOG_PRIVATE_KEY_FILE must be provided for this example")
-	}
-	privateKey, err := loadPrivateKeyFromFile(privateKeyFile)
-	if err != nil {
-		log.Fatal("failed to load private key: %v", err)
-	}
+	registerPaddingFlag(flag.CommandLine)
+	registerRedactFlag(flag.CommandLine)
+	flag.Parse()
+
+	privateKeys := loadPrivateKeysFromEnv()
 
 	// Create the client
 	client, err := sdm.New(
@@ -80,119 +84,126 @@ func main() {
 	resource := resourceResp.Value()
 
 	fmt.Printf("Queries made against %v:\n", resourceName)
+	// Accounts are looked up as they were when each query was made.
+	accounts := snapshotcache.New(client, 0)
 	queries, err := client.Queries().List(ctx, "resource_id:?", resource.GetID())
 	if err != nil {
 		log.Fatalf("failed to list queries: %v", err)
 	}
 	for queries.Next() {
 		q := queries.Value()
-		accountResp, err := client.SnapshotAt(q.Timestamp).Accounts().Get(ctx, q.AccountID)
+		account, err := accounts.Account(ctx, q.AccountID, q.Timestamp)
 		if err != nil {
 			log.Fatalf("failed to get account: %v", err)
 		}
-		user := accountResp.Account.(*sdm.User)
+		email := snapshotcache.AccountName(account)
+		redactions := redactor.Session()
 
+		// The symmetric key is the same for the query body and every replay
+		// chunk, so it is unwrapped once per query. A query that can't be
+		// decrypted is reported and skipped rather than ending the replay.
+		var qc *querycrypt.Cipher
 		if q.Encrypted {
 			fmt.Println("Decrypting encrypted query")
-			queryBody, err := base64.StdEncoding.DecodeString(q.QueryBody)
+			qc, q.QueryBody, err = decryptQueryBody(privateKeys, q)
 			if err != nil {
-				log.Fatalf("failed to decode query body: %v", err)
-			}
-			q.QueryBody, err = decryptQueryData(privateKey, q.QueryKey, queryBody)
-			if err != nil {
-				log.Fatalf("failed to decrypt query body: %v", err)
+				fmt.Printf("Skipping query %v made by %v at %v: %v\n", q.ID, email, q.Timestamp, err)
+				continue
 			}
 			var capture struct{ Type string }
 			if err := json.Unmarshal([]byte(q.QueryBody), &capture); err != nil {
-				log.Fatalf("failed to unmarshal query JSON %v: %v", q.QueryBody, err)
+				fmt.Printf("Skipping query %v made by %v at %v: %v: %v\n", q.ID, email, q.Timestamp, querycrypt.ErrInvalidJSON, err)
+				continue
 			}
-			q.Replayable = capture.Type == "shell"
+			q.Replayable = capture.Type == "shell" || kube.CaptureOf(q).IsSession()
 		}
 
 		if q.Replayable {
-			fmt.Printf("Replaying query made by %v at %v\n", user.Email, q.Timestamp)
-			replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
-			if err != nil {
-				log.Fatalf("failed to scan replay: %v", err)
-			}
-			for replayParts.Next() {
-				part := replayParts.Value()
-				if q.Encrypted {
-					partData, err := decryptQueryData(privateKey, q.QueryKey, part.Data)
-					if err != nil {
-						log.Fatalf("failed to decrypt replay data: %v", err)
-					}
-					var events []struct {
-						Data     []byte
-						Duration int64
-					}
-					if err := json.Unmarshal([]byte(partData), &events); err != nil {
-						log.Fatalf("failed to unmarshal events JSON %v: %v", partData, err)
-					}
-					for _, e := range events {
-						event := &sdm.ReplayChunkEvent{
-							Data:     e.Data,
-							Duration: time.Millisecond * time.Duration(e.Duration),
-						}
-						part.Events = append(part.Events, event)
-					}
+			fmt.Printf("Replaying query made by %v at %v\n", email, q.Timestamp)
+			// Chunks are decrypted ahead of playback by a pool of workers
+			// and arrive here in their original order.
+			var corrupt corruptionReport
+			stream := redactions.Stream()
+			k8s := kube.StreamFor(q)
+			chunks, listErr := streamReplay(ctx, client, q.ID)
+			for part := range decryptReplay(ctx, qc, chunks, runtime.NumCPU()) {
+				if part.Err != nil {
+					// Leave a marker in the output and carry on with the
+					// next chunk.
+					fmt.Printf("\n[chunk %v could not be decrypted: %v]\n", part.Index, part.Err)
+					corrupt.Add(part.Err)
+					continue
 				}
-
 				for _, ev := range part.Events {
 					// Some characters may not be printed cleanly by this method
-					fmt.Print(string(ev.Data))
+					fmt.Print(string(stream.Write(k8s.Write(ev.Data))))
 					time.Sleep(ev.Duration)
 				}
 			}
-			if err := replayParts.Err(); err != nil {
-				log.Fatalf("failed to iterate replay: %v", err)
+			if err := listErr(); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Print(string(stream.Write(k8s.Flush())))
+			fmt.Println(string(stream.Flush()))
+			fmt.Printf("Replay of query %v: %v\n", q.ID, redactions)
+			if corrupt.Total() > 0 {
+				fmt.Printf("%v replay chunks could not be decrypted (%v)\n", corrupt.Total(), &corrupt)
 			}
-			fmt.Println("")
+		} else if call, ok := kube.DescribeCall(q); ok {
+			// Other Kubernetes requests are API calls such as kubectl get.
+			fmt.Printf("Kubernetes call by %v at %v: %v\n", email, q.Timestamp, redactions.Text(call))
 		} else {
 			var capture struct{ Command string }
 			if err := json.Unmarshal([]byte(q.QueryBody), &capture); err != nil {
-				log.Fatalf("failed to unmarshal query JSON %v: %v", q.QueryBody, err)
+				fmt.Printf("Skipping query %v made by %v at %v: %v: %v\n", q.ID, email, q.Timestamp, querycrypt.ErrInvalidJSON, err)
+				continue
 			}
-			fmt.Printf("Command run by %v at %v: %v\n", user.Email, q.Timestamp, capture.Command)
+			fmt.Printf("Command run by %v at %v: %v\n", email, q.Timestamp, redactions.Text(capture.Command))
 		}
 	}
 }
 
-func loadPrivateKeyFromFile(privateKeyFile string) (*rsa.PrivateKey, error) {
-	privateKeyBytes, err := os.ReadFile(privateKeyFile)
-	if err != nil {
-		return nil, err
-	}
-	pemBlock, _ := pem.Decode(privateKeyBytes)
-	return x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
+// redactor masks keys, tokens and passwords in decrypted queries and replays
+// before they are printed or written. Rules can be added with -redact-rules.
+var redactor = redact.New(redact.Builtins()...)
+
+func registerRedactFlag(flags *flag.FlagSet) {
+	flags.Func("redact-rules", "JSON file of redaction rules to add to the built-in ones", func(path string) error {
+		var err error
+		redactor, err = redact.Load(path)
+		return err
+	})
 }
 
-// This method demonstrates how to decrypt encrypted query/replay data
-func decryptQueryData(privateKey *rsa.PrivateKey, encryptedQueryKey string, encryptedData []byte) (string, error) {
-	// Use the organization's private key to decrypt the symmetric key
-	queryKeyBytes, err := base64.StdEncoding.DecodeString(encryptedQueryKey)
-	if err != nil {
-		return "", err
+// loadPrivateKeysFromEnv loads the private keys for query and replay decryption.
+// SDM_LOG_PRIVATE_KEY_FILE should contain the path to the private encryption
+// key configured for StrongDM remote log encryption. After a key rotation,
+// list the previous keys as well (separated by ":") so that queries recorded
+// before the rotation can still be decrypted. Encrypted keys are unlocked
+// with a passphrase read from SDM_LOG_PRIVATE_KEY_PASSPHRASE_FD, or prompted
+// for on the terminal.
+func loadPrivateKeysFromEnv() querycrypt.Keyring {
+	privateKeyFile := os.Getenv("SDM_LOG_PRIVATE_KEY_FILE")
+	if privateKeyFile == "" {
+		log.Fatal("SDM_LOG_PRIVATE_KEY_FILE must be provided for this example")
 	}
-	symmetricKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, queryKeyBytes, nil)
+	privateKeys, err := querycrypt.LoadKeyring(privateKeyFile, querycrypt.PassphraseSource())
 	if err != nil {
-		return "", err
+		log.Fatalf("failed to load private key: %v", err)
 	}
+	return privateKeys
+}
 
-	// Use the symmetric key to decrypt the data
-	block, err := aes.NewCipher(symmetricKey)
+// decryptQueryBody unwraps the symmetric key of an encrypted query and uses it
+// to decrypt the query body.
+func decryptQueryBody(privateKeys querycrypt.Keyring, q *sdm.Query) (*querycrypt.Cipher, string, error) {
+	qc, err := querycrypt.NewCipher(privateKeys, q.QueryKey, padding)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
-	if len(encryptedData) < block.BlockSize() {
-		return "", fmt.Errorf("ciphertext is smaller than AES block size %v", block.BlockSize())
+	body, err := qc.DecryptBase64(q.QueryBody)
+	if err != nil {
+		return nil, "", fmt.Errorf("query body: %w", err)
 	}
-	iv := encryptedData[:block.BlockSize()]
-	ciphertext := encryptedData[block.BlockSize():]
-
-	plaintext := make([]byte, len(ciphertext))
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(plaintext, ciphertext)
-
-	return string(bytes.TrimRight(plaintext, "\x00")), nil
+	return qc, body, nil
 }
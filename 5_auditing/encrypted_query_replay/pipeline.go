@@ -0,0 +1,165 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// replayPart is a replay chunk together with its position in the replay.
+type replayPart struct {
+	Index  int
+	Chunk  *sdm.ReplayChunk
+	Events []*sdm.ReplayChunkEvent
+	Err    error
+}
+
+// streamReplay lists the replay chunks of a query in the background. The
+// returned function waits for the listing to finish and reports its error;
+// call it once, after the chunks have been consumed or ctx is done.
+func streamReplay(ctx context.Context, client *sdm.Client, queryID string) (<-chan *sdm.ReplayChunk, func() error) {
+	chunks := make(chan *sdm.ReplayChunk)
+	// The error is sent rather than shared, since the consumer may stop
+	// reading before the chunks channel is closed when ctx is done.
+	errc := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		errc <- listReplay(ctx, client, queryID, chunks)
+	}()
+	return chunks, func() error { return <-errc }
+}
+
+func listReplay(ctx context.Context, client *sdm.Client, queryID string, chunks chan<- *sdm.ReplayChunk) error {
+	replayParts, err := client.Replays().List(ctx, "id:?", queryID)
+	if err != nil {
+		return fmt.Errorf("failed to scan replay: %w", err)
+	}
+	for replayParts.Next() {
+		select {
+		case chunks <- replayParts.Value():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if err := replayParts.Err(); err != nil {
+		return fmt.Errorf("failed to iterate replay: %w", err)
+	}
+	return nil
+}
+
+// decryptReplay decrypts replay chunks with a pool of workers and emits them
+// in the order they were received. When c is nil the chunks are passed
+// through with their plaintext events.
+//
+// Each chunk gets a result channel that is queued, in order, before the chunk
+// is handed to a worker; the emitter waits on the queued channels one at a
+// time. The queue is bounded, so at most 2*workers chunks are in flight and a
+// slow consumer holds back the source instead of buffering the whole replay.
+func decryptReplay(ctx context.Context, c *querycrypt.Cipher, chunks <-chan *sdm.ReplayChunk, workers int) <-chan replayPart {
+	if workers < 1 {
+		workers = 1
+	}
+	type job struct {
+		part   replayPart
+		result chan replayPart
+	}
+	jobs := make(chan job)
+	queue := make(chan chan replayPart, 2*workers)
+	out := make(chan replayPart)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				j.part.Events, j.part.Err = decryptReplayChunk(c, j.part.Chunk)
+				j.result <- j.part
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+		defer close(jobs)
+		index := 0
+		for chunk := range chunks {
+			j := job{part: replayPart{Index: index, Chunk: chunk}, result: make(chan replayPart, 1)}
+			index++
+			select {
+			case queue <- j.result:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		for result := range queue {
+			var part replayPart
+			select {
+			case part = <-result:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- part:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// decryptReplayChunk returns the events of a single replay chunk.
+func decryptReplayChunk(c *querycrypt.Cipher, chunk *sdm.ReplayChunk) ([]*sdm.ReplayChunkEvent, error) {
+	if c == nil {
+		return chunk.Events, nil
+	}
+	partData, err := c.Decrypt(chunk.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt replay data: %w", err)
+	}
+	return parseReplayEvents(partData)
+}
+
+// parseReplayEvents unmarshals the decrypted event list of a replay chunk.
+// Durations are recorded in milliseconds.
+func parseReplayEvents(partData string) ([]*sdm.ReplayChunkEvent, error) {
+	var events []struct {
+		Data     []byte
+		Duration int64
+	}
+	if err := json.Unmarshal([]byte(partData), &events); err != nil {
+		return nil, fmt.Errorf("%w: %v", querycrypt.ErrInvalidJSON, err)
+	}
+	result := make([]*sdm.ReplayChunkEvent, 0, len(events))
+	for _, e := range events {
+		result = append(result, &sdm.ReplayChunkEvent{
+			Data:     e.Data,
+			Duration: time.Millisecond * time.Duration(e.Duration),
+		})
+	}
+	return result, nil
+}
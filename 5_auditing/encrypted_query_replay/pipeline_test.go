@@ -0,0 +1,157 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// BenchmarkDecryptReplay measures replay decryption throughput against a
+// generated replay, so no API keys or real recordings are needed. It
+// compares the serial approach of unwrapping the query key for every chunk
+// with the pipeline at several worker counts:
+//
+//	go test -run '^$' -bench DecryptReplay
+func BenchmarkDecryptReplay(b *testing.B) {
+	fixture, err := newReplayFixture(200, 50, 80)
+	if err != nil {
+		b.Fatalf("failed to build fixture replay: %v", err)
+	}
+
+	b.Run("serial key per chunk", func(b *testing.B) {
+		b.SetBytes(int64(fixture.size))
+		for i := 0; i < b.N; i++ {
+			for _, chunk := range fixture.chunks {
+				c, err := querycrypt.NewCipher(fixture.keys, fixture.queryKey, querycrypt.ZeroPadding)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := decryptReplayChunk(c, chunk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	for workers := 1; ; workers *= 2 {
+		if workers > runtime.NumCPU() {
+			workers = runtime.NumCPU()
+		}
+		b.Run(fmt.Sprintf("pipeline %v workers", workers), func(b *testing.B) {
+			b.SetBytes(int64(fixture.size))
+			for i := 0; i < b.N; i++ {
+				c, err := querycrypt.NewCipher(fixture.keys, fixture.queryKey, querycrypt.ZeroPadding)
+				if err != nil {
+					b.Fatal(err)
+				}
+				chunks := make(chan *sdm.ReplayChunk)
+				go func() {
+					defer close(chunks)
+					for _, chunk := range fixture.chunks {
+						chunks <- chunk
+					}
+				}()
+				next := 0
+				for part := range decryptReplay(context.Background(), c, chunks, workers) {
+					if part.Err != nil {
+						b.Fatal(part.Err)
+					}
+					if part.Index != next {
+						b.Fatalf("chunk %v emitted out of order, expected %v", part.Index, next)
+					}
+					next++
+				}
+			}
+		})
+		if workers == runtime.NumCPU() {
+			break
+		}
+	}
+}
+
+// replayFixture is an encrypted replay built the way StrongDM remote log
+// encryption writes one: a random AES-256 key wrapped with RSA-OAEP, and each
+// chunk's JSON event list zero padded and encrypted with AES-CBC.
+type replayFixture struct {
+	keys     querycrypt.Keyring
+	queryKey string
+	chunks   []*sdm.ReplayChunk
+	size     int
+}
+
+func newReplayFixture(numChunks, numEvents, eventSize int) (*replayFixture, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	symmetricKey := make([]byte, 32)
+	if _, err := rand.Read(symmetricKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &privateKey.PublicKey, symmetricKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(symmetricKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &replayFixture{
+		keys:     querycrypt.Keyring{privateKey},
+		queryKey: base64.StdEncoding.EncodeToString(wrapped),
+	}
+	type event struct {
+		Data     []byte
+		Duration int64
+	}
+	output := make([]byte, eventSize)
+	for i := range output {
+		output[i] = 'a' + byte(i%26)
+	}
+	for i := 0; i < numChunks; i++ {
+		events := make([]event, numEvents)
+		for j := range events {
+			events[j] = event{Data: output, Duration: 10}
+		}
+		plaintext, err := json.Marshal(events)
+		if err != nil {
+			return nil, err
+		}
+		if rem := len(plaintext) % aes.BlockSize; rem != 0 {
+			plaintext = append(plaintext, make([]byte, aes.BlockSize-rem)...)
+		}
+		data := make([]byte, aes.BlockSize+len(plaintext))
+		if _, err := rand.Read(data[:aes.BlockSize]); err != nil {
+			return nil, err
+		}
+		cipher.NewCBCEncrypter(block, data[:aes.BlockSize]).CryptBlocks(data[aes.BlockSize:], plaintext)
+		fixture.chunks = append(fixture.chunks, &sdm.ReplayChunk{Data: data})
+		fixture.size += len(data)
+	}
+	return fixture, nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+)
+
+// rekeyCommand re-encrypts a directory of relay log files for a new remote
+// log encryption key, so the old private key can be retired after a key
+// rotation. Each query's symmetric key is unwrapped with the old private
+// keys from SDM_LOG_PRIVATE_KEY_FILE and wrapped again with the new public
+// key. The query bodies and replay chunks are encrypted with the symmetric
+// key, so they are copied unchanged, as is every other entry; only the
+// queryKey of encrypted postStart entries differs in the output.
+//
+// Every encrypted query body and chunk is decrypted with the old keys
+// before anything is written, and nothing is written if any of them fails,
+// so no record is left that neither key can read. With -dry-run only that
+// check is made. The rewritten files go to a separate directory, which can
+// replace the original once it has been checked with the new private key:
+//
+//	encrypted_query_replay rekey -public-key new.pub -dry-run /var/log/sdm
+//	encrypted_query_replay rekey -public-key new.pub -out /var/log/sdm.rekeyed /var/log/sdm
+func rekeyCommand(args []string) {
+	flags := flag.NewFlagSet("rekey", flag.ExitOnError)
+	publicKeyPath := flags.String("public-key", "", "PEM file of the new RSA public key")
+	outDir := flags.String("out", "", "directory to write the re-encrypted relay logs to")
+	dryRun := flags.Bool("dry-run", false, "only check that every record decrypts with the old keys")
+	registerPaddingFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || *publicKeyPath == "" || (*outDir == "" && !*dryRun) {
+		log.Fatal("usage: encrypted_query_replay rekey -public-key file (-out directory | -dry-run) [-padding mode] <log directory>")
+	}
+	logDir := flags.Arg(0)
+	if !*dryRun {
+		if same, err := sameDir(logDir, *outDir); err != nil {
+			log.Fatalf("failed to check output directory: %v", err)
+		} else if same {
+			log.Fatal("-out must not be the log directory; the originals are kept until the output has been checked")
+		}
+	}
+
+	publicKey, err := querycrypt.LoadPublicKey(*publicKeyPath)
+	if err != nil {
+		log.Fatalf("failed to load public key: %v", err)
+	}
+	privateKeys := loadPrivateKeysFromEnv()
+
+	files, err := relayLogFiles(logDir)
+	if err != nil {
+		log.Fatalf("failed to list relay logs: %v", err)
+	}
+	queryKeys, err := collectQueryKeys(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Check every encrypted record and wrap each query key under the new
+	// public key.
+	var decrypted int
+	var failed corruptionReport
+	ciphers := newQueryCiphers(privateKeys, queryKeys)
+	newQueryKeys := map[string]string{}
+	for _, path := range files {
+		err := forEachLogEntry(path, func(entry logEntry) error {
+			uuid := entry.str("uuid")
+			if entry.str("type") == "postStart" && entry.str("queryKey") != "" {
+				if _, done := newQueryKeys[uuid]; !done {
+					c, err := ciphers.get(uuid)
+					if err != nil {
+						logEntryError(path, fmt.Errorf("query %v: %w", uuid, err))
+						failed.Add(err)
+						return nil
+					}
+					if newQueryKeys[uuid], err = querycrypt.WrapQueryKey(publicKey, c.Key()); err != nil {
+						return fmt.Errorf("failed to wrap key of query %v: %w", uuid, err)
+					}
+				}
+			}
+			// decryptLogEntry replaces the fields it decrypts, so give it a
+			// copy of the entry; the plaintext is only checked.
+			plaintext := logEntry{}
+			for field, value := range entry {
+				plaintext[field] = value
+			}
+			changed, err := decryptLogEntry(ciphers, plaintext)
+			if err != nil {
+				logEntryError(path, err)
+				failed.Add(err)
+			} else if changed {
+				decrypted++
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("failed to check %v: %v", path, err)
+		}
+	}
+	if failed.Total() > 0 {
+		log.Fatalf("%v entries could not be decrypted with the old keys (%v); nothing was written", failed.Total(), &failed)
+	}
+	log.Printf("Decrypted %v entries of %v encrypted queries from %v files", decrypted, len(newQueryKeys), len(files))
+	if *dryRun {
+		log.Printf("Dry run: every record decrypts with the old keys, nothing was written")
+		return
+	}
+
+	if err := os.MkdirAll(*outDir, 0o700); err != nil {
+		log.Fatalf("failed to create output directory: %v", err)
+	}
+	for _, path := range files {
+		if err := rekeyLogFile(path, filepath.Join(*outDir, filepath.Base(path)), newQueryKeys); err != nil {
+			log.Fatalf("failed to rewrite %v: %v", path, err)
+		}
+	}
+	log.Printf("Wrote %v files to %v. Check them with decrypt-logs and the new private key before replacing %v.", len(files), *outDir, logDir)
+}
+
+// rekeyLogFile copies a relay log file, replacing the query key of each
+// encrypted postStart entry with its new one. Only the value of queryKey
+// changes; every other byte is copied as it is. The copy is written to a
+// temporary file that is renamed to dst once it is complete, so an
+// interrupted run never leaves a partial dst behind.
+func rekeyLogFile(src, dst string, newQueryKeys map[string]string) error {
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	err = forEachRawLogEntry(src, func(raw json.RawMessage, entry logEntry) error {
+		if entry.str("type") == "postStart" && entry.str("queryKey") != "" {
+			queryKey, err := json.Marshal(newQueryKeys[entry.str("uuid")])
+			if err != nil {
+				return err
+			}
+			if raw, err = replaceField(raw, "queryKey", queryKey); err != nil {
+				return err
+			}
+		}
+		w.Write(raw)
+		return w.WriteByte('\n')
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+// replaceField returns a JSON object with the value of one of its top-level
+// fields replaced and the rest of its bytes unchanged.
+func replaceField(raw json.RawMessage, field string, value json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var old json.RawMessage
+		if err := dec.Decode(&old); err != nil {
+			return nil, err
+		}
+		if key == field {
+			end := int(dec.InputOffset())
+			return slices.Concat(raw[:end-len(old)], value, raw[end:]), nil
+		}
+	}
+	return nil, fmt.Errorf("no %v field", field)
+}
+
+// sameDir reports whether two paths name the same existing directory.
+func sameDir(a, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return os.SameFile(aInfo, bInfo), nil
+}
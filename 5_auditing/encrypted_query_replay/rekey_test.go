@@ -0,0 +1,112 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+)
+
+func TestRekeyLogFile(t *testing.T) {
+	l := newRelayLog(t)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadQueryCiphers(t, l.dir, l.keys).get("q-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newQueryKey, err := querycrypt.WrapQueryKey(&newKey.PublicKey, c.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	dst := filepath.Join(outDir, "relay.log")
+	// Output left by an earlier run is replaced.
+	if err := os.WriteFile(dst, []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rekeyLogFile(filepath.Join(l.dir, "relay.log"), dst, map[string]string{"q-1": newQueryKey}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the query key differs.
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(strings.Join(l.lines, "\n")+"\n", l.queryKey, newQueryKey, 1)
+	if string(data) != want {
+		t.Errorf("rekeyLogFile() wrote\n%s\nwant\n%s", data, want)
+	}
+	if files, _ := os.ReadDir(outDir); len(files) != 1 {
+		t.Errorf("rekeyLogFile() left %v files in the output directory, want 1", len(files))
+	}
+
+	// The rekeyed log decrypts with the new key to what the original
+	// decrypts to with the old one.
+	decrypt := func(dir string, keys querycrypt.Keyring) []string {
+		ciphers := loadQueryCiphers(t, dir, keys)
+		var lines []string
+		for _, entry := range readLogEntries(t, dir) {
+			if _, err := decryptLogEntry(ciphers, entry); err != nil {
+				t.Fatal(err)
+			}
+			line, err := json.Marshal(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, string(line))
+		}
+		return lines
+	}
+	before := decrypt(l.dir, l.keys)
+	after := decrypt(outDir, querycrypt.Keyring{newKey})
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("rekeyed log decrypts to\n%v\nwant\n%v", strings.Join(after, "\n"), strings.Join(before, "\n"))
+	}
+	if !strings.Contains(before[2], relayLogEvents) {
+		t.Errorf("decrypted chunk = %v, want the events %v", before[2], relayLogEvents)
+	}
+}
+
+func TestReplaceField(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`{"a":1,"queryKey":"old","b":2}`, `{"a":1,"queryKey":"new","b":2}`},
+		{`{ "queryKey" : "old" , "b" : {"queryKey":"nested"} }`, `{ "queryKey" : "new" , "b" : {"queryKey":"nested"} }`},
+		{`{"b":{"queryKey":"nested"},"queryKey":"old"}`, `{"b":{"queryKey":"nested"},"queryKey":"new"}`},
+	}
+	for _, tt := range tests {
+		got, err := replaceField(json.RawMessage(tt.raw), "queryKey", json.RawMessage(`"new"`))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("replaceField(%v) = %s, %v, want %v", tt.raw, got, err, tt.want)
+		}
+	}
+	if _, err := replaceField(json.RawMessage(`{"a":1}`), "queryKey", json.RawMessage(`"new"`)); err == nil {
+		t.Error("replaceField() of a missing field succeeded, want an error")
+	}
+}
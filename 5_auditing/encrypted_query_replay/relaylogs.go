@@ -0,0 +1,361 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+)
+
+// decryptLogsCommand decrypts a directory of relay log files, in the JSON
+// lines format described at https://www.strongdm.com/docs/admin/logs/references/,
+// and writes every entry as plaintext JSON lines. Query bodies of encrypted
+// postStart entries and the events of their chunks are decrypted; all other
+// entries are copied unchanged. Entries that fail to decrypt are copied as
+// they are and counted, so one damaged record doesn't stop the rest. Secrets
+// in the plaintext are masked on the way out.
+//
+//	encrypted_query_replay decrypt-logs -out decrypted.jsonl /var/log/sdm
+func decryptLogsCommand(args []string) {
+	flags := flag.NewFlagSet("decrypt-logs", flag.ExitOnError)
+	outPath := flags.String("out", "-", "file to write decrypted JSON lines to, - for stdout")
+	registerPaddingFlag(flags)
+	registerRedactFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("usage: encrypted_query_replay decrypt-logs [-out file] [-padding mode] [-redact-rules file] <log directory>")
+	}
+
+	privateKeys := loadPrivateKeysFromEnv()
+
+	files, err := relayLogFiles(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("failed to list relay logs: %v", err)
+	}
+
+	queryKeys, err := collectQueryKeys(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "-" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("failed to create output file: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	var decrypted int
+	var failed corruptionReport
+	ciphers := newQueryCiphers(privateKeys, queryKeys)
+	redactions := map[string]*redact.Session{}
+	for _, path := range files {
+		err := forEachLogEntry(path, func(entry logEntry) error {
+			changed, err := decryptLogEntry(ciphers, entry)
+			if err != nil {
+				logEntryError(path, err)
+				failed.Add(err)
+			} else if changed {
+				decrypted++
+			}
+			redactLogEntry(redactions, entry)
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			w.Write(line)
+			return w.WriteByte('\n')
+		})
+		if err != nil {
+			log.Fatalf("failed to decrypt %v: %v", path, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("failed to write output: %v", err)
+	}
+	log.Printf("Decrypted %v entries from %v files", decrypted, len(files))
+	uuids := make([]string, 0, len(redactions))
+	for uuid := range redactions {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	for _, uuid := range uuids {
+		if s := redactions[uuid]; s.Total() > 0 {
+			log.Printf("Query %v: %v", uuid, s)
+		}
+	}
+	if failed.Total() > 0 {
+		log.Printf("%v entries could not be decrypted (%v)", failed.Total(), &failed)
+		os.Exit(1)
+	}
+}
+
+// relayLogFiles returns the regular files in dir in name order, which for
+// relay logs is the order they were written in.
+func relayLogFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// collectQueryKeys returns the encrypted query key of every postStart entry
+// in files, by query UUID. Chunks only carry the query UUID, so the keys are
+// collected before any chunk is decrypted. This also copes with chunks that
+// were rotated into an earlier file than their postStart.
+func collectQueryKeys(files []string) (map[string]string, error) {
+	queryKeys := map[string]string{}
+	for _, path := range files {
+		err := forEachLogEntry(path, func(entry logEntry) error {
+			if queryKey := entry.str("queryKey"); queryKey != "" {
+				queryKeys[entry.str("uuid")] = queryKey
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %w", path, err)
+		}
+	}
+	return queryKeys, nil
+}
+
+// logEntry is a single relay log entry. Fields are kept as raw JSON so that
+// anything this example doesn't know about is written back out untouched.
+type logEntry map[string]json.RawMessage
+
+// str returns a field as a string, accepting both JSON strings and numbers.
+func (e logEntry) str(field string) string {
+	var s string
+	if err := json.Unmarshal(e[field], &s); err != nil {
+		return string(e[field])
+	}
+	return s
+}
+
+// forEachLogEntry calls fn for every JSON object in a relay log file.
+func forEachLogEntry(path string, fn func(logEntry) error) error {
+	return forEachRawLogEntry(path, func(_ json.RawMessage, entry logEntry) error {
+		return fn(entry)
+	})
+}
+
+// forEachRawLogEntry calls fn for every JSON object in a relay log file, with
+// the object exactly as it appears in the file.
+func forEachRawLogEntry(path string, fn func(json.RawMessage, logEntry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var entry logEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		if err := fn(raw, entry); err != nil {
+			return err
+		}
+	}
+}
+
+// decryptLogEntry replaces the encrypted fields of a postStart or chunk entry
+// with their plaintext and reports whether anything was decrypted.
+//
+// An encrypted postStart holds the base64 ciphertext of the query in "query"
+// and the wrapped symmetric key in "queryKey". An encrypted chunk holds the
+// base64 ciphertext of its event list in "events" instead of a JSON array.
+func decryptLogEntry(ciphers *queryCiphers, entry logEntry) (bool, error) {
+	uuid := entry.str("uuid")
+	switch entry.str("type") {
+	case "postStart":
+		var encrypted bool
+		json.Unmarshal(entry["encrypted"], &encrypted)
+		if !encrypted {
+			return false, nil
+		}
+		plaintext, err := decryptLogField(ciphers, uuid, entry.str("query"))
+		if err != nil {
+			return false, fmt.Errorf("query %v: %w", uuid, err)
+		}
+		entry["query"], _ = json.Marshal(plaintext)
+		entry["encrypted"] = json.RawMessage("false")
+		delete(entry, "queryKey")
+		return true, nil
+
+	case "chunk":
+		var ciphertext string
+		if err := json.Unmarshal(entry["events"], &ciphertext); err != nil {
+			// Already a plaintext event list.
+			return false, nil
+		}
+		plaintext, err := decryptLogField(ciphers, uuid, ciphertext)
+		if err != nil {
+			return false, fmt.Errorf("chunk %v of query %v: %w", entry.str("chunkId"), uuid, err)
+		}
+		if !json.Valid([]byte(plaintext)) {
+			return false, fmt.Errorf("chunk %v of query %v: %w", entry.str("chunkId"), uuid, querycrypt.ErrInvalidJSON)
+		}
+		entry["events"] = json.RawMessage(plaintext)
+		return true, nil
+	}
+	return false, nil
+}
+
+// redactLogEntry masks secrets in the query of a postStart entry and in the
+// event data of a chunk, counting them by query. Entries that are still
+// encrypted are left alone. A chunk can't be changed once it is written, so
+// the redaction stream is flushed at the end of each one and a secret split
+// across two chunks may be missed.
+func redactLogEntry(redactions map[string]*redact.Session, entry logEntry) {
+	uuid := entry.str("uuid")
+	session := func() *redact.Session {
+		s := redactions[uuid]
+		if s == nil {
+			s = redactor.Session()
+			redactions[uuid] = s
+		}
+		return s
+	}
+
+	switch entry.str("type") {
+	case "postStart":
+		var encrypted bool
+		json.Unmarshal(entry["encrypted"], &encrypted)
+		if encrypted || entry["query"] == nil {
+			return
+		}
+		entry["query"], _ = json.Marshal(session().Text(entry.str("query")))
+
+	case "chunk":
+		var events []map[string]json.RawMessage
+		if err := json.Unmarshal(entry["events"], &events); err != nil {
+			return
+		}
+		stream := session().Stream()
+		last := ""
+		var lastEvent map[string]json.RawMessage
+		for _, ev := range events {
+			for key, value := range ev {
+				if !strings.EqualFold(key, "data") {
+					continue
+				}
+				var data []byte
+				if err := json.Unmarshal(value, &data); err != nil {
+					continue
+				}
+				ev[key], _ = json.Marshal(stream.Write(data))
+				last, lastEvent = key, ev
+			}
+		}
+		if lastEvent != nil {
+			var data []byte
+			json.Unmarshal(lastEvent[last], &data)
+			lastEvent[last], _ = json.Marshal(append(data, stream.Flush()...))
+		}
+		entry["events"], _ = json.Marshal(events)
+	}
+}
+
+// queryCiphers unwraps the symmetric key of each query the first time one of
+// its entries is decrypted. A key that fails to unwrap is not tried again;
+// the later entries of its query get a repeatedError.
+type queryCiphers struct {
+	privateKeys querycrypt.Keyring
+	queryKeys   map[string]string
+	byUUID      map[string]*querycrypt.Cipher
+	errs        map[string]error
+}
+
+func newQueryCiphers(privateKeys querycrypt.Keyring, queryKeys map[string]string) *queryCiphers {
+	return &queryCiphers{
+		privateKeys: privateKeys,
+		queryKeys:   queryKeys,
+		byUUID:      map[string]*querycrypt.Cipher{},
+		errs:        map[string]error{},
+	}
+}
+
+func (qc *queryCiphers) get(uuid string) (*querycrypt.Cipher, error) {
+	if c, ok := qc.byUUID[uuid]; ok {
+		return c, nil
+	}
+	if err, ok := qc.errs[uuid]; ok {
+		return nil, repeatedError{err}
+	}
+	var c *querycrypt.Cipher
+	err := errors.New("no query key found in the postStart entry")
+	if queryKey := qc.queryKeys[uuid]; queryKey != "" {
+		c, err = querycrypt.NewCipher(qc.privateKeys, queryKey, padding)
+	}
+	if err != nil {
+		qc.errs[uuid] = err
+		return nil, err
+	}
+	qc.byUUID[uuid] = c
+	return c, nil
+}
+
+// repeatedError is the error of an entry whose query key already failed to
+// unwrap for an earlier entry.
+type repeatedError struct{ error }
+
+func (e repeatedError) Unwrap() error { return e.error }
+
+// logEntryError logs an entry of a relay log file that failed to decrypt,
+// unless its query key failed for an earlier entry and was logged then.
+func logEntryError(path string, err error) {
+	if !errors.As(err, new(repeatedError)) {
+		log.Printf("%v: %v", path, err)
+	}
+}
+
+func decryptLogField(ciphers *queryCiphers, uuid, ciphertext string) (string, error) {
+	c, err := ciphers.get(uuid)
+	if err != nil {
+		return "", err
+	}
+	return c.DecryptBase64(ciphertext)
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+)
+
+// relayLog is an encrypted relay log written the way the relay writes one,
+// with one query recorded under its own symmetric key.
+type relayLog struct {
+	dir      string
+	keys     querycrypt.Keyring
+	queryKey string // wrapped symmetric key of query q-1
+	lines    []string
+}
+
+// Events of the chunk of query q-1.
+const relayLogEvents = `[{"data":"JCBpZA0K","duration":10},{"data":"dWlkPTAK","duration":20}]`
+
+func newRelayLog(t *testing.T) *relayLog {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symmetricKey := make([]byte, 32)
+	rand.Read(symmetricKey)
+	queryKey, err := querycrypt.WrapQueryKey(&privateKey.PublicKey, symmetricKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &relayLog{dir: t.TempDir(), keys: querycrypt.Keyring{privateKey}, queryKey: queryKey}
+	// Fields are in the relay's order rather than sorted, and the target
+	// holds characters that json.Marshal would escape.
+	l.lines = []string{
+		`{"type":"start","timestamp":"2025-06-01T00:00:00Z","relayId":"n-1"}`,
+		`{"type":"postStart","uuid":"q-1","query":"` + encryptField(t, symmetricKey, `{"type":"shell","command":"id"}`) +
+			`","queryKey":"` + queryKey + `","encrypted":true,"target":"<db> & co"}`,
+		`{"type":"chunk","uuid":"q-1","chunkId":1,"events":"` + encryptField(t, symmetricKey, relayLogEvents) + `"}`,
+		`{"type":"postStart","uuid":"q-2","query":"SELECT 1","encrypted":false}`,
+	}
+	l.write(t, "relay.log", l.lines)
+	return l
+}
+
+func (l *relayLog) write(t *testing.T, name string, lines []string) {
+	if err := os.WriteFile(filepath.Join(l.dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// encryptField encrypts plaintext the way remote log encryption does: a
+// random IV, then the zero padded plaintext in AES-CBC, base64 encoded.
+func encryptField(t *testing.T, key []byte, plaintext string) string {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded := []byte(plaintext)
+	if rem := len(padded) % aes.BlockSize; rem != 0 {
+		padded = append(padded, make([]byte, aes.BlockSize-rem)...)
+	}
+	data := make([]byte, aes.BlockSize+len(padded))
+	rand.Read(data[:aes.BlockSize])
+	cipher.NewCBCEncrypter(block, data[:aes.BlockSize]).CryptBlocks(data[aes.BlockSize:], padded)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// readLogEntries reads every entry of the relay logs in dir.
+func readLogEntries(t *testing.T, dir string) []logEntry {
+	files, err := relayLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []logEntry
+	for _, path := range files {
+		err := forEachLogEntry(path, func(entry logEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return entries
+}
+
+// loadQueryCiphers returns the ciphers of the queries in dir, unwrapped with
+// keys.
+func loadQueryCiphers(t *testing.T, dir string, keys querycrypt.Keyring) *queryCiphers {
+	files, err := relayLogFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryKeys, err := collectQueryKeys(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newQueryCiphers(keys, queryKeys)
+}
+
+func parseLogEntry(t *testing.T, line string) logEntry {
+	var entry logEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestDecryptLogEntry(t *testing.T) {
+	l := newRelayLog(t)
+	c, err := loadQueryCiphers(t, l.dir, l.keys).get("q-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notJSON := `{"type":"chunk","uuid":"q-1","chunkId":2,"events":"` + encryptField(t, c.Key(), "[{") + `"}`
+	tests := []struct {
+		name    string
+		line    string
+		want    string
+		changed bool
+		err     error
+		wantErr bool
+	}{
+		{
+			name:    "encrypted postStart",
+			line:    l.lines[1],
+			want:    `{"type":"postStart","uuid":"q-1","query":"{\"type\":\"shell\",\"command\":\"id\"}","encrypted":false,"target":"<db> & co"}`,
+			changed: true,
+		},
+		{
+			name:    "encrypted chunk",
+			line:    l.lines[2],
+			want:    `{"type":"chunk","uuid":"q-1","chunkId":1,"events":` + relayLogEvents + `}`,
+			changed: true,
+		},
+		{name: "plaintext postStart", line: l.lines[3]},
+		{name: "plaintext chunk", line: `{"type":"chunk","uuid":"q-2","chunkId":1,"events":` + relayLogEvents + `}`},
+		{name: "other entry", line: l.lines[0]},
+		{
+			name:    "ciphertext not base64",
+			line:    `{"type":"chunk","uuid":"q-1","chunkId":2,"events":"not base64!"}`,
+			err:     querycrypt.ErrInvalidEncoding,
+			wantErr: true,
+		},
+		{name: "events not JSON", line: notJSON, err: querycrypt.ErrInvalidJSON, wantErr: true},
+		{
+			name:    "no query key",
+			line:    `{"type":"chunk","uuid":"q-3","chunkId":1,"events":"` + encryptField(t, c.Key(), relayLogEvents) + `"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = tt.line
+			}
+			entry := parseLogEntry(t, tt.line)
+			changed, err := decryptLogEntry(loadQueryCiphers(t, l.dir, l.keys), entry)
+			if (err != nil) != tt.wantErr || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("decryptLogEntry() error = %v, want %v (error %v)", err, tt.err, tt.wantErr)
+			}
+			if changed != tt.changed {
+				t.Errorf("decryptLogEntry() = %v, want %v", changed, tt.changed)
+			}
+			if !reflect.DeepEqual(entry, parseLogEntry(t, want)) {
+				t.Errorf("decryptLogEntry() left %s, want %s", mustMarshal(t, entry), want)
+			}
+		})
+	}
+}
+
+func TestDecryptLogEntryWrongKey(t *testing.T) {
+	l := newRelayLog(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphers := loadQueryCiphers(t, l.dir, querycrypt.Keyring{otherKey})
+
+	// The first entry of the query reports the unwrap error, and the later
+	// ones repeat it without trying the key again.
+	_, err = decryptLogEntry(ciphers, parseLogEntry(t, l.lines[1]))
+	if !errors.Is(err, querycrypt.ErrWrongKey) || errors.As(err, new(repeatedError)) {
+		t.Fatalf("decryptLogEntry() of the postStart = %v, want %v", err, querycrypt.ErrWrongKey)
+	}
+	ciphers.privateKeys = l.keys
+	_, err = decryptLogEntry(ciphers, parseLogEntry(t, l.lines[2]))
+	if !errors.Is(err, querycrypt.ErrWrongKey) || !errors.As(err, new(repeatedError)) {
+		t.Errorf("decryptLogEntry() of the chunk = %v, want a repeated %v", err, querycrypt.ErrWrongKey)
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,28 @@
+module github.com/strongdm/strongdm-sdk-go-examples/5_auditing/export_queries
+
+go 1.24.5
+
+require (
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact v0.0.0
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/siem v0.0.0
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache v0.0.0
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag v0.0.0
+	github.com/strongdm/strongdm-sdk-go/v15 v15.21.0
+)
+
+require (
+	github.com/golang/protobuf v1.5.0 // indirect
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a // indirect
+	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
+	google.golang.org/grpc v1.33.1 // indirect
+	google.golang.org/protobuf v1.26.0 // indirect
+)
+
+replace (
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact => ../redact
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/siem => ../siem
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache => ../snapshotcache
+	github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag => ../timeflag
+)
@@ -0,0 +1,308 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/siem"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Exports the queries made in a time range as CSV or JSON lines, resolving
+// the account and resource of each query as they were when it was made.
+// Keys, tokens and passwords in commands are redacted:
+//
+//	export_queries -from 2025-01-01T00:00:00Z -to 2025-02-01T00:00:00Z -format csv -out january.csv
+func main() {
+	log.SetFlags(0)
+	from := flag.String("from", "", "start of the time range, RFC 3339 or a duration ago such as 24h (default 24h)")
+	to := flag.String("to", "", "end of the time range, RFC 3339 or a duration ago (default now)")
+	filter := flag.String("filter", "", "additional query filter, for example resource_id:rs-1234")
+	format := flag.String("format", "jsonl", "output format: csv, jsonl, or cef, leef or ocsf for SIEMs")
+	outPath := flag.String("out", "-", "file to write to, - for stdout, or a syslog server such as udp://host:514 or unix:///dev/log")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
+	now := time.Now()
+	start, err := timeflag.Parse(*from, now, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end, err := timeflag.Parse(*to, now, now)
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	out, err := siem.OpenOutput(*outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		log.Fatalf("failed to open output: %v", err)
+	}
+	defer out.Close()
+	var w recordWriter
+	switch *format {
+	case "csv":
+		w = newCSVWriter(out)
+	case "jsonl":
+		w = newJSONLWriter(out)
+	default:
+		siemFormat, err := siem.ParseFormat(*format)
+		if err != nil {
+			log.Fatalf("unknown format %q, expected csv, jsonl, cef, leef or ocsf", *format)
+		}
+		w = &siemWriter{w: out, format: siemFormat}
+	}
+
+	identities := &identityCache{cache: snapshotcache.New(client, 0)}
+	queryFilter := strings.TrimSpace("after:? before:? " + *filter)
+	queries, err := client.Queries().List(ctx, queryFilter, start, end)
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+	count, redacted := 0, 0
+	for queries.Next() {
+		q := queries.Value()
+		redactions := redactor.Session()
+		rec := queryRecord{
+			Timestamp:    q.Timestamp,
+			ID:           q.ID,
+			AccountID:    q.AccountID,
+			AccountEmail: identities.AccountName(ctx, q.AccountID, q.Timestamp, q.AccountEmail),
+			ResourceID:   q.ResourceID,
+			ResourceName: identities.ResourceName(ctx, q.ResourceID, q.Timestamp, q.ResourceName),
+			ResourceType: q.ResourceType,
+			Duration:     q.Duration,
+			Encrypted:    q.Encrypted,
+			Command:      redactions.Text(parseCommand(q)),
+			query:        q,
+		}
+		if redactions.Total() > 0 {
+			log.Printf("Query %v: %v", q.ID, redactions)
+			redacted++
+		}
+		if err := w.Write(rec); err != nil {
+			log.Fatalf("failed to write query %v: %v", q.ID, err)
+		}
+		count++
+	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("failed to write output: %v", err)
+	}
+	log.Printf("Exported %v queries from %v to %v with %v identity lookups, %v of them redacted",
+		count, start.Format(time.RFC3339), end.Format(time.RFC3339), identities.cache.Stats().Loads, redacted)
+}
+
+// parseCommand extracts what was run from a query. Shell and Kubernetes
+// captures record the command separately; other clients send a JSON body with
+// a command field, and datasources such as Postgres record the statement text
+// itself. Encrypted bodies are left out.
+func parseCommand(q *sdm.Query) string {
+	if q.Encrypted {
+		return ""
+	}
+	if q.Capture != nil && q.Capture.Command != "" {
+		return q.Capture.Command
+	}
+	var capture struct{ Command string }
+	if err := json.Unmarshal([]byte(q.QueryBody), &capture); err == nil {
+		return capture.Command
+	}
+	return q.QueryBody
+}
+
+// queryRecord is one exported row.
+type queryRecord struct {
+	Timestamp    time.Time     `json:"timestamp"`
+	ID           string        `json:"id"`
+	AccountID    string        `json:"accountId"`
+	AccountEmail string        `json:"accountEmail"`
+	ResourceID   string        `json:"resourceId"`
+	ResourceName string        `json:"resourceName"`
+	ResourceType string        `json:"resourceType"`
+	Duration     time.Duration `json:"-"`
+	Encrypted    bool          `json:"encrypted"`
+	Command      string        `json:"command"`
+
+	query *sdm.Query
+}
+
+// MarshalJSON writes the duration in milliseconds rather than nanoseconds.
+func (r queryRecord) MarshalJSON() ([]byte, error) {
+	type plain queryRecord
+	return json.Marshal(struct {
+		plain
+		DurationMs int64 `json:"durationMs"`
+	}{plain(r), r.Duration.Milliseconds()})
+}
+
+type recordWriter interface {
+	Write(queryRecord) error
+	Flush() error
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(r queryRecord) error {
+	if !c.header {
+		c.header = true
+		err := c.w.Write([]string{"timestamp", "id", "account_id", "account_email", "resource_id",
+			"resource_name", "resource_type", "duration_ms", "encrypted", "command"})
+		if err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{
+		r.Timestamp.Format(time.RFC3339Nano),
+		r.ID,
+		r.AccountID,
+		r.AccountEmail,
+		r.ResourceID,
+		r.ResourceName,
+		r.ResourceType,
+		strconv.FormatInt(r.Duration.Milliseconds(), 10),
+		strconv.FormatBool(r.Encrypted),
+		r.Command,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{enc: enc}
+}
+
+func (j *jsonlWriter) Write(r queryRecord) error {
+	return j.enc.Encode(r)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
+
+// siemWriter writes each record as a CEF, LEEF or OCSF event. The account
+// and resource names resolved from snapshots replace the ones recorded on the
+// query.
+type siemWriter struct {
+	w      io.Writer
+	format siem.Format
+}
+
+func (s *siemWriter) Write(r queryRecord) error {
+	event := siem.QueryEvent(r.query)
+	event.Actor.Email = r.AccountEmail
+	event.Targets[0].Name = r.ResourceName
+	event.Message = "query on " + r.ResourceName
+	event.Command = r.Command
+	line, err := s.format.Format(event)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(s.w, line+"\n")
+	return err
+}
+
+func (s *siemWriter) Flush() error {
+	return nil
+}
+
+// identityCache resolves account and resource names as of a point in time.
+// The snapshot cache loads the history of each account and resource once, so
+// resolving names costs an API call per entity instead of per row.
+type identityCache struct {
+	cache *snapshotcache.Cache
+}
+
+// AccountName returns the email of a user, or the name of a service account,
+// at time at. If the history can't be read, fallback is used.
+func (c *identityCache) AccountName(ctx context.Context, id string, at time.Time, fallback string) string {
+	if id == "" {
+		return fallback
+	}
+	account, err := c.cache.Account(ctx, id, at)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to look up %v at %v: %v\n", id, at.Format(time.RFC3339), err)
+		return fallback
+	}
+	return snapshotcache.AccountName(account)
+}
+
+// ResourceName returns the name of a resource at time at. If the history
+// can't be read, fallback is used.
+func (c *identityCache) ResourceName(ctx context.Context, id string, at time.Time, fallback string) string {
+	if id == "" {
+		return fallback
+	}
+	resource, err := c.cache.Resource(ctx, id, at)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to look up %v at %v: %v\n", id, at.Format(time.RFC3339), err)
+		return fallback
+	}
+	return resource.GetName()
+}
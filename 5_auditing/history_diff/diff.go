@@ -0,0 +1,134 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+)
+
+// typeField holds the concrete type of a snapshot, so that changing a
+// resource from one type to another shows up as a change.
+const typeField = "(type)"
+
+// flatten turns a snapshot into a map from field path, such as Name,
+// Tags.env or AccessRules[0].Type, to a printable value. Zero values are left
+// out, so a field that is cleared shows up as removed.
+func flatten(v interface{}) map[string]string {
+	fields := map[string]string{}
+	if v == nil {
+		return fields
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fields
+	}
+	fields[typeField] = reflect.Indirect(rv).Type().Name()
+	flattenValue(rv, "", fields)
+	return fields
+}
+
+func flattenValue(v reflect.Value, path string, fields map[string]string) {
+	if !v.IsValid() || v.IsZero() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		flattenValue(v.Elem(), path, fields)
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			fields[path] = t.UTC().Format(time.RFC3339)
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				flattenValue(v.Field(i), joinPath(path, f.Name), fields)
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			flattenValue(v.MapIndex(key), joinPath(path, fmt.Sprint(key.Interface())), fields)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			fields[path] = strconv.Quote(string(v.Bytes()))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			flattenValue(v.Index(i), fmt.Sprintf("%v[%v]", path, i), fields)
+		}
+	case reflect.String:
+		fields[path] = strconv.Quote(v.String())
+	default:
+		fields[path] = fmt.Sprint(v.Interface())
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// fieldChange is a difference in one field between two snapshots.
+type fieldChange struct {
+	Path   string
+	Before string // empty if the field was added
+	After  string // empty if the field was removed
+}
+
+func (c fieldChange) String() string {
+	before, after := c.Before, c.After
+	if redact.IsSecretField(c.Path) {
+		// Secrets are compared but never printed.
+		if before != "" {
+			before = redact.Redacted
+		}
+		if after != "" {
+			after = redact.Redacted
+		}
+	}
+	switch {
+	case c.Before == "":
+		return fmt.Sprintf("+ %v: %v", c.Path, after)
+	case c.After == "":
+		return fmt.Sprintf("- %v: %v", c.Path, before)
+	default:
+		return fmt.Sprintf("~ %v: %v -> %v", c.Path, before, after)
+	}
+}
+
+// diff returns the fields that differ between two flattened snapshots, in
+// path order.
+func diff(before, after map[string]string) []fieldChange {
+	var changes []fieldChange
+	for path, b := range before {
+		if a := after[path]; a != b {
+			changes = append(changes, fieldChange{Path: path, Before: b, After: a})
+		}
+	}
+	for path, a := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, fieldChange{Path: path, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
@@ -0,0 +1,37 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"strings"
+)
+
+// idPrefixes maps the prefix of an ID to the kind of entity it identifies.
+// Groups have no distinctive prefix and need -kind.
+var idPrefixes = map[string]string{
+	"a-":  "account",
+	"rs-": "resource",
+	"r-":  "role",
+	"n-":  "node",
+	"po-": "policy",
+	"aw-": "workflow",
+	"af-": "approval-workflow",
+}
+
+func kindOf(id string) string {
+	if i := strings.IndexByte(id, '-'); i > 0 {
+		return idPrefixes[id[:i+1]]
+	}
+	return ""
+}
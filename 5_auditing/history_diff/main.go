@@ -0,0 +1,134 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Prints what changed, field by field, each time an entity was created,
+// updated or deleted. Secrets such as passwords are reported as changed but
+// their values are never printed:
+//
+//	history_diff -id rs-1234567890abcdef -from 720h
+func main() {
+	log.SetFlags(0)
+	id := flag.String("id", "", "ID of the account, resource, role, group, node, policy, workflow or approval workflow")
+	kind := flag.String("kind", "", "kind of entity, guessed from the ID prefix if not set: "+strings.Join(snapshotcache.Kinds(), ", "))
+	from := flag.String("from", "", "only show changes after this time, RFC 3339 or a duration ago such as 24h")
+	to := flag.String("to", "", "only show changes before this time, RFC 3339 or a duration ago")
+	flag.Parse()
+	if *id == "" {
+		log.Fatal("usage: history_diff -id <entity id> [-kind kind] [-from time] [-to time]")
+	}
+
+	now := time.Now()
+	start, err := timeflag.Parse(*from, now, time.Time{})
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end, err := timeflag.Parse(*to, now, now)
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+	if *kind == "" {
+		*kind = kindOf(*id)
+	}
+	if !slices.Contains(snapshotcache.Kinds(), *kind) {
+		log.Fatalf("can't tell what kind of entity %v is, set -kind to one of %v", *id, strings.Join(snapshotcache.Kinds(), ", "))
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// The whole history is read once, so it isn't worth caching.
+	versions, err := snapshotcache.History(ctx, client, *kind, *id)
+	if err != nil {
+		log.Fatalf("failed to list %v history: %v", *kind, err)
+	}
+	if len(versions) == 0 {
+		log.Fatalf("no history found for %v %v", *kind, *id)
+	}
+
+	// Every version is compared with the one before it, even if that one is
+	// outside the time range, so the first change shown is still a diff.
+	previous := map[string]string{}
+	for _, v := range versions {
+		current := flatten(v.Value)
+		changes := diff(previous, current)
+		previous = current
+		if v.At.Before(start) || v.At.After(end) {
+			continue
+		}
+
+		fmt.Println(describe(ctx, client, v))
+		if !v.DeletedAt.IsZero() {
+			continue
+		}
+		if len(changes) == 0 {
+			fmt.Println("  (no field changes)")
+		}
+		for _, change := range changes {
+			fmt.Printf("  %v\n", change)
+		}
+	}
+}
+
+// describe returns a heading for a version: when it was recorded, who made
+// the change and the activity's description of it.
+func describe(ctx context.Context, client *sdm.Client, v snapshotcache.Version) string {
+	at := v.At
+	if !v.DeletedAt.IsZero() {
+		at = v.DeletedAt
+	}
+	heading := at.UTC().Format(time.RFC3339)
+	if v.ActivityID == "" {
+		return heading
+	}
+	resp, err := client.Activities().Get(ctx, v.ActivityID)
+	if err != nil {
+		return fmt.Sprintf("%v (failed to look up activity %v: %v)", heading, v.ActivityID, err)
+	}
+	if actor := resp.Activity.Actor; actor != nil && actor.Email != "" {
+		heading += " " + actor.Email
+	}
+	return heading + ": " + resp.Activity.Description
+}
@@ -0,0 +1,291 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Summarizes the requests made to Kubernetes resources such as AmazonEKS
+// clusters. kubectl exec and attach sessions are listed with the pod and
+// command, and can be replayed with ssh_replay or replay_player. Every other
+// request is an API call, counted by verb, namespace and object, and the
+// calls that changed the cluster are listed with when they were made:
+//
+//	k8s_activity -from 168h
+//	k8s_activity -from 24h -filter resource_id:rs-1234 -json
+func main() {
+	log.SetFlags(0)
+	from := flag.String("from", "", "start of the time range, RFC 3339 or a duration ago such as 24h (default 24h)")
+	to := flag.String("to", "", "end of the time range, RFC 3339 or a duration ago (default now)")
+	filter := flag.String("filter", "", "additional query filter, for example resource_id:rs-1234")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+	now := time.Now()
+	start, err := timeflag.Parse(*from, now, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end, err := timeflag.Parse(*to, now, now)
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	queryFilter := strings.TrimSpace("after:? before:? " + *filter)
+	queries, err := client.Queries().List(ctx, queryFilter, start, end)
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+	r := newReport()
+	for queries.Next() {
+		q := queries.Value()
+		if !kube.IsKubernetes(q) {
+			continue
+		}
+		if q.Encrypted {
+			r.Encrypted++
+			continue
+		}
+		r.Add(q, redactor.Session())
+	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+	r.Finish()
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Fatalf("failed to write report: %v", err)
+		}
+		return
+	}
+	r.Print()
+	if r.Encrypted > 0 {
+		fmt.Printf("\nSkipped %v encrypted queries. See encrypted_query_replay for an example of query decryption.\n", r.Encrypted)
+	}
+}
+
+// session is a kubectl exec or attach session.
+type session struct {
+	Timestamp  time.Time `json:"timestamp"`
+	QueryID    string    `json:"queryId"`
+	Account    string    `json:"account"`
+	Resource   string    `json:"resource"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Pod        string    `json:"pod"`
+	Container  string    `json:"container,omitempty"`
+	Command    string    `json:"command"`
+	DurationMs int64     `json:"durationMs"`
+	Replayable bool      `json:"replayable"`
+}
+
+// callCount is how many times a kind of call was made.
+type callCount struct {
+	Verb      string   `json:"verb"`
+	Namespace string   `json:"namespace,omitempty"`
+	Object    string   `json:"object"`
+	Calls     int      `json:"calls"`
+	Accounts  []string `json:"accounts"`
+
+	accounts map[string]bool
+}
+
+// change is an API call that changed the cluster.
+type change struct {
+	Timestamp time.Time `json:"timestamp"`
+	QueryID   string    `json:"queryId"`
+	Account   string    `json:"account"`
+	Resource  string    `json:"resource"`
+	Call      string    `json:"call"`
+}
+
+type report struct {
+	Sessions []session    `json:"sessions"`
+	Calls    []*callCount `json:"calls"`
+	Changes  []change     `json:"changes"`
+	// Unparsed counts API calls whose request wasn't captured.
+	Unparsed  int `json:"unparsed"`
+	Encrypted int `json:"encrypted"`
+
+	counts map[[3]string]*callCount
+}
+
+func newReport() *report {
+	return &report{
+		Sessions: []session{},
+		Changes:  []change{},
+		counts:   map[[3]string]*callCount{},
+	}
+}
+
+// Add records a query against a Kubernetes resource, with the command and
+// call masked by redactions.
+func (r *report) Add(q *sdm.Query, redactions *redact.Session) {
+	c := kube.CaptureOf(q)
+	if c == nil {
+		r.Unparsed++
+		return
+	}
+	call, callErr := kube.ParseCall(c.RequestMethod, c.RequestURI)
+	if c.IsSession() {
+		s := session{
+			Timestamp:  q.Timestamp,
+			QueryID:    q.ID,
+			Account:    q.AccountEmail,
+			Resource:   q.ResourceName,
+			Pod:        c.Pod,
+			Command:    c.Command,
+			DurationMs: q.Duration.Milliseconds(),
+			Replayable: q.Replayable,
+		}
+		if callErr == nil {
+			s.Namespace, s.Container = call.Namespace, call.Container
+			if s.Pod == "" {
+				s.Pod = call.Name
+			}
+			if s.Command == "" {
+				s.Command = strings.Join(call.Command, " ")
+			}
+		}
+		s.Command = redactions.Text(s.Command)
+		r.Sessions = append(r.Sessions, s)
+		return
+	}
+	if callErr != nil {
+		r.Unparsed++
+		return
+	}
+
+	key := [3]string{call.Verb, call.Namespace, call.Object()}
+	count := r.counts[key]
+	if count == nil {
+		count = &callCount{Verb: call.Verb, Namespace: call.Namespace, Object: call.Object(), accounts: map[string]bool{}}
+		r.counts[key] = count
+	}
+	count.Calls++
+	count.accounts[q.AccountEmail] = true
+	if call.Mutating() {
+		r.Changes = append(r.Changes, change{
+			Timestamp: q.Timestamp,
+			QueryID:   q.ID,
+			Account:   q.AccountEmail,
+			Resource:  q.ResourceName,
+			Call:      redactions.Text(call.String()),
+		})
+	}
+}
+
+// Finish sorts the report: calls by how often they were made, sessions and
+// changes by time.
+func (r *report) Finish() {
+	r.Calls = []*callCount{}
+	for _, count := range r.counts {
+		count.Accounts = nil
+		for account := range count.accounts {
+			count.Accounts = append(count.Accounts, account)
+		}
+		sort.Strings(count.Accounts)
+		r.Calls = append(r.Calls, count)
+	}
+	sort.Slice(r.Calls, func(i, j int) bool {
+		a, b := r.Calls[i], r.Calls[j]
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return a.Verb+" "+a.Object+" "+a.Namespace < b.Verb+" "+b.Object+" "+b.Namespace
+	})
+	sort.SliceStable(r.Sessions, func(i, j int) bool { return r.Sessions[i].Timestamp.Before(r.Sessions[j].Timestamp) })
+	sort.SliceStable(r.Changes, func(i, j int) bool { return r.Changes[i].Timestamp.Before(r.Changes[j].Timestamp) })
+}
+
+func (r *report) Print() {
+	fmt.Printf("Exec and attach sessions (%v):\n", len(r.Sessions))
+	for _, s := range r.Sessions {
+		pod := s.Pod
+		if s.Namespace != "" {
+			pod = s.Namespace + "/" + pod
+		}
+		if s.Container != "" {
+			pod += " -c " + s.Container
+		}
+		replay := ""
+		if s.Replayable {
+			replay = ", replayable"
+		}
+		fmt.Printf("  %v  %v on %v  %v -- %v (query %v, %v%v)\n", s.Timestamp.UTC().Format(time.RFC3339), s.Account,
+			s.Resource, pod, s.Command, s.QueryID, (time.Duration(s.DurationMs) * time.Millisecond).Round(time.Second), replay)
+	}
+
+	fmt.Printf("\nAPI calls (%v kinds):\n", len(r.Calls))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  VERB\tNAMESPACE\tOBJECT\tCALLS\tACCOUNTS")
+	for _, c := range r.Calls {
+		namespace := c.Namespace
+		if namespace == "" {
+			namespace = "-"
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%v\t%v\t%v\n", c.Verb, namespace, c.Object, c.Calls, strings.Join(c.Accounts, ", "))
+	}
+	w.Flush()
+	if r.Unparsed > 0 {
+		fmt.Printf("  %v calls had no request recorded\n", r.Unparsed)
+	}
+
+	fmt.Printf("\nChanges to the cluster (%v):\n", len(r.Changes))
+	for _, c := range r.Changes {
+		fmt.Printf("  %v  %v on %v  %v (query %v)\n", c.Timestamp.UTC().Format(time.RFC3339), c.Account, c.Resource, c.Call, c.QueryID)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kube recognizes queries made against Kubernetes clusters, such as
+// AmazonEKS, GoogleGKE, AKS and plain Kubernetes resources.
+//
+// Interactive sessions opened with kubectl exec or kubectl attach are
+// replayable like SSH sessions, and a Stream turns their recorded frames into
+// terminal output. Every other request is an API call, which ParseCall
+// summarizes as a verb, a namespace and an object.
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Category is the query category of requests to Kubernetes resources.
+const Category = "k8s"
+
+// Capture types of Kubernetes sessions. Exec sessions with a TTY are
+// recorded as execTTY.
+const (
+	CaptureExec    = "k8s-exec"
+	CaptureExecTTY = "k8s-execTTY"
+	CaptureAttach  = "k8s-attach"
+)
+
+// Capture is the part of a query capture that describes a Kubernetes
+// request.
+type Capture struct {
+	Type          string
+	Command       string
+	Pod           string
+	RequestMethod string
+	RequestURI    string
+	RequestBody   []byte
+}
+
+// CaptureOf returns the capture of a query. Queries read from the API carry
+// it in q.Capture; the body of an encrypted query, once decrypted into
+// q.QueryBody, holds the same fields as JSON. It returns nil if the query
+// has neither.
+func CaptureOf(q *sdm.Query) *Capture {
+	if q.Capture != nil {
+		return &Capture{
+			Type:          q.Capture.Type,
+			Command:       q.Capture.Command,
+			Pod:           q.Capture.Pod,
+			RequestMethod: q.Capture.RequestMethod,
+			RequestURI:    q.Capture.RequestURI,
+			RequestBody:   q.Capture.RequestBody,
+		}
+	}
+	var c Capture
+	if err := json.Unmarshal([]byte(q.QueryBody), &c); err != nil {
+		return nil
+	}
+	return &c
+}
+
+// IsKubernetes reports whether a query was made against a Kubernetes
+// resource.
+func IsKubernetes(q *sdm.Query) bool {
+	if q.QueryCategory == Category {
+		return true
+	}
+	c := CaptureOf(q)
+	return c != nil && strings.HasPrefix(c.Type, "k8s")
+}
+
+// isSessionType reports whether a capture type is an interactive exec or
+// attach session, which can be replayed.
+func isSessionType(captureType string) bool {
+	switch captureType {
+	case CaptureExec, CaptureExecTTY, CaptureAttach:
+		return true
+	}
+	return false
+}
+
+// IsSession reports whether the capture is an exec or attach session. Older
+// captures only record the request, so its subresource is checked too. A nil
+// capture is not a session.
+func (c *Capture) IsSession() bool {
+	if c == nil {
+		return false
+	}
+	if isSessionType(c.Type) {
+		return true
+	}
+	call, err := ParseCall(c.RequestMethod, c.RequestURI)
+	return err == nil && (call.Subresource == "exec" || call.Subresource == "attach")
+}
+
+// TTY reports whether the session had a terminal. Without one, output uses
+// bare line feeds.
+func (c *Capture) TTY() bool {
+	if c.Type == CaptureExecTTY {
+		return true
+	}
+	if call, err := ParseCall(c.RequestMethod, c.RequestURI); err == nil {
+		return call.TTY
+	}
+	return false
+}
+
+// DescribeCall summarizes a query against a Kubernetes resource that isn't
+// an exec or attach session, such as kubectl get, as its Call or as the
+// reason it can't be parsed. It returns false for other queries.
+func DescribeCall(q *sdm.Query) (string, bool) {
+	if !IsKubernetes(q) {
+		return "", false
+	}
+	c := CaptureOf(q)
+	if c == nil {
+		return "not captured", true
+	}
+	call, err := ParseCall(c.RequestMethod, c.RequestURI)
+	if err != nil {
+		return err.Error(), true
+	}
+	return call.String(), true
+}
+
+// Call is a request to the Kubernetes API, described the way the API server
+// authorizes it.
+type Call struct {
+	// Verb is get, list, watch, create, update, patch, delete or
+	// deletecollection for resources, or the lower case HTTP method for
+	// other paths.
+	Verb        string `json:"verb"`
+	Group       string `json:"group,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	// Path is set instead of Resource for requests that aren't for a
+	// resource, such as /version.
+	Path string `json:"path,omitempty"`
+	// Command, Container and TTY are set for exec and attach.
+	Command   []string `json:"command,omitempty"`
+	Container string   `json:"container,omitempty"`
+	TTY       bool     `json:"tty,omitempty"`
+}
+
+// ParseCall parses the method and request URI of a Kubernetes API request,
+// such as GET /apis/apps/v1/namespaces/default/deployments/web.
+func ParseCall(method, requestURI string) (*Call, error) {
+	if method == "" || requestURI == "" {
+		return nil, fmt.Errorf("no Kubernetes request recorded")
+	}
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URI %q: %w", requestURI, err)
+	}
+	call := &Call{Verb: strings.ToLower(method)}
+	query := u.Query()
+	call.Command = query["command"]
+	call.Container = query.Get("container")
+	call.TTY = query.Get("tty") == "true" || query.Get("tty") == "1"
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		call.Version, parts = parts[1], parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		call.Group, call.Version, parts = parts[1], parts[2], parts[3:]
+	default:
+		call.Path = u.Path
+		return call, nil
+	}
+	// The deprecated watch prefix, as in /api/v1/watch/pods.
+	watch := query.Get("watch") == "true" || query.Get("watch") == "1"
+	if len(parts) > 0 && parts[0] == "watch" {
+		watch, parts = true, parts[1:]
+	}
+	// /api/v1/namespaces/prod is the namespace itself, and status and
+	// finalize are its subresources; anything else after a namespace is a
+	// resource in it.
+	if len(parts) >= 3 && parts[0] == "namespaces" && !(len(parts) == 3 && (parts[2] == "status" || parts[2] == "finalize")) {
+		call.Namespace, parts = parts[1], parts[2:]
+	}
+	if len(parts) == 0 {
+		call.Path = u.Path
+		return call, nil
+	}
+	call.Resource = parts[0]
+	if len(parts) > 1 {
+		call.Name = parts[1]
+	}
+	if len(parts) > 2 {
+		call.Subresource = strings.Join(parts[2:], "/")
+	}
+
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD":
+		switch {
+		case watch:
+			call.Verb = "watch"
+		case call.Name == "":
+			call.Verb = "list"
+		default:
+			call.Verb = "get"
+		}
+	case "POST":
+		call.Verb = "create"
+	case "PUT":
+		call.Verb = "update"
+	case "PATCH":
+		call.Verb = "patch"
+	case "DELETE":
+		if call.Name == "" {
+			call.Verb = "deletecollection"
+		} else {
+			call.Verb = "delete"
+		}
+	}
+	return call, nil
+}
+
+// Object names the kind of object a call is for, with its API group, such
+// as deployments.apps or pods/exec.
+func (c *Call) Object() string {
+	if c.Resource == "" {
+		return c.Path
+	}
+	if c.Subresource != "" {
+		return c.kind() + "/" + c.Subresource
+	}
+	return c.kind()
+}
+
+func (c *Call) kind() string {
+	if c.Group != "" {
+		return c.Resource + "." + c.Group
+	}
+	return c.Resource
+}
+
+// Mutating reports whether a call changes the cluster.
+func (c *Call) Mutating() bool {
+	switch c.Verb {
+	case "create", "update", "patch", "delete", "deletecollection":
+		return true
+	}
+	return false
+}
+
+// String summarizes a call the way kubectl names objects, such as
+// "delete deployments.apps/web -n prod".
+func (c *Call) String() string {
+	s := c.Verb + " " + c.Object()
+	if c.Name != "" {
+		s = c.Verb + " " + c.kind() + "/" + c.Name
+		if c.Subresource != "" {
+			s += "/" + c.Subresource
+		}
+	}
+	if c.Namespace != "" {
+		s += " -n " + c.Namespace
+	}
+	if len(c.Command) > 0 {
+		s += " -- " + strings.Join(c.Command, " ")
+	}
+	return s
+}
@@ -0,0 +1,152 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+func TestParseCall(t *testing.T) {
+	tests := []struct {
+		method, uri string
+		want        Call
+		// str is what Call.String returns.
+		str string
+	}{
+		{
+			"GET", "/api/v1/namespaces/default/pods",
+			Call{Verb: "list", Version: "v1", Namespace: "default", Resource: "pods"},
+			"list pods -n default",
+		},
+		{
+			"GET", "/apis/apps/v1/namespaces/prod/deployments/web",
+			Call{Verb: "get", Group: "apps", Version: "v1", Namespace: "prod", Resource: "deployments", Name: "web"},
+			"get deployments.apps/web -n prod",
+		},
+		{
+			"GET", "/api/v1/pods?watch=true",
+			Call{Verb: "watch", Version: "v1", Resource: "pods"},
+			"watch pods",
+		},
+		{
+			"GET", "/api/v1/watch/namespaces/dev/configmaps",
+			Call{Verb: "watch", Version: "v1", Namespace: "dev", Resource: "configmaps"},
+			"watch configmaps -n dev",
+		},
+		{
+			"POST", "/api/v1/namespaces/dev/pods/web-0/exec?command=sh&command=-c&command=id&container=app&tty=true",
+			Call{Verb: "create", Version: "v1", Namespace: "dev", Resource: "pods", Name: "web-0", Subresource: "exec",
+				Command: []string{"sh", "-c", "id"}, Container: "app", TTY: true},
+			"create pods/web-0/exec -n dev -- sh -c id",
+		},
+		{
+			"PATCH", "/apis/apps/v1/namespaces/prod/deployments/web/scale",
+			Call{Verb: "patch", Group: "apps", Version: "v1", Namespace: "prod", Resource: "deployments", Name: "web", Subresource: "scale"},
+			"patch deployments.apps/web/scale -n prod",
+		},
+		{
+			"DELETE", "/api/v1/namespaces/dev/secrets",
+			Call{Verb: "deletecollection", Version: "v1", Namespace: "dev", Resource: "secrets"},
+			"deletecollection secrets -n dev",
+		},
+		{
+			"DELETE", "/api/v1/namespaces/dev",
+			Call{Verb: "delete", Version: "v1", Resource: "namespaces", Name: "dev"},
+			"delete namespaces/dev",
+		},
+		{
+			"PUT", "/api/v1/namespaces/dev/finalize",
+			Call{Verb: "update", Version: "v1", Resource: "namespaces", Name: "dev", Subresource: "finalize"},
+			"update namespaces/dev/finalize",
+		},
+		{
+			"GET", "/version",
+			Call{Verb: "get", Path: "/version"},
+			"get /version",
+		},
+		{
+			"GET", "/apis",
+			Call{Verb: "get", Path: "/apis"},
+			"get /apis",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.uri, func(t *testing.T) {
+			got, err := ParseCall(tt.method, tt.uri)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseCall() = %+v, want %+v", *got, tt.want)
+			}
+			if s := got.String(); s != tt.str {
+				t.Errorf("String() = %q, want %q", s, tt.str)
+			}
+		})
+	}
+}
+
+func TestParseCallErrors(t *testing.T) {
+	tests := []struct{ method, uri string }{
+		{"", "/api/v1/pods"},
+		{"GET", ""},
+		{"GET", "api/v1/pods"},
+	}
+	for _, tt := range tests {
+		if call, err := ParseCall(tt.method, tt.uri); err == nil {
+			t.Errorf("ParseCall(%q, %q) = %+v, want an error", tt.method, tt.uri, call)
+		}
+	}
+}
+
+func TestDescribeCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  *sdm.Query
+		want   string
+		wantOK bool
+	}{
+		{
+			"api call",
+			&sdm.Query{QueryCategory: Category, QueryBody: `{"type":"k8s","requestMethod":"GET","requestUri":"/api/v1/namespaces/dev/pods"}`},
+			"list pods -n dev", true,
+		},
+		{
+			"not captured",
+			&sdm.Query{QueryCategory: Category, QueryBody: "not json"},
+			"not captured", true,
+		},
+		{
+			"no request",
+			&sdm.Query{QueryCategory: Category, QueryBody: `{"type":"k8s"}`},
+			"no Kubernetes request recorded", true,
+		},
+		{
+			"not kubernetes",
+			&sdm.Query{QueryCategory: "shell", QueryBody: `{"command":"ls"}`},
+			"", false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DescribeCall(tt.query)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("DescribeCall() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
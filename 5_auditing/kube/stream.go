@@ -0,0 +1,128 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Channels of the Kubernetes remote command protocol. Each frame of an exec
+// or attach session starts with the channel it was sent on.
+const (
+	channelStdin  = 0
+	channelStdout = 1
+	channelStderr = 2
+	channelError  = 3
+	channelResize = 4
+)
+
+// Stream turns the recorded events of an exec or attach session into the
+// terminal output a user saw, so the session can be replayed like an SSH
+// session.
+//
+// Sessions recorded from the remote command protocol hold one frame per
+// event: stdout and stderr are written out, stdin is dropped since a
+// terminal echoes what is typed, resizes are tracked, and the error channel
+// gives the exit status. Sessions recorded as plain terminal output are
+// passed through. Without a TTY, programs write bare line feeds, which are
+// turned into CR LF so the output lines up on a terminal.
+type Stream struct {
+	tty bool
+	// framed is decided by the first event: frames start with a channel
+	// number, which terminal output never does.
+	framed, decided bool
+	lastCR          bool
+
+	// Width and Height are the terminal size from the last resize frame.
+	Width, Height int
+	// Status is the message sent on the error channel when the command
+	// ended, such as "command terminated with non-zero exit code: ...".
+	Status string
+}
+
+// NewStream returns a Stream for a session with or without a TTY.
+func NewStream(tty bool) *Stream {
+	return &Stream{tty: tty}
+}
+
+// StreamFor returns a Stream for a query if it is an exec or attach session,
+// and nil otherwise. A nil Stream passes events through unchanged, so
+// replays of SSH and Kubernetes sessions can share the same code.
+func StreamFor(q *sdm.Query) *Stream {
+	c := CaptureOf(q)
+	if !c.IsSession() {
+		return nil
+	}
+	return NewStream(c.TTY())
+}
+
+// Write returns the terminal output in the data of one event.
+func (s *Stream) Write(data []byte) []byte {
+	if s == nil {
+		return data
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if !s.decided {
+		s.framed, s.decided = data[0] <= channelResize, true
+	}
+	if !s.framed {
+		return s.terminal(data)
+	}
+	switch data[0] {
+	case channelStdout, channelStderr:
+		return s.terminal(data[1:])
+	case channelError:
+		var status struct {
+			Status  string
+			Message string
+		}
+		if err := json.Unmarshal(data[1:], &status); err == nil && status.Status != "Success" {
+			s.Status = status.Message
+		}
+	case channelResize:
+		var size struct{ Width, Height int }
+		if err := json.Unmarshal(data[1:], &size); err == nil {
+			s.Width, s.Height = size.Width, size.Height
+		}
+	}
+	return nil
+}
+
+// Flush returns a line reporting how the command ended, if it failed.
+func (s *Stream) Flush() []byte {
+	if s == nil || s.Status == "" {
+		return nil
+	}
+	return []byte(fmt.Sprintf("\r\n[%v]\r\n", s.Status))
+}
+
+func (s *Stream) terminal(data []byte) []byte {
+	if s.tty {
+		return data
+	}
+	out := make([]byte, 0, len(data))
+	for _, c := range data {
+		if c == '\n' && !s.lastCR {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+		s.lastCR = c == '\r'
+	}
+	return out
+}
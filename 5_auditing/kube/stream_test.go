@@ -0,0 +1,163 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package kube
+
+import (
+	"testing"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// frame returns an event of the remote command protocol.
+func frame(channel byte, data string) []byte {
+	return append([]byte{channel}, data...)
+}
+
+func TestStreamWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		tty    bool
+		events [][]byte
+		want   string
+		// status, width and height are the fields of the Stream afterwards.
+		status        string
+		width, height int
+		// flush is what Flush returns at the end.
+		flush string
+	}{
+		{
+			name:   "stdout and stderr are written, stdin is dropped",
+			tty:    true,
+			events: [][]byte{frame(channelStdout, "$ "), frame(channelStdin, "id\r"), frame(channelStdout, "id\r\n"), frame(channelStderr, "denied\r\n")},
+			want:   "$ id\r\ndenied\r\n",
+		},
+		{
+			name:   "line feeds without a tty",
+			events: [][]byte{frame(channelStdout, "a\nb\r\nc\n")},
+			want:   "a\r\nb\r\nc\r\n",
+		},
+		{
+			name:   "CR LF split across frames",
+			events: [][]byte{frame(channelStdout, "a\r"), frame(channelStdout, "\nb")},
+			want:   "a\r\nb",
+		},
+		{
+			name:   "line feeds with a tty are left alone",
+			tty:    true,
+			events: [][]byte{frame(channelStdout, "a\nb")},
+			want:   "a\nb",
+		},
+		{
+			name:   "terminal output",
+			events: [][]byte{[]byte("total 0\n"), {channelStdout}, []byte("done\n")},
+			// Once the first event isn't a frame, no event is one.
+			want: "total 0\r\n\x01done\r\n",
+		},
+		{
+			name:   "empty events",
+			tty:    true,
+			events: [][]byte{nil, frame(channelStdout, "x"), {}},
+			want:   "x",
+		},
+		{
+			name: "failed command",
+			tty:  true,
+			events: [][]byte{
+				frame(channelStdout, "oops"),
+				frame(channelError, `{"status":"Failure","message":"command terminated with non-zero exit code: 2"}`),
+			},
+			want:   "oops",
+			status: "command terminated with non-zero exit code: 2",
+			flush:  "\r\n[command terminated with non-zero exit code: 2]\r\n",
+		},
+		{
+			name:   "successful command",
+			tty:    true,
+			events: [][]byte{frame(channelError, `{"status":"Success"}`)},
+		},
+		{
+			name:   "resize",
+			tty:    true,
+			events: [][]byte{frame(channelResize, `{"Width":80,"Height":24}`), frame(channelResize, `{"Width":120,"Height":40}`)},
+			width:  120,
+			height: 40,
+		},
+		{
+			name:   "malformed resize is ignored",
+			tty:    true,
+			events: [][]byte{frame(channelResize, `{"Width":80,"Height":24}`), frame(channelResize, `{"Width":`)},
+			width:  80,
+			height: 24,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStream(tt.tty)
+			var out []byte
+			for _, event := range tt.events {
+				out = append(out, s.Write(event)...)
+			}
+			if string(out) != tt.want {
+				t.Errorf("Write() = %q, want %q", out, tt.want)
+			}
+			if s.Status != tt.status || s.Width != tt.width || s.Height != tt.height {
+				t.Errorf("Status, Width, Height = %q, %v, %v, want %q, %v, %v", s.Status, s.Width, s.Height, tt.status, tt.width, tt.height)
+			}
+			if got := string(s.Flush()); got != tt.flush {
+				t.Errorf("Flush() = %q, want %q", got, tt.flush)
+			}
+		})
+	}
+}
+
+func TestStreamFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *sdm.Query
+		want    bool
+		wantTTY bool
+	}{
+		{"exec with a tty", &sdm.Query{Capture: &sdm.QueryCapture{Type: CaptureExecTTY}}, true, true},
+		{"attach", &sdm.Query{Capture: &sdm.QueryCapture{Type: CaptureAttach}}, true, false},
+		{
+			"older capture of an exec request",
+			&sdm.Query{Capture: &sdm.QueryCapture{Type: "k8s", RequestMethod: "POST", RequestURI: "/api/v1/namespaces/dev/pods/web-0/exec?command=sh&tty=true"}},
+			true, true,
+		},
+		{"decrypted body", &sdm.Query{QueryBody: `{"type":"k8s-exec"}`}, true, false},
+		{"api call", &sdm.Query{Capture: &sdm.QueryCapture{Type: "k8s", RequestMethod: "GET", RequestURI: "/api/v1/pods"}}, false, false},
+		{"shell", &sdm.Query{Capture: &sdm.QueryCapture{Type: "shell"}}, false, false},
+		{"no capture", &sdm.Query{QueryBody: "SELECT 1"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := StreamFor(tt.query)
+			if (s != nil) != tt.want {
+				t.Fatalf("StreamFor() = %+v, want a Stream: %v", s, tt.want)
+			}
+			if s != nil && s.tty != tt.wantTTY {
+				t.Errorf("StreamFor() tty = %v, want %v", s.tty, tt.wantTTY)
+			}
+		})
+	}
+
+	// A nil Stream passes events through.
+	var s *Stream
+	if got := string(s.Write([]byte("a\nb"))); got != "a\nb" {
+		t.Errorf("nil Stream Write() = %q, want the event unchanged", got)
+	}
+	if got := s.Flush(); got != nil {
+		t.Errorf("nil Stream Flush() = %q, want nil", got)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// entityChange is an entity that was added, removed or changed between two
+// snapshots.
+type entityChange struct {
+	Collection string
+	ID         string
+	Name       string
+	Op         byte // '+', '-' or '~'
+	Fields     []fieldChange
+}
+
+func (c entityChange) String() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%c %v", c.Op, c.ID)
+	}
+	return fmt.Sprintf("%c %v %v", c.Op, c.ID, c.Name)
+}
+
+// fieldChange is a difference in one field of an entity.
+type fieldChange struct {
+	Path   string
+	Before string // empty if the field was added
+	After  string // empty if the field was removed
+}
+
+func (c fieldChange) String() string {
+	switch {
+	case c.Before == "":
+		return fmt.Sprintf("+ %v: %v", c.Path, c.After)
+	case c.After == "":
+		return fmt.Sprintf("- %v: %v", c.Path, c.Before)
+	default:
+		return fmt.Sprintf("~ %v: %v -> %v", c.Path, c.Before, c.After)
+	}
+}
+
+// diffSnapshots returns the entities that differ between two snapshots, by
+// collection and then ID.
+func diffSnapshots(before, after *snapshot) ([]entityChange, error) {
+	var changes []entityChange
+	for _, c := range collections {
+		old, current := before.Entities[c.name], after.Entities[c.name]
+		ids := map[string]bool{}
+		for id := range old {
+			ids[id] = true
+		}
+		for id := range current {
+			ids[id] = true
+		}
+		sorted := make([]string, 0, len(ids))
+		for id := range ids {
+			sorted = append(sorted, id)
+		}
+		sort.Strings(sorted)
+
+		for _, id := range sorted {
+			b, a := old[id], current[id]
+			if bytes.Equal(b, a) {
+				continue
+			}
+			change := entityChange{Collection: c.name, ID: id}
+			switch {
+			case b == nil:
+				change.Op, change.Name = '+', displayName(a)
+			case a == nil:
+				change.Op, change.Name = '-', displayName(b)
+			default:
+				change.Op, change.Name = '~', displayName(a)
+				bf, err := flattenJSON(b)
+				if err != nil {
+					return nil, fmt.Errorf("%v %v: %w", c.name, id, err)
+				}
+				af, err := flattenJSON(a)
+				if err != nil {
+					return nil, fmt.Errorf("%v %v: %w", c.name, id, err)
+				}
+				change.Fields = diffFields(bf, af)
+				if len(change.Fields) == 0 {
+					// Only the formatting differs.
+					continue
+				}
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// diffFields returns the fields that differ between two flattened entities,
+// in path order.
+func diffFields(before, after map[string]string) []fieldChange {
+	var changes []fieldChange
+	for path, b := range before {
+		if a := after[path]; a != b {
+			changes = append(changes, fieldChange{Path: path, Before: b, After: a})
+		}
+	}
+	for path, a := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, fieldChange{Path: path, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// flattenJSON turns a saved entity into a map from field path, such as Name,
+// Tags.env or AccessRules[0].Type, to its value. Empty values are left out,
+// so a field that is cleared shows up as removed.
+func flattenJSON(data []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	flattenValue(v, "", fields)
+	return fields, nil
+}
+
+func flattenValue(v interface{}, path string, fields map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			if path != "" {
+				name = path + "." + name
+			}
+			flattenValue(field, name, fields)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenValue(item, path+"["+strconv.Itoa(i)+"]", fields)
+		}
+	case string:
+		if v != "" && v != "0001-01-01T00:00:00Z" {
+			fields[path] = strconv.Quote(v)
+		}
+	case json.Number:
+		if v != "0" {
+			fields[path] = v.String()
+		}
+	case bool:
+		if v {
+			fields[path] = "true"
+		}
+	}
+}
+
+// displayName returns the name or email of a saved entity, if it has one.
+func displayName(data []byte) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+	for _, want := range []string{"name", "email"} {
+		for name, v := range fields {
+			if s, ok := v.(string); ok && s != "" && strings.EqualFold(name, want) {
+				return s
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	at := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	before := newSnapshot(t, at, map[string][]interface{}{
+		"accounts": {&sdm.User{ID: "a-1", Email: "alice@example.com"}},
+		"roles": {
+			&sdm.Role{ID: "r-1", Name: "eng", Tags: sdm.Tags{"env": "prod"}},
+			&sdm.Role{ID: "r-2", Name: "ops"},
+		},
+	})
+	after := newSnapshot(t, at.Add(time.Hour), map[string][]interface{}{
+		"accounts": {&sdm.User{ID: "a-1", Email: "alice@example.com"}},
+		"roles": {
+			&sdm.Role{ID: "r-1", Name: "engineering", Tags: sdm.Tags{"team": "core"}},
+			&sdm.Role{ID: "r-3", Name: "support"},
+		},
+	})
+	// The same account saved with other formatting is not a change.
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, after.Entities["accounts"]["a-1"]); err != nil {
+		t.Fatal(err)
+	}
+	after.Entities["accounts"]["a-1"] = compact.Bytes()
+
+	changes, err := diffSnapshots(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Collection+" "+c.String())
+		for _, f := range c.Fields {
+			got = append(got, "  "+f.String())
+		}
+	}
+	want := []string{
+		"roles ~ r-1 engineering",
+		`  ~ Name: "eng" -> "engineering"`,
+		`  - Tags.env: "prod"`,
+		`  + Tags.team: "core"`,
+		"roles - r-2 ops",
+		"roles + r-3 support",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSnapshots() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestFlattenJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{
+			"nested fields",
+			`{"Name":"eng","Tags":{"env":"prod"},"AccessRules":[{"type":"postgres"},{"ids":["rs-1","rs-2"]}]}`,
+			map[string]string{
+				"Name":                  `"eng"`,
+				"Tags.env":              `"prod"`,
+				"AccessRules[0].type":   `"postgres"`,
+				"AccessRules[1].ids[0]": `"rs-1"`,
+				"AccessRules[1].ids[1]": `"rs-2"`,
+			},
+		},
+		{
+			"scalars",
+			`{"Port":5432,"Ratio":0.5,"Suspended":true,"Big":12345678901234567890}`,
+			map[string]string{"Port": "5432", "Ratio": "0.5", "Suspended": "true", "Big": "12345678901234567890"},
+		},
+		{
+			"empty values are left out",
+			`{"Name":"","Port":0,"Suspended":false,"Tags":{},"Rules":[],"Manager":null,"Created":"0001-01-01T00:00:00Z"}`,
+			map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := flattenJSON([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flattenJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := flattenJSON([]byte("{")); err == nil {
+		t.Error("flattenJSON() of truncated JSON succeeded, want an error")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Saves the whole organization as it was at a point in time, using
+// client.SnapshotAt, to a directory with one JSON file per entity, and
+// compares two snapshots or a snapshot with the live organization. The same
+// state always produces the same files, so snapshots can also be compared
+// with diff or kept in git:
+//
+//	org_snapshot dump -at 2025-01-01T00:00:00Z -out snapshots/2025-01-01
+//	org_snapshot diff snapshots/2025-01-01 live
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal("usage: org_snapshot dump [-at time] -out dir | org_snapshot diff <dir|live> <dir|live>")
+	}
+	switch os.Args[1] {
+	case "dump":
+		dumpCommand(os.Args[2:])
+	case "diff":
+		diffCommand(os.Args[2:])
+	default:
+		log.Fatalf("unknown command %q, expected dump or diff", os.Args[1])
+	}
+}
+
+func dumpCommand(args []string) {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	at := flags.String("at", "", "time to take the snapshot at, RFC 3339 or a duration ago such as 24h (default now)")
+	outDir := flags.String("out", "", "directory to write the snapshot to, which must not exist or be empty")
+	flags.Parse(args)
+	if *outDir == "" {
+		log.Fatal("usage: org_snapshot dump [-at time] -out dir")
+	}
+
+	client := newClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	var s *snapshot
+	var err error
+	if *at == "" {
+		s, err = readOrg(ctx, client, now.UTC())
+	} else {
+		t, parseErr := timeflag.Parse(*at, now, now)
+		if parseErr != nil {
+			log.Fatalf("invalid -at: %v", parseErr)
+		}
+		s, err = readOrg(ctx, client.SnapshotAt(t), t.UTC())
+	}
+	if err != nil {
+		log.Fatalf("failed to read organization: %v", err)
+	}
+	if err := s.save(*outDir); err != nil {
+		log.Fatalf("failed to save snapshot: %v", err)
+	}
+	total := 0
+	for _, entities := range s.Entities {
+		total += len(entities)
+	}
+	log.Printf("Saved %v entities as of %v to %v", total, s.At.Format(time.RFC3339), *outDir)
+}
+
+// diffCommand prints what changed from the first snapshot to the second and
+// exits with status 1 if anything did, like diff.
+func diffCommand(args []string) {
+	if len(args) != 2 {
+		log.Fatal("usage: org_snapshot diff <dir|live> <dir|live>")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	var client *sdm.Client
+	load := func(source string) *snapshot {
+		if source != "live" {
+			s, err := loadSnapshot(source)
+			if err != nil {
+				log.Fatalf("failed to load snapshot: %v", err)
+			}
+			return s
+		}
+		if client == nil {
+			client = newClient()
+		}
+		s, err := readOrg(ctx, client, time.Now().UTC())
+		if err != nil {
+			log.Fatalf("failed to read organization: %v", err)
+		}
+		return s
+	}
+	before, after := load(args[0]), load(args[1])
+
+	changes, err := diffSnapshots(before, after)
+	if err != nil {
+		log.Fatalf("failed to compare snapshots: %v", err)
+	}
+	fmt.Printf("Changes from %v (%v) to %v (%v):\n",
+		args[0], before.At.Format(time.RFC3339), args[1], after.At.Format(time.RFC3339))
+	if len(changes) == 0 {
+		fmt.Println("  (no changes)")
+		return
+	}
+	collection := ""
+	for _, c := range changes {
+		if c.Collection != collection {
+			collection = c.Collection
+			fmt.Printf("%v:\n", collection)
+		}
+		fmt.Printf("  %v\n", c)
+		for _, f := range c.Fields {
+			fmt.Printf("      %v\n", f)
+		}
+	}
+	os.Exit(1)
+}
+
+func newClient() *sdm.Client {
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+	return client
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// orgReader is implemented by both the live client and a SnapshotClient, so
+// the same code reads the organization as it is now or as it was at any time
+// in the past.
+type orgReader interface {
+	Accounts() *sdm.Accounts
+	Roles() *sdm.Roles
+	Resources() *sdm.Resources
+	Groups() *sdm.Groups
+	AccountAttachments() *sdm.AccountAttachments
+	AccountsGroups() *sdm.AccountsGroups
+	GroupsRoles() *sdm.GroupsRoles
+	Workflows() *sdm.Workflows
+	ApprovalWorkflows() *sdm.ApprovalWorkflows
+	Policies() *sdm.Policies
+}
+
+type iterator[T any] interface {
+	Next() bool
+	Value() T
+	Err() error
+}
+
+// collect reads every entity from an iterator, keyed by ID.
+func collect[T any](it iterator[T], err error, id func(T) string) (map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	entities := map[string]interface{}{}
+	for it.Next() {
+		v := it.Value()
+		entities[id(v)] = v
+	}
+	return entities, it.Err()
+}
+
+// collections are the parts of the organization saved in a snapshot, named
+// after the directory each is written to. Roles carry their access rules.
+var collections = []struct {
+	name string
+	list func(ctx context.Context, r orgReader) (map[string]interface{}, error)
+}{
+	{"accounts", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.Accounts().List(ctx, "")
+		return collect[sdm.Account](it, err, sdm.Account.GetID)
+	}},
+	{"roles", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.Roles().List(ctx, "")
+		return collect(it, err, func(v *sdm.Role) string { return v.ID })
+	}},
+	{"resources", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.Resources().List(ctx, "")
+		return collect[sdm.Resource](it, err, sdm.Resource.GetID)
+	}},
+	{"groups", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.Groups().List(ctx, "")
+		return collect(it, err, func(v *sdm.Group) string { return v.ID })
+	}},
+	{"account-attachments", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.AccountAttachments().List(ctx, "")
+		return collect(it, err, func(v *sdm.AccountAttachment) string { return v.ID })
+	}},
+	{"accounts-groups", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.AccountsGroups().List(ctx, "")
+		return collect(it, err, func(v *sdm.AccountGroup) string { return v.ID })
+	}},
+	{"groups-roles", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.GroupsRoles().List(ctx, "")
+		return collect(it, err, func(v *sdm.GroupRole) string { return v.ID })
+	}},
+	{"workflows", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.Workflows().List(ctx, "")
+		return collect(it, err, func(v *sdm.Workflow) string { return v.ID })
+	}},
+	{"approval-workflows", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.ApprovalWorkflows().List(ctx, "")
+		return collect(it, err, func(v *sdm.ApprovalWorkflow) string { return v.ID })
+	}},
+	{"policies", func(ctx context.Context, r orgReader) (map[string]interface{}, error) {
+		it, err := r.Policies().List(ctx, "")
+		return collect(it, err, func(v *sdm.Policy) string { return v.ID })
+	}},
+}
+
+// snapshot is the state of an organization at time At, with every entity
+// held as the JSON it is saved as.
+type snapshot struct {
+	At       time.Time
+	Entities map[string]map[string][]byte // collection name, then ID
+}
+
+// manifest is saved alongside the entities of a snapshot.
+type manifest struct {
+	At     time.Time      `json:"at"`
+	Counts map[string]int `json:"counts"`
+}
+
+const manifestFile = "manifest.json"
+
+// readOrg reads every collection from r.
+func readOrg(ctx context.Context, r orgReader, at time.Time) (*snapshot, error) {
+	s := &snapshot{At: at, Entities: map[string]map[string][]byte{}}
+	for _, c := range collections {
+		entities, err := c.list(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %v: %w", c.name, err)
+		}
+		s.Entities[c.name] = map[string][]byte{}
+		for id, v := range entities {
+			data, err := canonicalJSON(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %v %v: %w", c.name, id, err)
+			}
+			s.Entities[c.name][id] = data
+		}
+	}
+	return s, nil
+}
+
+// canonicalJSON encodes an entity so that the same state always gives the
+// same bytes: object keys are sorted, output is indented, and the concrete
+// type of accounts and resources is recorded in a _type field. Secrets are
+// replaced with <redacted>.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	generic, err := redact.Entity(v, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// save writes a snapshot to dir as one file per entity,
+// <collection>/<id>.json, and a manifest. dir must not exist or be empty, so
+// that the tree holds exactly the snapshot.
+func (s *snapshot) save(dir string) error {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%v is not empty", dir)
+	}
+	m := manifest{At: s.At, Counts: map[string]int{}}
+	for _, c := range collections {
+		if err := os.MkdirAll(filepath.Join(dir, c.name), 0755); err != nil {
+			return err
+		}
+		for id, data := range s.Entities[c.name] {
+			if err := os.WriteFile(filepath.Join(dir, c.name, id+".json"), data, 0644); err != nil {
+				return err
+			}
+		}
+		m.Counts[c.name] = len(s.Entities[c.name])
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0644)
+}
+
+// loadSnapshot reads a snapshot saved by save.
+func loadSnapshot(dir string) (*snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%v is not a snapshot, it has no %v", dir, manifestFile)
+	} else if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", manifestFile, err)
+	}
+
+	s := &snapshot{At: m.At, Entities: map[string]map[string][]byte{}}
+	for _, c := range collections {
+		s.Entities[c.name] = map[string][]byte{}
+		files, err := filepath.Glob(filepath.Join(dir, c.name, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range files {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			s.Entities[c.name][strings.TrimSuffix(filepath.Base(path), ".json")] = data
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	role := &sdm.Role{ID: "r-1", Name: "eng", Tags: sdm.Tags{"team": "core", "env": "prod", "cost": "42"}}
+	first := encode(t, role)
+	// Map iteration order changes from run to run, so encode several times.
+	for i := 0; i < 20; i++ {
+		if again := encode(t, role); !bytes.Equal(again, first) {
+			t.Fatalf("canonicalJSON() gave\n%s\nthen\n%s", first, again)
+		}
+	}
+	if !bytes.HasSuffix(first, []byte("\n")) {
+		t.Errorf("canonicalJSON() = %q, want a trailing newline", first)
+	}
+	assertOrder(t, string(first), `"ID"`, `"Name"`, `"Tags"`, `"cost"`, `"env"`, `"team"`, `"_type": "Role"`)
+
+	redis := encode(t, &sdm.Redis{ID: "rs-1", Name: "cache", Password: "hunter22", Hostname: "cache.internal"})
+	if bytes.Contains(redis, []byte("hunter22")) || !bytes.Contains(redis, []byte(`"Password": "<redacted>"`)) {
+		t.Errorf("canonicalJSON() = %s, want the password redacted", redis)
+	}
+	if !bytes.Contains(redis, []byte(`"_type": "Redis"`)) {
+		t.Errorf("canonicalJSON() = %s, want the resource type recorded", redis)
+	}
+}
+
+func TestSaveLoadSnapshot(t *testing.T) {
+	s := newSnapshot(t, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), map[string][]interface{}{
+		"accounts": {&sdm.User{ID: "a-1", Email: "alice@example.com"}},
+		"roles":    {&sdm.Role{ID: "r-1", Name: "eng"}, &sdm.Role{ID: "r-2", Name: "ops"}},
+	})
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	if err := s.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.At.Equal(s.At) {
+		t.Errorf("loadSnapshot() At = %v, want %v", loaded.At, s.At)
+	}
+	if !reflect.DeepEqual(loaded.Entities, s.Entities) {
+		t.Errorf("loadSnapshot() Entities =\n%q\nwant\n%q", loaded.Entities, s.Entities)
+	}
+
+	if err := s.save(dir); err == nil {
+		t.Error("save() into a snapshot that already exists succeeded, want an error")
+	}
+	if _, err := loadSnapshot(t.TempDir()); err == nil {
+		t.Error("loadSnapshot() of an empty directory succeeded, want an error")
+	}
+}
+
+// newSnapshot returns a snapshot of the given entities, by collection name.
+func newSnapshot(t *testing.T, at time.Time, entities map[string][]interface{}) *snapshot {
+	s := &snapshot{At: at, Entities: map[string]map[string][]byte{}}
+	for _, c := range collections {
+		s.Entities[c.name] = map[string][]byte{}
+		for _, v := range entities[c.name] {
+			s.Entities[c.name][reflect.Indirect(reflect.ValueOf(v)).FieldByName("ID").String()] = encode(t, v)
+		}
+	}
+	return s
+}
+
+func encode(t *testing.T, v interface{}) []byte {
+	data, err := canonicalJSON(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// assertOrder checks that each of the substrings appears in s after the one
+// before it.
+func assertOrder(t *testing.T, s string, substrings ...string) {
+	t.Helper()
+	rest := s
+	for _, sub := range substrings {
+		i := strings.Index(rest, sub)
+		if i < 0 {
+			t.Errorf("%s\ndoes not have %v after the fields before it", s, sub)
+			return
+		}
+		rest = rest[i+len(sub):]
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// query is a line of the JSONL written by export_queries.
+type query struct {
+	Timestamp    time.Time `json:"timestamp"`
+	ID           string    `json:"id"`
+	AccountID    string    `json:"accountId"`
+	AccountEmail string    `json:"accountEmail"`
+	ResourceID   string    `json:"resourceId"`
+	ResourceName string    `json:"resourceName"`
+	ResourceType string    `json:"resourceType"`
+	DurationMs   int64     `json:"durationMs"`
+	Command      string    `json:"command"`
+}
+
+func (q *query) Duration() time.Duration {
+	return time.Duration(q.DurationMs) * time.Millisecond
+}
+
+// baseline is what is normal for one account or one resource, learned from
+// the queries made in the baseline period.
+type baseline struct {
+	Queries int
+	// Hours counts queries by hour of the day, in the report's time zone.
+	Hours [24]int
+	// Peers are the resources an account used, or the accounts that used a
+	// resource.
+	Peers map[string]bool
+	// Days counts queries by day, from the first day the subject was seen
+	// to the end of the baseline period, so quiet days count as zero.
+	Days      map[string]int
+	FirstSeen time.Time
+	Durations []time.Duration
+}
+
+func newBaseline() *baseline {
+	return &baseline{Peers: map[string]bool{}, Days: map[string]int{}}
+}
+
+func (b *baseline) add(q *query, peer string, loc *time.Location) {
+	t := q.Timestamp.In(loc)
+	b.Queries++
+	b.Hours[t.Hour()]++
+	b.Peers[peer] = true
+	b.Days[dayOf(t)]++
+	if b.FirstSeen.IsZero() || q.Timestamp.Before(b.FirstSeen) {
+		b.FirstSeen = q.Timestamp
+	}
+	if q.DurationMs > 0 {
+		b.Durations = append(b.Durations, q.Duration())
+	}
+}
+
+// HourShare returns the fraction of queries made in the given hour of the
+// day.
+func (b *baseline) HourShare(hour int) float64 {
+	if b.Queries == 0 {
+		return 0
+	}
+	return float64(b.Hours[hour]) / float64(b.Queries)
+}
+
+// DailyVolume returns the mean and standard deviation of the number of
+// queries per day, from the day the subject was first seen until end.
+func (b *baseline) DailyVolume(end time.Time, loc *time.Location) (mean, stddev float64) {
+	if b.Queries == 0 {
+		return 0, 0
+	}
+	var counts []float64
+	for d := b.FirstSeen.In(loc); d.Before(end); d = d.AddDate(0, 0, 1) {
+		counts = append(counts, float64(b.Days[dayOf(d)]))
+	}
+	if len(counts) == 0 {
+		return 0, 0
+	}
+	for _, c := range counts {
+		mean += c
+	}
+	mean /= float64(len(counts))
+	for _, c := range counts {
+		stddev += (c - mean) * (c - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(counts)))
+}
+
+// DurationPercentile returns the pth percentile of session lengths, or zero
+// if none were recorded.
+func (b *baseline) DurationPercentile(p float64) time.Duration {
+	if len(b.Durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), b.Durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+func dayOf(t time.Time) string {
+	return t.Format("2006-01-02")
+}
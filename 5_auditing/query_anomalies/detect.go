@@ -0,0 +1,270 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// finding is one anomaly. Findings are ranked by Score, which is roughly the
+// number of standard deviations from normal for volume, the multiple of the
+// usual length for sessions, and a fixed weight for the other kinds.
+type finding struct {
+	Score   float64   `json:"score"`
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"`
+	Time    time.Time `json:"time"`
+	QueryID string    `json:"queryId,omitempty"`
+	Detail  string    `json:"detail"`
+}
+
+// Fixed scores for anomalies that aren't measured against a distribution.
+const (
+	scoreFirstAccess       = 5
+	scoreFirstAccessNewRes = 3
+	scoreNewAccount        = 4
+	scoreNeverSeenHour     = 3
+	scoreRareHour          = 2
+	maxScore               = 10
+)
+
+// options are the thresholds for flagging a query or a day.
+type options struct {
+	Location *time.Location
+	// MinBaseline is the number of baseline queries a subject needs before
+	// its hours, volume and session lengths are judged.
+	MinBaseline int
+	// RareHour is the share of a subject's queries below which an hour of
+	// the day counts as off-hours.
+	RareHour float64
+	// VolumeZ is how many standard deviations above the daily mean a day's
+	// query count must be to be a spike.
+	VolumeZ float64
+	// LongSession is the multiple of the 95th percentile session length
+	// above which a session is unusually long.
+	LongSession float64
+}
+
+// subjectKind is something baselines are kept for: accounts or resources.
+type subjectKind struct {
+	name string
+	key  func(*query) string
+	// label names the subject in findings, and peer is the other side of
+	// a query, whose set the baseline keeps.
+	label func(*query) string
+	peer  func(*query) string
+}
+
+var subjectKinds = []subjectKind{
+	{
+		name:  "account",
+		key:   func(q *query) string { return q.AccountID },
+		label: func(q *query) string { return "account " + firstNonEmpty(q.AccountEmail, q.AccountID) },
+		peer:  func(q *query) string { return q.ResourceID },
+	},
+	{
+		name:  "resource",
+		key:   func(q *query) string { return q.ResourceID },
+		label: func(q *query) string { return "resource " + firstNonEmpty(q.ResourceName, q.ResourceID) },
+		peer:  func(q *query) string { return q.AccountID },
+	},
+}
+
+// analyze learns baselines from the queries before recentStart and returns
+// the anomalies among the queries from recentStart on, highest score first.
+func analyze(queries []*query, recentStart time.Time, opts options) []finding {
+	sort.SliceStable(queries, func(i, j int) bool { return queries[i].Timestamp.Before(queries[j].Timestamp) })
+	baselines := map[string]map[string]*baseline{}
+	var recent []*query
+	for _, kind := range subjectKinds {
+		baselines[kind.name] = map[string]*baseline{}
+	}
+	for _, q := range queries {
+		if !q.Timestamp.Before(recentStart) {
+			recent = append(recent, q)
+			continue
+		}
+		for _, kind := range subjectKinds {
+			b := baselines[kind.name][kind.key(q)]
+			if b == nil {
+				b = newBaseline()
+				baselines[kind.name][kind.key(q)] = b
+			}
+			b.add(q, kind.peer(q), opts.Location)
+		}
+	}
+
+	var findings []finding
+	findings = append(findings, firstAccess(recent, baselines["account"], baselines["resource"])...)
+	for _, kind := range subjectKinds {
+		findings = append(findings, offHours(recent, kind, baselines[kind.name], opts)...)
+		findings = append(findings, volumeSpikes(recent, kind, baselines[kind.name], recentStart, opts)...)
+		findings = append(findings, longSessions(recent, kind, baselines[kind.name], opts)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Score != findings[j].Score {
+			return findings[i].Score > findings[j].Score
+		}
+		return findings[i].Time.Before(findings[j].Time)
+	})
+	return findings
+}
+
+// firstAccess flags the first time an account uses a resource it didn't use
+// in the baseline, and accounts that weren't seen at all in the baseline.
+func firstAccess(recent []*query, accounts, resources map[string]*baseline) []finding {
+	var findings []finding
+	seen := map[string]bool{}
+	newAccounts := map[string]*finding{}
+	for _, q := range recent {
+		label := subjectKinds[0].label(q)
+		resource := firstNonEmpty(q.ResourceName, q.ResourceID)
+		account := accounts[q.AccountID]
+		if account == nil {
+			used := q.AccountID + " " + resource
+			if seen[used] {
+				continue
+			}
+			seen[used] = true
+			if f := newAccounts[q.AccountID]; f != nil {
+				f.Detail += ", " + resource
+				continue
+			}
+			newAccounts[q.AccountID] = &finding{
+				Score: scoreNewAccount, Kind: "new-account", Subject: label, Time: q.Timestamp, QueryID: q.ID,
+				Detail: "no queries in the baseline period; used " + resource,
+			}
+			continue
+		}
+		pair := q.AccountID + " " + q.ResourceID
+		if account.Peers[q.ResourceID] || seen[pair] {
+			continue
+		}
+		seen[pair] = true
+		f := finding{Score: scoreFirstAccess, Kind: "first-access", Subject: label, Time: q.Timestamp, QueryID: q.ID}
+		if others := resources[q.ResourceID]; others != nil {
+			f.Detail = fmt.Sprintf("first use of %v, which %v other accounts used in the baseline period", resource, len(others.Peers))
+		} else {
+			// A resource nobody used before is most likely new.
+			f.Score = scoreFirstAccessNewRes
+			f.Detail = fmt.Sprintf("first use of %v, which nobody used in the baseline period", resource)
+		}
+		findings = append(findings, f)
+	}
+	for _, f := range newAccounts {
+		findings = append(findings, *f)
+	}
+	return findings
+}
+
+// offHours flags queries made in an hour of the day that a subject rarely or
+// never works in, once per subject and hour.
+func offHours(recent []*query, kind subjectKind, baselines map[string]*baseline, opts options) []finding {
+	var findings []finding
+	seen := map[string]bool{}
+	for _, q := range recent {
+		b := baselines[kind.key(q)]
+		if b == nil || b.Queries < opts.MinBaseline {
+			continue
+		}
+		t := q.Timestamp.In(opts.Location)
+		share := b.HourShare(t.Hour())
+		slot := kind.key(q) + " " + t.Format("2006-01-02T15")
+		if share >= opts.RareHour || seen[slot] {
+			continue
+		}
+		seen[slot] = true
+		f := finding{Score: scoreRareHour, Kind: "off-hours", Subject: kind.label(q), Time: q.Timestamp, QueryID: q.ID}
+		if b.Hours[t.Hour()] == 0 {
+			f.Score = scoreNeverSeenHour
+			f.Detail = fmt.Sprintf("active at %02d:00, never seen in %v baseline queries", t.Hour(), b.Queries)
+		} else {
+			f.Detail = fmt.Sprintf("active at %02d:00, which has %.1f%% of %v baseline queries", t.Hour(), share*100, b.Queries)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// volumeSpikes flags days on which a subject made far more queries than its
+// daily average.
+func volumeSpikes(recent []*query, kind subjectKind, baselines map[string]*baseline, recentStart time.Time, opts options) []finding {
+	type day struct{ key, date string }
+	counts := map[day]int{}
+	firstQuery := map[day]*query{}
+	for _, q := range recent {
+		d := day{kind.key(q), dayOf(q.Timestamp.In(opts.Location))}
+		counts[d]++
+		if firstQuery[d] == nil {
+			firstQuery[d] = q
+		}
+	}
+
+	var findings []finding
+	for d, n := range counts {
+		b := baselines[d.key]
+		if b == nil || b.Queries < opts.MinBaseline {
+			continue
+		}
+		mean, stddev := b.DailyVolume(recentStart, opts.Location)
+		z := (float64(n) - mean) / math.Max(stddev, 1)
+		if z < opts.VolumeZ {
+			continue
+		}
+		q := firstQuery[d]
+		findings = append(findings, finding{
+			Score: math.Min(z, maxScore), Kind: "volume-spike", Subject: kind.label(q), Time: q.Timestamp,
+			Detail: fmt.Sprintf("%v queries on %v, usually %.1f ± %.1f a day", n, d.date, mean, stddev),
+		})
+	}
+	return findings
+}
+
+// longSessions flags queries that lasted much longer than a subject's usual
+// sessions.
+func longSessions(recent []*query, kind subjectKind, baselines map[string]*baseline, opts options) []finding {
+	var findings []finding
+	for _, q := range recent {
+		b := baselines[kind.key(q)]
+		if b == nil || len(b.Durations) < opts.MinBaseline {
+			continue
+		}
+		usual := b.DurationPercentile(95)
+		if usual <= 0 || q.Duration() < time.Minute {
+			continue
+		}
+		ratio := float64(q.Duration()) / float64(usual)
+		if ratio < opts.LongSession {
+			continue
+		}
+		findings = append(findings, finding{
+			Score: math.Min(ratio, maxScore), Kind: "long-session", Subject: kind.label(q), Time: q.Timestamp, QueryID: q.ID,
+			Detail: fmt.Sprintf("session lasted %v, 95%% of baseline sessions were under %v",
+				q.Duration().Round(time.Second), usual.Round(time.Second)),
+		})
+	}
+	return findings
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAnalyzeSample checks that each anomaly planted in the last day of
+// testdata/queries.jsonl is found with the options main uses by default.
+func TestAnalyzeSample(t *testing.T) {
+	f, err := os.Open("testdata/queries.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	queries, err := readQueries(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var end time.Time
+	for _, q := range queries {
+		if q.Timestamp.After(end) {
+			end = q.Timestamp
+		}
+	}
+	findings := analyze(queries, end.Add(-24*time.Hour), options{
+		Location:    time.UTC,
+		MinBaseline: 20,
+		RareHour:    0.01,
+		VolumeZ:     3,
+		LongSession: 3,
+	})
+
+	tests := []struct {
+		name    string
+		kind    string
+		subject string
+		// match is checked against the query ID, time and detail.
+		match string
+	}{
+		{"volume spike", "volume-spike", "account bob@example.com", "40 queries"},
+		{"long session", "long-session", "account alice@example.com", "s00223"},
+		{"first access", "first-access", "account alice@example.com", "payments-db"},
+		{"off-hours", "off-hours", "account alice@example.com", "03:12"},
+		{"new account", "new-account", "account carol@example.com", "prod-postgres"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, f := range findings {
+				text := f.QueryID + " " + f.Time.UTC().Format("15:04") + " " + f.Detail
+				if f.Kind == tt.kind && f.Subject == tt.subject && strings.Contains(text, tt.match) {
+					return
+				}
+			}
+			t.Errorf("no %v finding for %v matching %q in %+v", tt.kind, tt.subject, tt.match, findings)
+		})
+	}
+}
+
+func TestFirstAccessNewAccount(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	var recent []*query
+	// Resource names that contain one another are still listed apart.
+	for i, resource := range []string{"prod-db", "db", "prod-db", "db-replica"} {
+		recent = append(recent, &query{
+			Timestamp: at.Add(time.Duration(i) * time.Minute), ID: fmt.Sprintf("q-%v", i),
+			AccountID: "a-1", AccountEmail: "carol@example.com", ResourceID: "rs-" + resource, ResourceName: resource,
+		})
+	}
+	findings := firstAccess(recent, map[string]*baseline{}, map[string]*baseline{})
+	if len(findings) != 1 {
+		t.Fatalf("firstAccess() = %+v, want one finding", findings)
+	}
+	want := "no queries in the baseline period; used prod-db, db, db-replica"
+	if f := findings[0]; f.Kind != "new-account" || f.QueryID != "q-0" || f.Detail != want {
+		t.Errorf("firstAccess() = %+v, want a new-account finding for q-0 with detail %q", f, want)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/strongdm/strongdm-sdk-go-examples/5_auditing/query_anomalies
+
+go 1.24.5
@@ -0,0 +1,168 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Looks for unusual activity in the queries written by export_queries. What
+// is normal for each account and each resource - the hours it is active, how
+// many queries it makes a day, the resources or accounts it works with and
+// how long its sessions last - is learned from a baseline period, and the
+// queries of the recent period are ranked by how far they stray from it. No
+// API access is needed, so it can be run on an exported file, given with -in
+// or as the only argument:
+//
+//	export_queries -from 720h -out queries.jsonl
+//	query_anomalies -recent 24h -tz America/New_York queries.jsonl
+func main() {
+	log.SetFlags(0)
+	inPath := flag.String("in", "-", "JSON lines file written by export_queries, - for stdin")
+	tz := flag.String("tz", "UTC", "time zone that working hours and days are counted in")
+	baselineFlag := flag.Duration("baseline", 30*24*time.Hour, "length of the baseline period before the recent period")
+	recentFlag := flag.Duration("recent", 24*time.Hour, "length of the recent period to report on")
+	nowFlag := flag.String("now", "", "end of the recent period, RFC 3339 (default the time of the last query)")
+	minBaseline := flag.Int("min-baseline", 20, "baseline queries an account or resource needs before its hours, volume and session lengths are judged")
+	minScore := flag.Float64("min-score", 0, "leave out findings scored below this")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: query_anomalies [flags] [file]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch {
+	case flag.NArg() > 1:
+		flag.Usage()
+		os.Exit(2)
+	case flag.NArg() == 1 && *inPath != "-":
+		log.Fatal("give the input file either with -in or as an argument, not both")
+	case flag.NArg() == 1:
+		*inPath = flag.Arg(0)
+	}
+
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		log.Fatalf("invalid -tz: %v", err)
+	}
+	in := os.Stdin
+	if *inPath != "-" {
+		in, err = os.Open(*inPath)
+		if err != nil {
+			log.Fatalf("failed to open input: %v", err)
+		}
+		defer in.Close()
+	}
+	queries, err := readQueries(in)
+	if err != nil {
+		log.Fatalf("failed to read queries: %v", err)
+	}
+	if len(queries) == 0 {
+		log.Fatal("no queries to analyze")
+	}
+
+	var end time.Time
+	if *nowFlag != "" {
+		end, err = time.Parse(time.RFC3339, *nowFlag)
+		if err != nil {
+			log.Fatalf("invalid -now: %v", err)
+		}
+	} else {
+		for _, q := range queries {
+			if q.Timestamp.After(end) {
+				end = q.Timestamp
+			}
+		}
+	}
+	recentStart := end.Add(-*recentFlag)
+	baselineStart := recentStart.Add(-*baselineFlag)
+
+	var inRange []*query
+	baselineCount := 0
+	for _, q := range queries {
+		if q.Timestamp.Before(baselineStart) || q.Timestamp.After(end) {
+			continue
+		}
+		if q.Timestamp.Before(recentStart) {
+			baselineCount++
+		}
+		inRange = append(inRange, q)
+	}
+	findings := analyze(inRange, recentStart, options{
+		Location:    loc,
+		MinBaseline: *minBaseline,
+		RareHour:    0.01,
+		VolumeZ:     3,
+		LongSession: 3,
+	})
+	var report []finding
+	for _, f := range findings {
+		if f.Score >= *minScore {
+			report = append(report, f)
+		}
+	}
+	log.Printf("Learned from %v queries between %v and %v, checked %v queries up to %v",
+		baselineCount, baselineStart.Format(time.RFC3339), recentStart.Format(time.RFC3339),
+		len(inRange)-baselineCount, end.Format(time.RFC3339))
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if report == nil {
+			report = []finding{}
+		}
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("failed to write report: %v", err)
+		}
+		return
+	}
+	if len(report) == 0 {
+		fmt.Println("No anomalies found.")
+		return
+	}
+	for i, f := range report {
+		fmt.Printf("%3d. [%4.1f] %-12v %v  %v\n", i+1, f.Score, f.Kind, f.Time.In(loc).Format("2006-01-02 15:04"), f.Subject)
+		fmt.Printf("                   %v", f.Detail)
+		if f.QueryID != "" {
+			fmt.Printf(" (query %v)", f.QueryID)
+		}
+		fmt.Println()
+	}
+}
+
+// readQueries reads JSON lines of queries, skipping blank lines.
+func readQueries(r io.Reader) ([]*query, error) {
+	var queries []*query
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		q := &query{}
+		if err := json.Unmarshal(scanner.Bytes(), q); err != nil {
+			return nil, fmt.Errorf("line %v: %w", line, err)
+		}
+		queries = append(queries, q)
+	}
+	return queries, scanner.Err()
+}
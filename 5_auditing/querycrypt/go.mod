@@ -0,0 +1,3 @@
+module github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt
+
+go 1.24.5
@@ -0,0 +1,384 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package querycrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Keyring holds every private key that may have been used for remote log
+// encryption. After a key rotation, queries recorded before the rotation are
+// still wrapped with the old key, so each key is tried in turn.
+type Keyring []*rsa.PrivateKey
+
+// unwrapQueryKey decrypts the symmetric key of a query with the first private
+// key in the keyring that accepts it.
+func (keys Keyring) unwrapQueryKey(encryptedQueryKey string) ([]byte, error) {
+	queryKeyBytes, err := base64.StdEncoding.DecodeString(encryptedQueryKey)
+	if err != nil {
+		return nil, fmt.Errorf("query key: %w: %v", ErrInvalidEncoding, err)
+	}
+	for _, key := range keys {
+		symmetricKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, queryKeyBytes, nil)
+		if err == nil {
+			return symmetricKey, nil
+		}
+	}
+	return nil, fmt.Errorf("%w (tried %v)", ErrWrongKey, len(keys))
+}
+
+// WrapQueryKey encrypts the symmetric key of a query with a public key, the
+// same way StrongDM remote log encryption does, so that the matching private
+// key can unwrap it with NewCipher.
+func WrapQueryKey(publicKey *rsa.PublicKey, symmetricKey []byte) (string, error) {
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, symmetricKey, nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(wrapped), nil
+}
+
+// LoadKeyring loads the private keys from a list of files separated by the
+// OS path list separator (":" on Unix). Each file may hold one or more PEM
+// blocks or a single DER encoded key.
+func LoadKeyring(privateKeyFiles string, passphrase func(string) ([]byte, error)) (Keyring, error) {
+	var keys Keyring
+	for _, path := range filepath.SplitList(privateKeyFiles) {
+		if path == "" {
+			continue
+		}
+		fileKeys, err := loadPrivateKeysFromFile(path, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no private keys found")
+	}
+	return keys, nil
+}
+
+// loadPrivateKeysFromFile accepts PKCS#1 and PKCS#8 keys, either PEM or DER
+// encoded. Encrypted PEM keys, in both the legacy OpenSSL format and as
+// encrypted PKCS#8, are decrypted with the passphrase callback.
+func loadPrivateKeysFromFile(privateKeyFile string, passphrase func(string) ([]byte, error)) ([]*rsa.PrivateKey, error) {
+	privateKeyBytes, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*rsa.PrivateKey
+	rest := privateKeyBytes
+	for {
+		var pemBlock *pem.Block
+		pemBlock, rest = pem.Decode(rest)
+		if pemBlock == nil {
+			break
+		}
+		der := pemBlock.Bytes
+		switch {
+		case pemBlock.Type == "ENCRYPTED PRIVATE KEY":
+			password, err := passphrase(privateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if der, err = decryptPKCS8(der, password); err != nil {
+				return nil, err
+			}
+		case x509.IsEncryptedPEMBlock(pemBlock):
+			// Legacy "Proc-Type: 4,ENCRYPTED" keys are deprecated but still
+			// produced by `openssl genrsa -aes256`.
+			password, err := passphrase(privateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if der, err = x509.DecryptPEMBlock(pemBlock, password); err != nil {
+				return nil, fmt.Errorf("failed to decrypt key: %w", err)
+			}
+		case !strings.HasSuffix(pemBlock.Type, "PRIVATE KEY"):
+			// Certificates and public keys may share the file.
+			continue
+		}
+		key, err := parsePrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		return keys, nil
+	}
+
+	// Not PEM, so the file may be a bare DER key.
+	key, err := parsePrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, errors.New("file does not contain a PEM or DER encoded RSA private key")
+	}
+	return []*rsa.PrivateKey{key}, nil
+}
+
+// parsePrivateKey parses a DER encoded PKCS#1 or PKCS#8 RSA private key.
+func parsePrivateKey(der []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("remote log encryption requires an RSA key, found %T", key)
+	}
+	return rsaKey, nil
+}
+
+// LoadPublicKey loads an RSA public key from a PEM file holding a PKIX or
+// PKCS#1 public key or a certificate.
+func LoadPublicKey(publicKeyFile string) (*rsa.PublicKey, error) {
+	publicKeyBytes, err := os.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	for rest := publicKeyBytes; ; {
+		var pemBlock *pem.Block
+		pemBlock, rest = pem.Decode(rest)
+		if pemBlock == nil {
+			break
+		}
+		var key interface{}
+		switch pemBlock.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(pemBlock.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(pemBlock.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(pemBlock.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("remote log encryption requires an RSA key, found %T", key)
+		}
+		return rsaKey, nil
+	}
+	return nil, errors.New("file does not contain a PEM encoded RSA public key")
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts an encrypted PKCS#8 key using PBES2 with PBKDF2 and
+// AES-CBC, which is what `openssl genpkey` and `openssl pkcs8 -topk8` write.
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption algorithm %v", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("failed to parse PBES2 parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %v", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("failed to parse PBKDF2 parameters: %w", err)
+	}
+
+	var keyLen int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLen = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported key encryption scheme %v", scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption IV: %w", err)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0 || kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 hash %v", kdf.PRF.Algorithm)
+	}
+	key, err := pbkdf2.Key(prf, string(password), kdf.Salt, kdf.IterationCount, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(info.EncryptedData) == 0 || len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, errors.New("malformed encrypted key")
+	}
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// A wrong passphrase almost always shows up as invalid PKCS#7 padding.
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, errors.New("failed to decrypt key: incorrect passphrase")
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("failed to decrypt key: incorrect passphrase")
+		}
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+// PassphraseSource returns a callback that supplies the passphrase for
+// encrypted keys. When SDM_LOG_PRIVATE_KEY_PASSPHRASE_FD names an open file
+// descriptor the passphrase is read from it once and used for every key,
+// which suits scripts and secret managers. Otherwise the user is prompted on
+// the terminal for each encrypted key file, since keys from before and after
+// a rotation need not share a passphrase.
+func PassphraseSource() func(string) ([]byte, error) {
+	var fromFD []byte
+	byFile := map[string][]byte{}
+	return func(privateKeyFile string) ([]byte, error) {
+		if fd := os.Getenv("SDM_LOG_PRIVATE_KEY_PASSPHRASE_FD"); fd != "" {
+			if fromFD == nil {
+				var err error
+				if fromFD, err = readPassphraseFD(fd); err != nil {
+					return nil, err
+				}
+			}
+			return fromFD, nil
+		}
+		// A file may hold several encrypted keys; ask once for all of them.
+		if passphrase, ok := byFile[privateKeyFile]; ok {
+			return passphrase, nil
+		}
+		passphrase, err := promptPassphrase(privateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		byFile[privateKeyFile] = passphrase
+		return passphrase, nil
+	}
+}
+
+func readPassphraseFD(fd string) ([]byte, error) {
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SDM_LOG_PRIVATE_KEY_PASSPHRASE_FD %q", fd)
+	}
+	f := os.NewFile(uintptr(n), "passphrase")
+	if f == nil {
+		return nil, fmt.Errorf("file descriptor %v is not open", n)
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
+
+// promptPassphrase reads a passphrase from the controlling terminal with echo
+// turned off.
+func promptPassphrase(privateKeyFile string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.New("key is encrypted and there is no terminal to prompt for its passphrase, set SDM_LOG_PRIVATE_KEY_PASSPHRASE_FD")
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "Passphrase for %v: ", privateKeyFile)
+	noEcho := exec.Command("stty", "-echo")
+	noEcho.Stdin = tty
+	if err := noEcho.Run(); err == nil {
+		defer func() {
+			echo := exec.Command("stty", "echo")
+			echo.Stdin = tty
+			echo.Run()
+			fmt.Fprintln(tty)
+		}()
+	}
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
@@ -0,0 +1,340 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package querycrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testPassphrase = "correct horse"
+
+var (
+	testKeysOnce sync.Once
+	testKeys     [2]*rsa.PrivateKey
+)
+
+// keys returns two RSA keys shared by the tests, since generating them is
+// slow.
+func keys(t *testing.T) [2]*rsa.PrivateKey {
+	testKeysOnce.Do(func() {
+		for i := range testKeys {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testKeys[i] = key
+		}
+	})
+	return testKeys
+}
+
+func TestLoadKeyring(t *testing.T) {
+	k := keys(t)
+	pkcs1 := func(key *rsa.PrivateKey) []byte { return x509.MarshalPKCS1PrivateKey(key) }
+	pkcs8 := func(key *rsa.PrivateKey) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&k[0].PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// files are the contents of the key files, in the order listed.
+		files [][]byte
+		want  []*rsa.PrivateKey
+	}{
+		{
+			"pkcs1 pem",
+			[][]byte{pemBlock("RSA PRIVATE KEY", pkcs1(k[0]))},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"pkcs8 pem",
+			[][]byte{pemBlock("PRIVATE KEY", pkcs8(k[0]))},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"pkcs1 der",
+			[][]byte{pkcs1(k[0])},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"pkcs8 der",
+			[][]byte{pkcs8(k[0])},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"encrypted pkcs8 with aes-256 and sha-256",
+			[][]byte{pemBlock("ENCRYPTED PRIVATE KEY", encryptPKCS8(t, pkcs8(k[0]), testPassphrase, oidHMACWithSHA256, oidAES256CBC))},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"encrypted pkcs8 with aes-128 and the default sha-1",
+			[][]byte{pemBlock("ENCRYPTED PRIVATE KEY", encryptPKCS8(t, pkcs8(k[0]), testPassphrase, nil, oidAES128CBC))},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"encrypted pkcs8 with aes-192 and sha-1",
+			[][]byte{pemBlock("ENCRYPTED PRIVATE KEY", encryptPKCS8(t, pkcs8(k[0]), testPassphrase, oidHMACWithSHA1, oidAES192CBC))},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"legacy encrypted pem",
+			[][]byte{legacyEncrypt(t, pkcs1(k[0]), testPassphrase)},
+			[]*rsa.PrivateKey{k[0]},
+		},
+		{
+			"several keys in a file, skipping a public key",
+			[][]byte{bytes.Join([][]byte{
+				pemBlock("RSA PRIVATE KEY", pkcs1(k[0])),
+				pemBlock("PUBLIC KEY", publicKey),
+				pemBlock("ENCRYPTED PRIVATE KEY", encryptPKCS8(t, pkcs8(k[1]), testPassphrase, oidHMACWithSHA256, oidAES256CBC)),
+			}, nil)},
+			[]*rsa.PrivateKey{k[0], k[1]},
+		},
+		{
+			"several files",
+			[][]byte{legacyEncrypt(t, pkcs1(k[1]), testPassphrase), pkcs8(k[0])},
+			[]*rsa.PrivateKey{k[1], k[0]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadKeyring(writeKeyFiles(t, tt.files...), staticPassphrase(testPassphrase))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LoadKeyring() loaded %v keys, want %v", len(got), len(tt.want))
+			}
+			for i, key := range got {
+				if !key.Equal(tt.want[i]) {
+					t.Errorf("key %v is not the key written", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	k := keys(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(k[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		files [][]byte
+		// err is a substring of the error expected. A wrong passphrase
+		// occasionally decrypts to valid padding and only fails to parse,
+		// so those cases expect any error.
+		err string
+	}{
+		{
+			"wrong passphrase for pkcs8",
+			[][]byte{pemBlock("ENCRYPTED PRIVATE KEY", encryptPKCS8(t, pkcs8, "wrong", oidHMACWithSHA256, oidAES256CBC))},
+			"",
+		},
+		{
+			"wrong passphrase for a legacy key",
+			[][]byte{legacyEncrypt(t, x509.MarshalPKCS1PrivateKey(k[0]), "wrong")},
+			"",
+		},
+		{
+			"not a key",
+			[][]byte{[]byte("hello")},
+			"does not contain a PEM or DER encoded RSA private key",
+		},
+		{
+			"only a public key",
+			[][]byte{pemBlock("PUBLIC KEY", []byte("ignored"))},
+			"does not contain a PEM or DER encoded RSA private key",
+		},
+		{
+			"not rsa",
+			[][]byte{pemBlock("PRIVATE KEY", ecDER)},
+			"requires an RSA key",
+		},
+		{
+			"no files",
+			nil,
+			"no private keys found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadKeyring(writeKeyFiles(t, tt.files...), staticPassphrase(testPassphrase))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("LoadKeyring() = %v keys, %v, want an error containing %q", len(got), err, tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadKeyringPassphraseError(t *testing.T) {
+	k := keys(t)
+	path := writeKeyFiles(t, legacyEncrypt(t, x509.MarshalPKCS1PrivateKey(k[0]), testPassphrase))
+	noTerminal := errors.New("no terminal")
+	_, err := LoadKeyring(path, func(string) ([]byte, error) { return nil, noTerminal })
+	if !errors.Is(err, noTerminal) {
+		t.Errorf("LoadKeyring() error = %v, want %v", err, noTerminal)
+	}
+}
+
+func TestUnwrapQueryKey(t *testing.T) {
+	k := keys(t)
+	symmetricKey := bytes.Repeat([]byte{7}, 32)
+	wrapped, err := WrapQueryKey(&k[1].PublicKey, symmetricKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second key in the keyring is tried after the first fails.
+	got, err := Keyring{k[0], k[1]}.unwrapQueryKey(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, symmetricKey) {
+		t.Errorf("unwrapQueryKey() = %x, want %x", got, symmetricKey)
+	}
+
+	if _, err := (Keyring{k[0]}).unwrapQueryKey(wrapped); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("unwrapQueryKey() with another key error = %v, want %v", err, ErrWrongKey)
+	}
+	if _, err := (Keyring{k[1]}).unwrapQueryKey("not base64!"); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("unwrapQueryKey() of bad base64 error = %v, want %v", err, ErrInvalidEncoding)
+	}
+}
+
+func pemBlock(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+// legacyEncrypt writes a PKCS#1 key in the "Proc-Type: 4,ENCRYPTED" format
+// of `openssl genrsa -aes256`.
+func legacyEncrypt(t *testing.T, der []byte, passphrase string) []byte {
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", der, []byte(passphrase), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(block)
+}
+
+// encryptPKCS8 is the inverse of decryptPKCS8. A nil prf leaves the PRF out,
+// so that it defaults to HMAC-SHA1.
+func encryptPKCS8(t *testing.T, der []byte, passphrase string, prf, scheme asn1.ObjectIdentifier) []byte {
+	keyLen := map[string]int{
+		oidAES128CBC.String(): 16,
+		oidAES192CBC.String(): 24,
+		oidAES256CBC.String(): 32,
+	}[scheme.String()]
+	var newHash func() hash.Hash = sha1.New
+	kdf := pbkdf2Params{Salt: make([]byte, 16), IterationCount: 1000}
+	if prf != nil {
+		kdf.PRF = pkix.AlgorithmIdentifier{Algorithm: prf, Parameters: asn1.NullRawValue}
+		if prf.Equal(oidHMACWithSHA256) {
+			newHash = sha256.New
+		}
+	}
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(kdf.Salt)
+	rand.Read(iv)
+
+	key, err := pbkdf2.Key(newHash, passphrase, kdf.Salt, kdf.IterationCount, keyLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(der)%aes.BlockSize
+	plaintext := append(bytes.Clone(der), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	kdfDER := marshal(t, kdf)
+	ivDER := marshal(t, iv)
+	params := marshal(t, pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfDER}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: scheme, Parameters: asn1.RawValue{FullBytes: ivDER}},
+	})
+	return marshal(t, encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: ciphertext,
+	})
+}
+
+func marshal(t *testing.T, v interface{}) []byte {
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// writeKeyFiles writes each key file and returns their paths as a list for
+// LoadKeyring.
+func writeKeyFiles(t *testing.T, files ...[]byte) string {
+	dir := t.TempDir()
+	var paths []string
+	for i, data := range files {
+		path := filepath.Join(dir, fmt.Sprint("key", i))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return strings.Join(paths, string(filepath.ListSeparator))
+}
+
+func staticPassphrase(passphrase string) func(string) ([]byte, error) {
+	return func(string) ([]byte, error) { return []byte(passphrase), nil }
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package querycrypt decrypts queries and replays recorded with StrongDM
+// remote log encryption.
+//
+// Each query is encrypted with its own AES key, which is stored with the
+// query wrapped with the organization's RSA public key. A Keyring holds the
+// private keys that may have wrapped it, and NewCipher unwraps the query key
+// once so that the query body and every replay chunk can be decrypted with
+// the resulting Cipher.
+package querycrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Decryption failures are reported as one of these errors, wrapped with
+// details, so that callers can tell a damaged record from a configuration
+// problem with errors.Is and carry on with the next record.
+var (
+	// ErrTruncated means the ciphertext is too short to hold an IV and data.
+	ErrTruncated = errors.New("ciphertext is truncated")
+	// ErrMisaligned means the ciphertext is not a whole number of AES blocks.
+	ErrMisaligned = errors.New("ciphertext is not a whole number of AES blocks")
+	// ErrWrongKey means none of the private keys can unwrap the query key.
+	ErrWrongKey = errors.New("query key was not encrypted with any of the private keys")
+	// ErrBadPadding means the plaintext padding is invalid, which usually
+	// means the data was decrypted with the wrong symmetric key.
+	ErrBadPadding = errors.New("invalid padding")
+	// ErrInvalidEncoding means a base64 field could not be decoded.
+	ErrInvalidEncoding = errors.New("invalid base64 encoding")
+	// ErrInvalidJSON means the plaintext is not the expected JSON document.
+	ErrInvalidJSON = errors.New("decrypted data is not valid JSON")
+)
+
+// Padding selects how the padding is removed after decryption.
+type Padding string
+
+const (
+	// ZeroPadding is what StrongDM remote log encryption writes: the
+	// plaintext is filled to a block boundary with NUL bytes. Only the final
+	// block is trimmed, so NUL bytes earlier in the data survive.
+	ZeroPadding Padding = "zero"
+	// PKCS7Padding requires and validates PKCS#7 padding.
+	PKCS7Padding Padding = "pkcs7"
+	// AutoPadding removes valid PKCS#7 padding if present and falls back to
+	// zero padding otherwise.
+	AutoPadding Padding = "auto"
+)
+
+// ParsePadding parses the name of a padding mode.
+func ParsePadding(s string) (Padding, error) {
+	switch mode := Padding(s); mode {
+	case ZeroPadding, PKCS7Padding, AutoPadding:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown padding %q, expected zero, pkcs7 or auto", s)
+}
+
+// Cipher decrypts the body and replay chunks of a single query. It is safe
+// for concurrent use.
+type Cipher struct {
+	block   cipher.Block
+	key     []byte
+	padding Padding
+}
+
+// NewCipher uses the organization's private keys to decrypt the symmetric
+// key of a query. This is the expensive RSA step, so do it once per query.
+func NewCipher(keys Keyring, encryptedQueryKey string, padding Padding) (*Cipher, error) {
+	symmetricKey, err := keys.unwrapQueryKey(encryptedQueryKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(symmetricKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongKey, err)
+	}
+	return &Cipher{block: block, key: symmetricKey, padding: padding}, nil
+}
+
+// Key returns the symmetric key of the query, so that it can be wrapped
+// again under a new public key with WrapQueryKey.
+func (c *Cipher) Key() []byte {
+	return c.key
+}
+
+// Decrypt demonstrates how to decrypt encrypted query/replay data with the
+// query's symmetric key. The data is the IV followed by the AES-CBC
+// ciphertext.
+func (c *Cipher) Decrypt(encryptedData []byte) (string, error) {
+	blockSize := c.block.BlockSize()
+	if len(encryptedData) < blockSize {
+		return "", fmt.Errorf("%w: %v bytes is smaller than AES block size %v", ErrTruncated, len(encryptedData), blockSize)
+	}
+	if len(encryptedData)%blockSize != 0 {
+		return "", fmt.Errorf("%w: %v bytes", ErrMisaligned, len(encryptedData))
+	}
+	iv := encryptedData[:blockSize]
+	ciphertext := encryptedData[blockSize:]
+
+	plaintext := make([]byte, len(ciphertext))
+	mode := cipher.NewCBCDecrypter(c.block, iv)
+	mode.CryptBlocks(plaintext, ciphertext)
+
+	plaintext, err := unpad(plaintext, blockSize, c.padding)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// DecryptBase64 decrypts base64 encoded data, such as the body of an
+// encrypted query.
+func (c *Cipher) DecryptBase64(encodedData string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encodedData)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return c.Decrypt(data)
+}
+
+// unpad removes the padding from a decrypted plaintext. An empty mode is
+// zero padding.
+func unpad(plaintext []byte, blockSize int, mode Padding) ([]byte, error) {
+	if mode == PKCS7Padding || mode == AutoPadding {
+		unpadded, err := unpadPKCS7(plaintext, blockSize)
+		if err == nil || mode == PKCS7Padding {
+			return unpadded, err
+		}
+	}
+	n := len(plaintext)
+	for n > 0 && len(plaintext)-n < blockSize-1 && plaintext[n-1] == 0 {
+		n--
+	}
+	return plaintext[:n], nil
+}
+
+func unpadPKCS7(plaintext []byte, blockSize int) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, fmt.Errorf("%w: no data after the IV", ErrTruncated)
+	}
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > blockSize || pad > len(plaintext) {
+		return nil, fmt.Errorf("%w: pad length %v", ErrBadPadding, pad)
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("%w: inconsistent pad bytes", ErrBadPadding)
+		}
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package querycrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnpad(t *testing.T) {
+	block := func(data string, pad byte, n int) []byte {
+		return append([]byte(data), bytes.Repeat([]byte{pad}, n)...)
+	}
+	tests := []struct {
+		name      string
+		plaintext []byte
+		mode      Padding
+		want      string
+		err       error
+	}{
+		{"zero", block("hello", 0, 11), ZeroPadding, "hello", nil},
+		{"zero default", block("hello", 0, 11), "", "hello", nil},
+		{"zero full block", []byte(strings.Repeat("a", 16)), ZeroPadding, strings.Repeat("a", 16), nil},
+		{"zero keeps NULs before the last block", block("a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00b", 0, 14), ZeroPadding, "a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00b", nil},
+		{"zero trims at most a block less one", block("", 0, 16), ZeroPadding, "\x00", nil},
+		{"zero empty", nil, ZeroPadding, "", nil},
+		{"pkcs7", block("hello", 11, 11), PKCS7Padding, "hello", nil},
+		{"pkcs7 full block of padding", block(strings.Repeat("a", 16), 16, 16), PKCS7Padding, strings.Repeat("a", 16), nil},
+		{"pkcs7 zero pad byte", block("hello", 0, 11), PKCS7Padding, "", ErrBadPadding},
+		{"pkcs7 pad longer than a block", block("hello", 17, 11), PKCS7Padding, "", ErrBadPadding},
+		{"pkcs7 inconsistent", append(block("hello", 10, 10), 11), PKCS7Padding, "", ErrBadPadding},
+		{"pkcs7 empty", nil, PKCS7Padding, "", ErrTruncated},
+		{"auto pkcs7", block("hello", 11, 11), AutoPadding, "hello", nil},
+		{"auto falls back to zero", block("hello", 0, 11), AutoPadding, "hello", nil},
+		{"auto keeps data that isn't padding", []byte("0123456789abcdeX"), AutoPadding, "0123456789abcdeX", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unpad(tt.plaintext, 16, tt.mode)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("unpad() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("unpad() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCipherDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	block := func(data string, pad byte, n int) []byte {
+		return append([]byte(data), bytes.Repeat([]byte{pad}, n)...)
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		padding Padding
+		want    string
+		err     error
+	}{
+		{"zero padded", encrypt(t, key, block("hello", 0, 11)), ZeroPadding, "hello", nil},
+		{"pkcs7 padded", encrypt(t, key, block("hello", 11, 11)), PKCS7Padding, "hello", nil},
+		{"two blocks", encrypt(t, key, block(strings.Repeat("a", 20), 0, 12)), ZeroPadding, strings.Repeat("a", 20), nil},
+		{"only an IV", make([]byte, aes.BlockSize), ZeroPadding, "", nil},
+		{"only an IV with pkcs7", make([]byte, aes.BlockSize), PKCS7Padding, "", ErrTruncated},
+		{"shorter than a block", make([]byte, 10), ZeroPadding, "", ErrTruncated},
+		{"empty", nil, ZeroPadding, "", ErrTruncated},
+		{"not whole blocks", make([]byte, 40), ZeroPadding, "", ErrMisaligned},
+		{"zero padded read as pkcs7", encrypt(t, key, block("hello", 0, 11)), PKCS7Padding, "", ErrBadPadding},
+		// The plaintext ends in what looks like PKCS#7 padding, but the zero
+		// padding after it comes last, so auto keeps it.
+		{"auto on zero padding after pad-like bytes", encrypt(t, key, block("hello\x02\x02", 0, 9)), AutoPadding, "hello\x02\x02", nil},
+		{"auto on pkcs7 padding", encrypt(t, key, block("hello", 11, 11)), AutoPadding, "hello", nil},
+		// A plaintext that fills its last block needs no zero padding, so
+		// if it ends in a pad-like byte auto can't tell it from PKCS#7.
+		{"auto on a full block ending in a pad-like byte", encrypt(t, key, []byte("0123456789abcde\x01")), AutoPadding, "0123456789abcde", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCipher(t, key, tt.padding)
+			got, err := c.Decrypt(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCipherDecryptBase64(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	c := newTestCipher(t, key, ZeroPadding)
+	got, err := c.DecryptBase64(base64.StdEncoding.EncodeToString(encrypt(t, key, []byte("SELECT 1\x00\x00\x00\x00\x00\x00\x00\x00"))))
+	if err != nil || got != "SELECT 1" {
+		t.Errorf("DecryptBase64() = %q, %v, want %q", got, err, "SELECT 1")
+	}
+	if _, err := c.DecryptBase64("not base64!"); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("DecryptBase64() of bad base64 error = %v, want %v", err, ErrInvalidEncoding)
+	}
+}
+
+func TestNewCipher(t *testing.T) {
+	k := keys(t)
+	key := bytes.Repeat([]byte{1}, 32)
+	wrapped, err := WrapQueryKey(&k[0].PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCipher(Keyring{k[1], k[0]}, wrapped, ZeroPadding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Key(), key) {
+		t.Errorf("Key() = %x, want %x", c.Key(), key)
+	}
+
+	if _, err := NewCipher(Keyring{k[1]}, wrapped, ZeroPadding); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("NewCipher() with another key error = %v, want %v", err, ErrWrongKey)
+	}
+	// A key that unwraps but isn't an AES key was wrapped by something else.
+	short, err := WrapQueryKey(&k[0].PublicKey, []byte("short"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCipher(Keyring{k[0]}, short, ZeroPadding); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("NewCipher() of a 5 byte key error = %v, want %v", err, ErrWrongKey)
+	}
+}
+
+// newTestCipher returns a Cipher for a symmetric key, without the RSA step.
+func newTestCipher(t *testing.T, key []byte, padding Padding) *Cipher {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Cipher{block: block, key: key, padding: padding}
+}
+
+// encrypt returns a random IV followed by the AES-CBC ciphertext of
+// plaintext, which must already be padded to a whole number of blocks.
+func encrypt(t *testing.T, key, plaintext []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, aes.BlockSize+len(plaintext))
+	rand.Read(data[:aes.BlockSize])
+	cipher.NewCBCEncrypter(block, data[:aes.BlockSize]).CryptBlocks(data[aes.BlockSize:], plaintext)
+	return data
+}
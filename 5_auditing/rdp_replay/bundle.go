@@ -0,0 +1,196 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// A bundle is a gzipped tar archive holding the relay logs of one RDP session
+// under logs/, followed by manifest.json describing the session and the
+// SHA-256 of every log.
+const (
+	bundleVersion      = 1
+	bundleLogDir       = "logs"
+	bundleManifestName = "manifest.json"
+)
+
+type bundleManifest struct {
+	Version      int          `json:"version"`
+	QueryID      string       `json:"queryId"`
+	AccountID    string       `json:"accountId"`
+	AccountEmail string       `json:"accountEmail"`
+	ResourceID   string       `json:"resourceId"`
+	ResourceName string       `json:"resourceName"`
+	Start        time.Time    `json:"start"`
+	End          time.Time    `json:"end"`
+	Files        []bundleFile `json:"files"`
+}
+
+type bundleFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// bundleWriter streams relay logs into a bundle, hashing them as they are
+// written so the manifest can be added at the end.
+type bundleWriter struct {
+	gz    *gzip.Writer
+	tw    *tar.Writer
+	files []bundleFile
+}
+
+func newBundleWriter(w io.Writer) *bundleWriter {
+	gz := gzip.NewWriter(w)
+	return &bundleWriter{gz: gz, tw: tar.NewWriter(gz)}
+}
+
+// AddLog adds a relay log file to the bundle.
+func (b *bundleWriter) AddLog(name string, data []byte) error {
+	if err := b.add(path.Join(bundleLogDir, name), data); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	b.files = append(b.files, bundleFile{Name: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+	return nil
+}
+
+// Close writes the manifest, with the list of files added, and finishes the
+// archive. It does not close the underlying writer.
+func (b *bundleWriter) Close(manifest bundleManifest) error {
+	manifest.Files = b.files
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := b.add(bundleManifestName, data); err != nil {
+		return err
+	}
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	return b.gz.Close()
+}
+
+func (b *bundleWriter) add(name string, data []byte) error {
+	err := b.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = b.tw.Write(data)
+	return err
+}
+
+// extractBundle unpacks the logs of a bundle into dir and verifies them
+// against the manifest. It returns the manifest and the paths of the
+// extracted logs in order.
+func extractBundle(bundlePath, dir string) (*bundleManifest, []string, error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *bundleManifest
+	hashes := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case hdr.Name == bundleManifestName:
+			manifest = &bundleManifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+		case path.Dir(hdr.Name) == bundleLogDir && hdr.Typeflag == tar.TypeReg:
+			// Only plain file names are accepted, so entries can't escape dir.
+			name := path.Base(hdr.Name)
+			sum, err := extractFile(tr, filepath.Join(dir, name))
+			if err != nil {
+				return nil, nil, err
+			}
+			hashes[name] = sum
+		default:
+			return nil, nil, fmt.Errorf("unexpected entry %q", hdr.Name)
+		}
+	}
+
+	if manifest == nil {
+		return nil, nil, errors.New("bundle has no manifest")
+	}
+	if manifest.Version != bundleVersion {
+		return nil, nil, fmt.Errorf("unsupported bundle version %v", manifest.Version)
+	}
+	var logs []string
+	for _, file := range manifest.Files {
+		sum, ok := hashes[file.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%v is listed in the manifest but missing", file.Name)
+		}
+		if sum != file.SHA256 {
+			return nil, nil, fmt.Errorf("%v does not match its SHA-256 in the manifest", file.Name)
+		}
+		delete(hashes, file.Name)
+		logs = append(logs, filepath.Join(dir, file.Name))
+	}
+	for name := range hashes {
+		return nil, nil, fmt.Errorf("%v is not listed in the manifest", name)
+	}
+	sort.Strings(logs)
+	return manifest, logs, nil
+}
+
+func extractFile(r io.Reader, dest string) (string, error) {
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), r); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
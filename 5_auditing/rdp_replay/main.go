@@ -16,6 +16,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -23,10 +24,42 @@ import (
 	"path/filepath"
 	"time"
 
-	sdm "github.com/strongdm/strongdm-sdk-go/v3"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
 )
 
+// RDP sessions are exported into portable bundles that can be replayed later,
+// on any machine with the sdm CLI:
+//
+//	rdp_replay export -resource Example -out bundles
+//	rdp_replay play bundles/<query id>.tar.gz
 func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("usage: rdp_replay export [-resource name] [-out dir] [-redact-rules file] | rdp_replay play <bundle>")
+	}
+	switch os.Args[1] {
+	case "export":
+		exportCommand(os.Args[2:])
+	case "play":
+		playCommand(os.Args[2:])
+	default:
+		log.Fatalf("unknown command %q, expected export or play", os.Args[1])
+	}
+}
+
+func exportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	resourceName := flags.String("resource", "Example", "name of the RDP resource whose sessions should be exported")
+	outDir := flags.String("out", ".", "directory to write the bundles to")
+	redactRules := flags.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flags.Parse(args)
+
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
 	//	Load the SDM API keys from the environment.
 	//	If these values are not set in your environment,
 	//	please follow the documentation here:
@@ -46,60 +79,70 @@ func main() {
 		log.Fatalf("could not create client: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("failed to create output directory: %v", err)
+	}
+
 	// You'll need an RDP resource that has had queries made against it, provide its name:
-	resourceName := "Example"
-	resourceResp, err := client.Resources().List(ctx, "name:?", resourceName)
+	resourceResp, err := client.Resources().List(ctx, "name:?", *resourceName)
 	if err != nil {
 		log.Fatalf("failed to list resources: %v", err)
 	}
 	if !resourceResp.Next() {
-		log.Fatalf("couldn't find resource named %v (error: %v)", resourceName, resourceResp.Err())
+		log.Fatalf("couldn't find resource named %v (error: %v)", *resourceName, resourceResp.Err())
 	}
 	resource := resourceResp.Value()
 
-	fmt.Printf("Queries made against %v:\n", resourceName)
+	fmt.Printf("Queries made against %v:\n", *resourceName)
+	// Accounts are looked up as they were when each query was made.
+	accounts := snapshotcache.New(client, 0)
 	queries, err := client.Queries().List(ctx, "resource_id:?", resource.GetID())
 	if err != nil {
 		log.Fatalf("failed to list queries: %v", err)
 	}
 	for queries.Next() {
 		q := queries.Value()
-		accountResp, err := client.SnapshotAt(q.Timestamp).Accounts().Get(ctx, q.AccountID)
+		account, err := accounts.Account(ctx, q.AccountID, q.Timestamp)
 		if err != nil {
 			log.Fatalf("failed to get account: %v", err)
 		}
-		user := accountResp.Account.(*sdm.User)
+		email := snapshotcache.AccountName(account)
 
 		if q.Encrypted {
-			fmt.Printf("Skipping encrypted query made by %v at %v\n", user.Email, q.Timestamp)
+			fmt.Printf("Skipping encrypted query made by %v at %v\n", email, q.Timestamp)
 			fmt.Println("See encrypted_query_replay for an example of query decryption.")
 		} else if q.ResourceType == "rdp" && q.Duration > 0 {
 			// Skipping Start query (duration = 0), as it won't have the metadata we need
 			// for the RDP replay
-			fmt.Printf("RDP query made by %v at %v\n", user.Email, q.Timestamp)
-			replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
-			if err != nil {
-				log.Fatalf("failed to scan replay: %v", err)
-			}
-
-			tempDir, err := os.MkdirTemp("", q.ID)
+			path := filepath.Join(*outDir, q.ID+".tar.gz")
+			f, err := os.Create(path)
 			if err != nil {
-				log.Fatalf("failed to create tempory directory: %v", err)
+				log.Fatalf("failed to create bundle: %v", err)
 			}
-			defer os.RemoveAll(tempDir)
+			b := newBundleWriter(f)
 
 			// Massage the query into the expected format (https://www.strongdm.com/docs/admin/logs/references/post-start/)
-			data := fmt.Sprintf(`{"type":"postStart","uuid":"%v","query":%q}`, q.ID, q.QueryBody)
+			// The query body is JSON metadata that the player reads, so
+			// secrets are masked in its string values only. The replay
+			// events are RDP protocol data rather than text, so they are
+			// copied as is.
+			redactions := redactor.Session()
+			metadata, err := redact.JSON([]byte(q.QueryBody), redactions.Text)
 			if err != nil {
-				log.Fatalf("failed to marshal query data: %v", err)
+				log.Fatalf("failed to redact query metadata: %v", err)
 			}
-
-			if err := os.WriteFile(filepath.Join(tempDir, "relay.0000000000.log"), []byte(data), 0644); err != nil {
+			data := fmt.Sprintf(`{"type":"postStart","uuid":"%v","query":%q}`, q.ID, metadata)
+			if err := b.AddLog(relayLogName(0), []byte(data)); err != nil {
 				log.Fatalf("failed to write query data: %v", err)
 			}
+
+			replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
+			if err != nil {
+				log.Fatalf("failed to scan replay: %v", err)
+			}
 			chunkId := 1
 			for replayParts.Next() {
 				chunkEvents, err := json.Marshal(replayParts.Value().Events)
@@ -109,7 +152,7 @@ func main() {
 
 				// Massage the chunk into the expected format (https://www.strongdm.com/docs/admin/logs/references/replays/)
 				chunkData := fmt.Sprintf(`{"type":"chunk","uuid":"%v","chunkId":"%v","events":%s}`, q.ID, chunkId, chunkEvents)
-				if err := os.WriteFile(filepath.Join(tempDir, fmt.Sprintf("relay.%010d.log", chunkId)), []byte(chunkData), 0644); err != nil {
+				if err := b.AddLog(relayLogName(chunkId), []byte(chunkData)); err != nil {
 					log.Fatalf("failed to write chunk data: %v", err)
 				}
 				chunkId++
@@ -117,20 +160,68 @@ func main() {
 			if err := replayParts.Err(); err != nil {
 				log.Fatalf("failed to iterate replay: %v", err)
 			}
-			logs, err := filepath.Glob(filepath.Join(tempDir, "*"))
+
+			err = b.Close(bundleManifest{
+				Version:      bundleVersion,
+				QueryID:      q.ID,
+				AccountID:    q.AccountID,
+				AccountEmail: email,
+				ResourceID:   resource.GetID(),
+				ResourceName: resource.GetName(),
+				Start:        q.Timestamp,
+				End:          q.Timestamp.Add(q.Duration),
+			})
 			if err != nil {
-				log.Fatalf("failed to glob log directory: %v", err)
+				log.Fatalf("failed to write bundle: %v", err)
 			}
-			// Run the sdm cli, this must be in the path
-			cmd := exec.Command("sdm", append([]string{"replay", "rdp", q.ID}, logs...)...)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-
-			if err := cmd.Run(); err != nil {
-				log.Fatalf("failed to execute sdm replay: %v", err)
+			if err := f.Close(); err != nil {
+				log.Fatalf("failed to write bundle: %v", err)
 			}
-
-			fmt.Println("")
+			fmt.Printf("Exported RDP session by %v at %v to %v with %v\n", email, q.Timestamp, path, redactions)
 		}
 	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+}
+
+func playCommand(args []string) {
+	if len(args) != 1 {
+		log.Fatal("usage: rdp_replay play <bundle>")
+	}
+	if err := play(args[0]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// play unpacks a bundle into a temporary directory, checks it against its
+// manifest and hands the logs to `sdm replay rdp`.
+func play(bundlePath string) error {
+	tempDir, err := os.MkdirTemp("", "rdp_replay")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifest, logs, err := extractBundle(bundlePath, tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	fmt.Printf("RDP session by %v on %v, %v to %v\n", manifest.AccountEmail, manifest.ResourceName,
+		manifest.Start.Format(time.RFC3339), manifest.End.Format(time.RFC3339))
+
+	// Run the sdm cli, this must be in the path
+	cmd := exec.Command("sdm", append([]string{"replay", "rdp", manifest.QueryID}, logs...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to execute sdm replay: %w", err)
+	}
+	return nil
+}
+
+// relayLogName returns the file name the relay would give the nth log file.
+func relayLogName(n int) string {
+	return fmt.Sprintf("relay.%010d.log", n)
 }
@@ -0,0 +1,55 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package redact
+
+// Names of the built-in detectors that a Stream also tracks across writes.
+const (
+	PrivateKey     = "private-key"
+	PasswordPrompt = "password-prompt"
+)
+
+// builtinPatterns are the built-in detectors, in the order they are listed
+// by Builtins.
+var builtinPatterns = []struct{ name, pattern string }{
+	{"aws-access-key", `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`},
+	{"aws-secret-key", `(?i)aws_?secret_?access_?key["']?[^\S\r\n]*[=:][^\S\r\n]*["']?([A-Za-z0-9/+=]{40})`},
+	{"gcp-api-key", `\bAIza[0-9A-Za-z_-]{35}`},
+	{"azure-storage-key", `(?i)AccountKey=([A-Za-z0-9+/=]{40,})`},
+	{"github-token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`},
+	{"slack-token", `\bxox[abeprs]-[A-Za-z0-9-]{10,}`},
+	{"jwt", `\beyJ[A-Za-z0-9_-]{4,}\.eyJ[A-Za-z0-9_-]{4,}\.[A-Za-z0-9_-]*`},
+	{"bearer-token", `(?i)\bbearer\s+([A-Za-z0-9._~+/-]{8,}=*)`},
+	{PrivateKey, `(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----(.*?)-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`},
+	{"url-credentials", `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`},
+	{"sql-password", `(?i)\b(?:identified\s+by|password)\s+'((?:[^']|'')*)'`},
+	{"password-assignment", `(?i)\b(?:password|passwd|pwd|secret|token|api_?key|access_?key|client_?secret)["']?[^\S\r\n]*[=:][^\S\r\n]*(?:"([^"\r\n]*)"|'([^'\r\n]*)'|([^\s"'&;,]+))`},
+	// Input that a terminal echoed after a password prompt, when it appears
+	// on the same line. Stream also catches echo that arrives keystroke by
+	// keystroke.
+	{PasswordPrompt, `(?i)\b(?:password|passphrase|passcode)(?: for [^:\r\n]{1,64})?:[^\S\r\n]*([^\s][^\r\n]*)`},
+}
+
+// Builtins returns the built-in detectors. Their names can be listed in the
+// disable section of a rules file.
+func Builtins() []Detector {
+	detectors := make([]Detector, len(builtinPatterns))
+	for i, p := range builtinPatterns {
+		rule, err := NewRule(p.name, p.pattern)
+		if err != nil {
+			panic(err)
+		}
+		detectors[i] = rule
+	}
+	return detectors
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Redacted replaces the value of a secret field.
+const Redacted = "<redacted>"
+
+// typeField records the concrete type of an entity converted by Entity.
+const typeField = "_type"
+
+// secretFields are lowercased substrings of field names whose values are
+// never printed or saved.
+var secretFields = []string{
+	"password",
+	"secret",
+	"token",
+	"privatekey",
+	"accesskey",
+	"apikey",
+	"clientkey",
+	"certificate",
+}
+
+// IsSecretField reports whether any element of a field path names a secret,
+// so that both Password and Secrets.Password are secret. Elements are
+// separated by dots and may be indexed, as in Keys[0]. Names ending in "id",
+// such as SecretStoreID, only refer to a secret and are not secret
+// themselves.
+func IsSecretField(path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		if isSecretName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	if strings.HasSuffix(name, "id") {
+		return false
+	}
+	for _, secret := range secretFields {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Entity converts an entity to the maps, slices and scalars of its JSON
+// form, keeping numbers as json.Number. The concrete type of accounts,
+// resources and nodes is recorded in a _type field. Every non-empty string
+// under a secret field, at any depth, is replaced with Redacted. If text is
+// not nil it is applied to every other string, for example to mask secrets
+// with Session.Text.
+func Entity(v interface{}, text func(string) string) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	if fields, ok := generic.(map[string]interface{}); ok {
+		fields[typeField] = reflect.Indirect(reflect.ValueOf(v)).Type().Name()
+	}
+	return redactFields(generic, false, text), nil
+}
+
+// JSON redacts the string values of a JSON document the way Entity does,
+// leaving its keys, numbers and structure intact. Redacting the document as
+// text could match across quotes and leave it invalid.
+func JSON(data []byte, text func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redactFields(generic, false, text)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// redactFields redacts v, which is under a secret field if secret is set.
+func redactFields(v interface{}, secret bool, text func(string) string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			v[name] = redactFields(field, secret || isSecretName(name), text)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactFields(item, secret, text)
+		}
+	case string:
+		switch {
+		case v == "":
+		case secret:
+			return Redacted
+		case text != nil:
+			return text(v)
+		}
+	}
+	return v
+}
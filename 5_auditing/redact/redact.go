@@ -0,0 +1,250 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact removes secrets from query bodies and session replays before
+// they leave an auditing export.
+//
+// A Redactor holds a set of detectors: the built-in ones for cloud keys,
+// tokens, private keys and passwords, and any custom rules loaded from a
+// file. Each query or session is redacted through its own Session, which
+// counts what was removed so that exporters can report it.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector finds secrets in text. Find returns the byte ranges to redact as
+// pairs of start and end offsets, like regexp.FindAllIndex.
+type Detector interface {
+	Name() string
+	Find(text []byte) [][]int
+}
+
+// Rule is a Detector built from a regular expression. If the expression has
+// capture groups, only the first group that took part in a match is redacted,
+// so that a rule such as `password=(\S+)` keeps the key and hides the value.
+type Rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// NewRule compiles a rule.
+func NewRule(name, pattern string) (*Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rule %v: %w", name, err)
+	}
+	return &Rule{name: name, re: re}, nil
+}
+
+func (r *Rule) Name() string {
+	return r.name
+}
+
+func (r *Rule) Find(text []byte) [][]int {
+	var found [][]int
+	for _, loc := range r.re.FindAllSubmatchIndex(text, -1) {
+		span := loc[:2]
+		for i := 2; i < len(loc); i += 2 {
+			if loc[i] >= 0 {
+				span = loc[i : i+2]
+				break
+			}
+		}
+		if span[0] < span[1] {
+			found = append(found, span)
+		}
+	}
+	return found
+}
+
+// Redactor applies a set of detectors. It is safe for concurrent use; the
+// counts are kept by each Session.
+type Redactor struct {
+	detectors []Detector
+}
+
+// New returns a Redactor using the given detectors.
+func New(detectors ...Detector) *Redactor {
+	return &Redactor{detectors: detectors}
+}
+
+// Config is the format of a rules file. Rules are added to the built-in
+// detectors, and built-in detectors listed in Disable are removed:
+//
+//	{
+//	  "disable": ["password-assignment"],
+//	  "rules": [
+//	    {"name": "internal-token", "pattern": "itk_[0-9a-f]{32}"},
+//	    {"name": "ssn", "pattern": "ssn=(\\d{3}-\\d{2}-\\d{4})"}
+//	  ]
+//	}
+type Config struct {
+	Disable []string `json:"disable"`
+	Rules   []struct {
+		Name    string `json:"name"`
+		Pattern string `json:"pattern"`
+	} `json:"rules"`
+}
+
+// Load returns a Redactor with the built-in detectors and the rules in the
+// file at path. An empty path uses the built-in detectors alone.
+func Load(path string) (*Redactor, error) {
+	if path == "" {
+		return New(Builtins()...), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+	}
+
+	disabled := map[string]bool{}
+	for _, name := range config.Disable {
+		disabled[name] = true
+	}
+	var detectors []Detector
+	for _, d := range Builtins() {
+		if disabled[d.Name()] {
+			delete(disabled, d.Name())
+			continue
+		}
+		detectors = append(detectors, d)
+	}
+	for name := range disabled {
+		return nil, fmt.Errorf("%v: no built-in detector named %q", path, name)
+	}
+	for _, r := range config.Rules {
+		if r.Name == "" || r.Pattern == "" {
+			return nil, fmt.Errorf("%v: rules need a name and a pattern", path)
+		}
+		rule, err := NewRule(r.Name, r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+		detectors = append(detectors, rule)
+	}
+	return New(detectors...), nil
+}
+
+// has reports whether the detector with the given name is enabled.
+func (r *Redactor) has(name string) bool {
+	for _, d := range r.detectors {
+		if d.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Session returns a Session that counts the redactions made in one query or
+// replay.
+func (r *Redactor) Session() *Session {
+	return &Session{r: r, counts: map[string]int{}}
+}
+
+// Session redacts the text of one query or replay and counts what it
+// removed, by detector.
+type Session struct {
+	r      *Redactor
+	counts map[string]int
+}
+
+// Text returns s with every secret replaced by [REDACTED:<detector>].
+func (s *Session) Text(text string) string {
+	return string(s.redact([]byte(text), func(out []byte, name string, _ []byte) []byte {
+		return append(out, "[REDACTED:"+name+"]"...)
+	}))
+}
+
+// match is a range of text found by a detector.
+type match struct {
+	start, end int
+	name       string
+}
+
+// redact finds secrets in text and replaces each with the output of replace.
+// Overlapping matches are merged and counted once, under the detector of the
+// one that starts first.
+func (s *Session) redact(text []byte, replace func(out []byte, name string, secret []byte) []byte) []byte {
+	var matches []match
+	for _, d := range s.r.detectors {
+		for _, loc := range d.Find(text) {
+			matches = append(matches, match{loc[0], loc[1], d.Name()})
+		}
+	}
+	if len(matches) == 0 {
+		return text
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	out := make([]byte, 0, len(text))
+	pos := 0
+	for i := 0; i < len(matches); {
+		m := matches[i]
+		for i++; i < len(matches) && matches[i].start < m.end; i++ {
+			m.end = max(m.end, matches[i].end)
+		}
+		out = append(out, text[pos:m.start]...)
+		out = replace(out, m.name, text[m.start:m.end])
+		pos = m.end
+		s.counts[m.name]++
+	}
+	return append(out, text[pos:]...)
+}
+
+// Total returns the number of redactions made.
+func (s *Session) Total() int {
+	total := 0
+	for _, n := range s.counts {
+		total += n
+	}
+	return total
+}
+
+// Counts returns the number of redactions made by each detector.
+func (s *Session) Counts() map[string]int {
+	return s.counts
+}
+
+// String summarizes the redactions, for example
+// "3 redactions (aws-access-key 1, jwt 2)".
+func (s *Session) String() string {
+	total := s.Total()
+	if total == 0 {
+		return "no redactions"
+	}
+	names := make([]string, 0, len(s.counts))
+	for name := range s.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%v %v", name, s.counts[name])
+	}
+	noun := "redactions"
+	if total == 1 {
+		noun = "redaction"
+	}
+	return fmt.Sprintf("%v %v (%v)", total, noun, strings.Join(names, ", "))
+}
@@ -0,0 +1,181 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package redact
+
+import (
+	"bytes"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Limits on how much output a Stream holds back.
+const (
+	// bulkWrite is the smallest write without a line break that is treated
+	// as program output rather than the echo of a keystroke or a line
+	// editor redraw.
+	bulkWrite = 16
+	// maxHold is the longest run of token characters held back.
+	maxHold = 4096
+	// maxLine is how much of the current line is kept to detect prompts.
+	maxLine = 256
+)
+
+var (
+	keyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`)
+	keyEnd   = []byte("-----END ")
+	prompt   = regexp.MustCompile(`(?i)\b(?:password|passphrase|passcode|pin)(?: for [^:\r\n]{1,64})?:[^\S\r\n]*$`)
+)
+
+// Stream redacts terminal output that arrives in pieces, such as the events
+// of an SSH replay.
+//
+// Secrets are masked with one * per character, keeping line breaks, so that
+// the cursor movements around them still line up when the session is
+// played back. A secret can be split between two events, so when a large
+// write, or one that completes a line, ends in the middle of a run of
+// characters that could be part of a key or token, that run is held back and
+// released with the next write. A private key printed over many events is
+// masked until its END line, and input echoed after a password prompt is
+// masked until the line ends.
+type Stream struct {
+	s    *Session
+	held []byte
+	// line is the end of the current line as written, to detect prompts.
+	line  []byte
+	inKey bool
+	echo  bool
+	// echoed is set once input has been masked after a prompt.
+	echoed bool
+}
+
+// Stream returns a Stream that counts its redactions in s.
+func (s *Session) Stream() *Stream {
+	return &Stream{s: s}
+}
+
+// Write returns the redacted form of as much of p as can be released.
+func (st *Stream) Write(p []byte) []byte {
+	data := append(st.held, p...)
+	st.held = nil
+	if (len(p) >= bulkWrite || bytes.ContainsAny(p, "\r\n")) && !st.inKey && !st.echo {
+		i := len(data)
+		for i > 0 && len(data)-i < maxHold && isTokenByte(data[i-1]) {
+			i--
+		}
+		if len(data)-i < maxHold {
+			st.held = append([]byte(nil), data[i:]...)
+			data = data[:i]
+		}
+	}
+	return st.redact(data)
+}
+
+// Flush returns whatever is held back, redacted.
+func (st *Stream) Flush() []byte {
+	data := st.held
+	st.held = nil
+	out := st.redact(data)
+	if st.echoed {
+		st.s.counts[PasswordPrompt]++
+		st.echoed = false
+	}
+	return out
+}
+
+func (st *Stream) redact(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		switch {
+		case st.inKey:
+			n := bytes.Index(data, keyEnd)
+			if n < 0 {
+				n = len(data)
+			} else {
+				st.inKey = false
+			}
+			out = mask(out, data[:n])
+			data = data[n:]
+
+		case st.echo:
+			n := bytes.IndexAny(data, "\r\n")
+			if n < 0 {
+				n = len(data)
+			} else {
+				st.echo = false
+			}
+			if n > 0 {
+				st.echoed = true
+			}
+			out = mask(out, data[:n])
+			data = data[n:]
+			if !st.echo && st.echoed {
+				st.s.counts[PasswordPrompt]++
+				st.echoed = false
+			}
+
+		default:
+			// Redact up to the end of a private key header whose END line
+			// has not been written yet, or to the end of the data.
+			n, opensKey := len(data), false
+			if st.s.r.has(PrivateKey) {
+				if loc := keyBegin.FindIndex(data); loc != nil && !bytes.Contains(data[loc[1]:], keyEnd) {
+					n, opensKey = loc[1], true
+				}
+			}
+			out = append(out, st.s.redact(data[:n], func(out []byte, _ string, secret []byte) []byte {
+				return mask(out, secret)
+			})...)
+			data = data[n:]
+			if opensKey {
+				st.inKey = true
+				st.s.counts[PrivateKey]++
+			}
+		}
+	}
+
+	if i := bytes.LastIndexAny(out, "\r\n"); i >= 0 {
+		st.line = append(st.line[:0], out[i+1:]...)
+	} else {
+		st.line = append(st.line, out...)
+	}
+	if len(st.line) > maxLine {
+		st.line = append(st.line[:0], st.line[len(st.line)-maxLine:]...)
+	}
+	if !st.inKey && !st.echo && st.s.r.has(PasswordPrompt) && prompt.Match(st.line) {
+		st.echo = true
+	}
+	return out
+}
+
+// mask appends secret to out with every character but line breaks replaced
+// by *.
+func mask(out, secret []byte) []byte {
+	for len(secret) > 0 {
+		r, n := utf8.DecodeRune(secret)
+		if r == '\r' || r == '\n' {
+			out = append(out, byte(r))
+		} else {
+			out = append(out, '*')
+		}
+		secret = secret[n:]
+	}
+	return out
+}
+
+// isTokenByte reports whether c can appear in a key or token, including the
+// separators of JWTs and base64.
+func isTokenByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '+' || c == '/' || c == '=' || c == '_' || c == '-' || c == '.'
+}
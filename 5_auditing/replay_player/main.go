@@ -0,0 +1,209 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+func main() {
+	log.SetFlags(0)
+	queryID := flag.String("query", "", "ID of the query to replay")
+	resourceName := flag.String("resource", "Example", "when no query is given, list the replayable queries made against this resource")
+	speed := flag.Float64("speed", 1, "initial playback speed")
+	idleLimit := flag.Duration("idle-limit", 2*time.Second, "cap pauses between events to this duration (0 disables)")
+	start := flag.Duration("start", 0, "offset into the session to start playing from")
+	flag.Parse()
+	if *speed <= 0 {
+		log.Fatalf("invalid -speed %v: must be greater than 0", *speed)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Encrypted replays can be played when the private key configured for
+	// StrongDM remote log encryption is available.
+	// SDM_LOG_PRIVATE_KEY_FILE lists the current and any previous keys,
+	// as described in encrypted_query_replay.
+	var privateKeys querycrypt.Keyring
+	if privateKeyFile := os.Getenv("SDM_LOG_PRIVATE_KEY_FILE"); privateKeyFile != "" {
+		var err error
+		privateKeys, err = querycrypt.LoadKeyring(privateKeyFile, querycrypt.PassphraseSource())
+		if err != nil {
+			log.Fatalf("failed to load private key: %v", err)
+		}
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *queryID == "" {
+		if err := listReplayableQueries(ctx, client, *resourceName); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	queries, err := client.Queries().List(ctx, "id:?", *queryID)
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+	if !queries.Next() {
+		log.Fatalf("couldn't find query %v (error: %v)", *queryID, queries.Err())
+	}
+	q := queries.Value()
+
+	tl, err := loadTimeline(ctx, client, q, privateKeys)
+	if err != nil {
+		log.Fatalf("failed to load replay: %v", err)
+	}
+	fmt.Printf("Replaying query made by %v at %v (%v)\n", q.AccountEmail, q.Timestamp, tl.Length().Round(time.Second))
+	fmt.Println("space: play/pause  1/2/4/8: speed  n: next burst  g: seek  q: quit")
+
+	p := &player{
+		timeline:  tl,
+		out:       os.Stdout,
+		speed:     *speed,
+		idleLimit: *idleLimit,
+	}
+	if err := p.Run(ctx, os.Stdin, *start); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("replay failed: %v", err)
+	}
+	fmt.Println("")
+}
+
+// listReplayableQueries prints the ID of every query against the named
+// resource that has a replay.
+func listReplayableQueries(ctx context.Context, client *sdm.Client, resourceName string) error {
+	resourceResp, err := client.Resources().List(ctx, "name:?", resourceName)
+	if err != nil {
+		return fmt.Errorf("failed to list resources: %w", err)
+	}
+	if !resourceResp.Next() {
+		return fmt.Errorf("couldn't find resource named %v (error: %v)", resourceName, resourceResp.Err())
+	}
+	resource := resourceResp.Value()
+
+	fmt.Printf("Replayable queries made against %v:\n", resourceName)
+	queries, err := client.Queries().List(ctx, "resource_id:?", resource.GetID())
+	if err != nil {
+		return fmt.Errorf("failed to list queries: %w", err)
+	}
+	for queries.Next() {
+		q := queries.Value()
+		if q.Replayable || q.Encrypted {
+			fmt.Printf("%v\t%v\t%v\tencrypted=%v\n", q.ID, q.Timestamp, q.AccountEmail, q.Encrypted)
+		}
+	}
+	if err := queries.Err(); err != nil {
+		return fmt.Errorf("failed to iterate queries: %w", err)
+	}
+	fmt.Println("Run again with -query <id> to play one of them.")
+	return nil
+}
+
+// loadTimeline fetches every replay chunk of a query, decrypting them first
+// if the query was recorded with remote log encryption enabled.
+func loadTimeline(ctx context.Context, client *sdm.Client, q *sdm.Query, privateKeys querycrypt.Keyring) (*timeline, error) {
+	// The query key is unwrapped once and used for the body and every
+	// replay chunk.
+	var qc *querycrypt.Cipher
+	if q.Encrypted {
+		if privateKeys == nil {
+			return nil, errors.New("query is encrypted, set SDM_LOG_PRIVATE_KEY_FILE to decrypt it")
+		}
+		var err error
+		qc, err = querycrypt.NewCipher(privateKeys, q.QueryKey, querycrypt.ZeroPadding)
+		if err != nil {
+			return nil, err
+		}
+		q.QueryBody, err = qc.DecryptBase64(q.QueryBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt query body: %w", err)
+		}
+		var capture struct{ Type string }
+		if err := json.Unmarshal([]byte(q.QueryBody), &capture); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal query JSON: %w", err)
+		}
+		q.Replayable = capture.Type == "shell" || kube.CaptureOf(q).IsSession()
+	}
+	if !q.Replayable {
+		return nil, fmt.Errorf("query %v is not replayable", q.ID)
+	}
+
+	tl := &timeline{}
+	k8s := kube.StreamFor(q)
+	replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan replay: %w", err)
+	}
+	for replayParts.Next() {
+		part := replayParts.Value()
+		if qc != nil {
+			partData, err := qc.Decrypt(part.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt replay data: %w", err)
+			}
+			var events []struct {
+				Data     []byte
+				Duration int64
+			}
+			if err := json.Unmarshal([]byte(partData), &events); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal events JSON: %w", err)
+			}
+			for _, e := range events {
+				part.Events = append(part.Events, &sdm.ReplayChunkEvent{
+					Data:     e.Data,
+					Duration: time.Millisecond * time.Duration(e.Duration),
+				})
+			}
+		}
+		for _, ev := range part.Events {
+			tl.Append(&sdm.ReplayChunkEvent{Data: k8s.Write(ev.Data), Duration: ev.Duration})
+		}
+	}
+	if err := replayParts.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate replay: %w", err)
+	}
+	if end := k8s.Flush(); len(end) > 0 {
+		tl.Append(&sdm.ReplayChunkEvent{Data: end})
+	}
+	return tl, nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// burstGap is the minimum pause before a run of output for it to count as a
+// new burst of activity when skipping ahead.
+const burstGap = time.Second
+
+// timedEvent is a replay event positioned at its offset from the start of the
+// session.
+type timedEvent struct {
+	At   time.Duration
+	Data []byte
+}
+
+// timeline holds a whole session so it can be played from any offset.
+type timeline struct {
+	events []timedEvent
+	end    time.Duration
+}
+
+// Append adds a replay event. Replay events carry the delay that follows
+// them, so each event starts where the previous delays end.
+func (tl *timeline) Append(ev *sdm.ReplayChunkEvent) {
+	tl.events = append(tl.events, timedEvent{At: tl.end, Data: ev.Data})
+	tl.end += ev.Duration
+}
+
+// Length returns the total duration of the session.
+func (tl *timeline) Length() time.Duration {
+	return tl.end
+}
+
+// indexAt returns the index of the first event at or after offset.
+func (tl *timeline) indexAt(offset time.Duration) int {
+	return sort.Search(len(tl.events), func(i int) bool {
+		return tl.events[i].At >= offset
+	})
+}
+
+// nextBurst returns the index of the first event after from that follows a
+// pause of at least burstGap, or the number of events if there is none.
+func (tl *timeline) nextBurst(from int) int {
+	for i := from + 1; i < len(tl.events); i++ {
+		if tl.events[i].At-tl.events[i-1].At >= burstGap {
+			return i
+		}
+	}
+	return len(tl.events)
+}
+
+// player plays a timeline to a terminal, reacting to single key presses.
+type player struct {
+	timeline  *timeline
+	out       io.Writer
+	speed     float64
+	idleLimit time.Duration
+
+	pos    int           // index of the next event to print
+	clock  time.Duration // session time that has been played so far
+	paused bool
+}
+
+// Run plays the timeline from start until it ends, the user quits or ctx is
+// cancelled. Keys are read from in, which is switched to unbuffered mode for
+// the duration of the replay when it is a terminal.
+func (p *player) Run(ctx context.Context, in *os.File, start time.Duration) error {
+	restore, err := rawMode(in)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	keys := make(chan byte)
+	go readKeys(in, keys)
+
+	p.seek(start)
+	for p.pos < len(p.timeline.events) {
+		var timer *time.Timer
+		var fire <-chan time.Time
+		waitStart := time.Now()
+		if !p.paused {
+			timer = time.NewTimer(p.delay())
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-fire:
+			p.emit()
+		case k, ok := <-keys:
+			if timer != nil {
+				timer.Stop()
+				p.advance(time.Since(waitStart))
+			}
+			if !ok {
+				// Input is closed, keep playing without controls. Nothing
+				// could resume a paused replay, so resume it now.
+				keys = nil
+				p.paused = false
+				continue
+			}
+			if quit := p.handleKey(k, keys); quit {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// delay returns how long to wait in real time before the next event.
+func (p *player) delay() time.Duration {
+	gap := p.timeline.events[p.pos].At - p.clock
+	if p.idleLimit > 0 && gap > p.idleLimit {
+		gap = p.idleLimit
+	}
+	return time.Duration(float64(gap) / p.speed)
+}
+
+// advance moves the session clock forward after waiting for real time d
+// without reaching the next event.
+func (p *player) advance(d time.Duration) {
+	next := p.timeline.events[p.pos].At
+	p.clock += time.Duration(float64(d) * p.speed)
+	if p.clock > next {
+		p.clock = next
+	}
+}
+
+// emit prints the next event.
+func (p *player) emit() {
+	ev := p.timeline.events[p.pos]
+	p.out.Write(ev.Data)
+	p.clock = ev.At
+	p.pos++
+}
+
+// seek positions the replay at offset. Terminal output can't be rewound, so
+// seeking backwards resets the screen and redraws from the start.
+func (p *player) seek(offset time.Duration) {
+	if offset < p.clock {
+		io.WriteString(p.out, "\x1bc")
+		p.pos = 0
+	}
+	p.fastForward(p.timeline.indexAt(offset))
+	p.clock = offset
+}
+
+// fastForward prints every event up to, but not including, index to without
+// waiting.
+func (p *player) fastForward(to int) {
+	var buf bytes.Buffer
+	for ; p.pos < to; p.pos++ {
+		buf.Write(p.timeline.events[p.pos].Data)
+	}
+	p.out.Write(buf.Bytes())
+	if to < len(p.timeline.events) {
+		p.clock = p.timeline.events[to].At
+	} else {
+		p.clock = p.timeline.end
+	}
+}
+
+// handleKey applies a single key press and reports whether playback should
+// stop.
+func (p *player) handleKey(k byte, keys <-chan byte) bool {
+	switch k {
+	case 'q', 'Q':
+		return true
+	case ' ':
+		p.paused = !p.paused
+	case '1', '2', '4', '8':
+		p.speed = float64(k - '0')
+	case '+':
+		p.speed *= 2
+	case '-':
+		p.speed /= 2
+	case 'n', 'N':
+		p.fastForward(p.timeline.nextBurst(p.pos))
+	case 'g', 'G':
+		if offset, ok := p.promptOffset(keys); ok {
+			p.seek(offset)
+		}
+	}
+	return false
+}
+
+// promptOffset reads an offset such as "90", "90s" or "1h2m" terminated by
+// Enter. Escape cancels the prompt.
+func (p *player) promptOffset(keys <-chan byte) (time.Duration, bool) {
+	fmt.Fprintf(p.out, "\r\n\x1b[7mseek to (of %v):\x1b[0m ", p.timeline.Length().Round(time.Second))
+	var input []byte
+	for k := range keys {
+		switch {
+		case k == '\r' || k == '\n':
+			return parseOffset(string(input))
+		case k == 0x1b:
+			return 0, false
+		case k == 0x7f || k == '\b':
+			if len(input) > 0 {
+				input = input[:len(input)-1]
+				io.WriteString(p.out, "\b \b")
+			}
+		default:
+			input = append(input, k)
+			p.out.Write([]byte{k})
+		}
+	}
+	return 0, false
+}
+
+// parseOffset accepts a Go duration or a plain number of seconds.
+func parseOffset(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), secs >= 0
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d >= 0
+}
+
+// readKeys sends every byte read from in to keys, closing keys when in is
+// exhausted.
+func readKeys(in io.Reader, keys chan<- byte) {
+	defer close(keys)
+	buf := make([]byte, 1)
+	for {
+		if _, err := in.Read(buf); err != nil {
+			return
+		}
+		keys <- buf[0]
+	}
+}
+
+// rawMode disables line buffering and echo on a terminal so that single key
+// presses reach the player. It returns a function that restores the previous
+// settings. Interrupt handling is left enabled so Ctrl-C still works.
+func rawMode(in *os.File) (func(), error) {
+	if fi, err := in.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return func() {}, nil
+	}
+	saved, err := stty(in, "-g")
+	if err != nil {
+		// Not a terminal stty understands, play without controls.
+		return func() {}, nil
+	}
+	if _, err := stty(in, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, fmt.Errorf("failed to configure terminal: %w", err)
+	}
+	return func() { stty(in, strings.TrimSpace(saved)) }, nil
+}
+
+func stty(in *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = in
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,147 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// newTimeline returns a timeline of events named a, b, c, ... each followed
+// by the given delay.
+func newTimeline(delays ...time.Duration) *timeline {
+	tl := &timeline{}
+	for i, d := range delays {
+		tl.Append(&sdm.ReplayChunkEvent{Data: []byte{'a' + byte(i)}, Duration: d})
+	}
+	return tl
+}
+
+func TestTimelineBursts(t *testing.T) {
+	// Events at 0, 100ms, 2.1s, 2.6s and 5.6s.
+	tl := newTimeline(100*time.Millisecond, 2*time.Second, 500*time.Millisecond, 3*time.Second, time.Second)
+	if tl.Length() != 6600*time.Millisecond {
+		t.Errorf("Length() = %v, want 6.6s", tl.Length())
+	}
+	tests := []struct {
+		from, want int
+	}{
+		{0, 2},
+		{1, 2},
+		{2, 4},
+		{4, 5},
+	}
+	for _, tt := range tests {
+		if got := tl.nextBurst(tt.from); got != tt.want {
+			t.Errorf("nextBurst(%v) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+	indexes := []struct {
+		offset time.Duration
+		want   int
+	}{
+		{0, 0},
+		{100 * time.Millisecond, 1},
+		{time.Second, 2},
+		{6 * time.Second, 5},
+	}
+	for _, tt := range indexes {
+		if got := tl.indexAt(tt.offset); got != tt.want {
+			t.Errorf("indexAt(%v) = %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	var out bytes.Buffer
+	p := &player{timeline: newTimeline(time.Second, time.Second, time.Second), out: &out, speed: 1}
+	tests := []struct {
+		offset time.Duration
+		want   string
+		pos    int
+	}{
+		{1500 * time.Millisecond, "ab", 2},
+		{2 * time.Second, "", 2},
+		{5 * time.Second, "c", 3},
+		// Seeking backwards redraws from the start.
+		{time.Second, "\x1bca", 1},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		p.seek(tt.offset)
+		if out.String() != tt.want || p.pos != tt.pos || p.clock != tt.offset {
+			t.Errorf("seek(%v) printed %q at %v, %v, want %q at %v, %v", tt.offset, out.String(), p.pos, p.clock, tt.want, tt.pos, tt.offset)
+		}
+	}
+
+	// Skipping to the next burst prints the events before it.
+	out.Reset()
+	p.handleKey('n', nil)
+	if out.String() != "b" || p.pos != 2 || p.clock != 2*time.Second {
+		t.Errorf("next burst printed %q at %v, %v, want %q at 2, 2s", out.String(), p.pos, p.clock, "b")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	tl := newTimeline(time.Second, time.Minute, time.Second)
+	tests := []struct {
+		name      string
+		pos       int
+		clock     time.Duration
+		speed     float64
+		idleLimit time.Duration
+		want      time.Duration
+	}{
+		{"next event", 1, 0, 1, 0, time.Second},
+		{"part played", 1, 400 * time.Millisecond, 1, 0, 600 * time.Millisecond},
+		{"faster", 1, 0, 4, 0, 250 * time.Millisecond},
+		{"idle gap", 2, time.Second, 1, 0, time.Minute},
+		{"idle gap capped", 2, time.Second, 1, 2 * time.Second, 2 * time.Second},
+		{"capped then faster", 2, time.Second, 2, 2 * time.Second, time.Second},
+		{"under the cap", 1, 0, 1, 2 * time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		p := &player{timeline: tl, speed: tt.speed, idleLimit: tt.idleLimit, pos: tt.pos, clock: tt.clock}
+		if got := p.delay(); got != tt.want {
+			t.Errorf("%v: delay() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunInputClosedWhilePaused(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// Pause, then close the input.
+	w.Write([]byte(" "))
+	w.Close()
+
+	var out bytes.Buffer
+	p := &player{timeline: newTimeline(time.Millisecond, time.Millisecond, time.Millisecond), out: &out, speed: 1}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Run(ctx, r, 0); err != nil {
+		t.Fatalf("Run() = %v, want the replay to finish", err)
+	}
+	if out.String() != "abc" {
+		t.Errorf("Run() printed %q, want %q", out.String(), "abc")
+	}
+}
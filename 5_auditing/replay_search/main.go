@@ -0,0 +1,248 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/querycrypt"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Searches the output of SSH and kubectl exec sessions for a regular
+// expression and reports where each match happened, for example every
+// session in the last week in which someone became root or printed a private
+// key. Secrets are masked before searching, so they never appear in the
+// results:
+//
+//	replay_search -from 168h -context 2 'sudo su|PRIVATE KEY-----'
+func main() {
+	log.SetFlags(0)
+	from := flag.String("from", "", "start of the time range, RFC 3339 or a duration ago such as 24h (default 24h)")
+	to := flag.String("to", "", "end of the time range, RFC 3339 or a duration ago (default now)")
+	resourceName := flag.String("resource", "", "only search sessions on the resource with this name")
+	filter := flag.String("filter", "", "additional query filter, for example account_id:a-1234")
+	contextLines := flag.Int("context", 0, "lines of context to print around each match")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: replay_search [flags] <regexp>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	re, err := regexp.Compile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("invalid pattern: %v", err)
+	}
+
+	now := time.Now()
+	start, err := timeflag.Parse(*from, now, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end, err := timeflag.Parse(*to, now, now)
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Encrypted sessions are searched too when the private key configured
+	// for StrongDM remote log encryption is available.
+	// SDM_LOG_PRIVATE_KEY_FILE lists the current and any previous keys,
+	// as described in encrypted_query_replay.
+	var privateKeys querycrypt.Keyring
+	if path := os.Getenv("SDM_LOG_PRIVATE_KEY_FILE"); path != "" {
+		privateKeys, err = querycrypt.LoadKeyring(path, querycrypt.PassphraseSource())
+		if err != nil {
+			log.Fatalf("failed to load private key: %v", err)
+		}
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	queryFilter := "after:? before:? " + *filter
+	args := []interface{}{start, end}
+	if *resourceName != "" {
+		resources, err := client.Resources().List(ctx, "name:?", *resourceName)
+		if err != nil {
+			log.Fatalf("failed to list resources: %v", err)
+		}
+		if !resources.Next() {
+			log.Fatalf("couldn't find resource named %v (error: %v)", *resourceName, resources.Err())
+		}
+		queryFilter += " resource_id:?"
+		args = append(args, resources.Value().GetID())
+	}
+
+	queries, err := client.Queries().List(ctx, strings.TrimSpace(queryFilter), args...)
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+	searched, matched, skipped := 0, 0, 0
+	for queries.Next() {
+		q := queries.Value()
+		if !q.Replayable && !q.Encrypted {
+			continue
+		}
+		redactions := redactor.Session()
+		t, err := loadTranscript(ctx, client, q, privateKeys, redactions.Stream())
+		if errors.Is(err, errNotReplayable) {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping query %v: %v\n", q.ID, err)
+			skipped++
+			continue
+		}
+		searched++
+		if redactions.Total() > 0 {
+			log.Printf("Session %v: %v", q.ID, redactions)
+		}
+		hits := t.Search(re, *contextLines)
+		if len(hits) > 0 {
+			matched++
+		}
+		for _, h := range hits {
+			printHit(q, h, *contextLines > 0)
+		}
+	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+	log.Printf("Searched %v sessions, %v matched, %v skipped", searched, matched, skipped)
+}
+
+// printHit prints the session a match was found in and the lines around it,
+// marking the matching lines with >.
+func printHit(q *sdm.Query, h hit, separate bool) {
+	fmt.Printf("%v %v %v %v +%v\n", q.ID, q.AccountEmail, q.ResourceName,
+		q.Timestamp.UTC().Format(time.RFC3339), h.Offset.Round(100*time.Millisecond))
+	for _, line := range h.Before {
+		fmt.Printf("  %v\n", line)
+	}
+	for _, line := range h.Lines {
+		fmt.Printf("> %v\n", line)
+	}
+	for _, line := range h.After {
+		fmt.Printf("  %v\n", line)
+	}
+	if separate {
+		fmt.Println("--")
+	}
+}
+
+var errNotReplayable = errors.New("query is not replayable")
+
+// loadTranscript fetches every replay chunk of a query, decrypting them
+// first if the query was recorded with remote log encryption enabled, and
+// masks secrets in them with stream.
+func loadTranscript(ctx context.Context, client *sdm.Client, q *sdm.Query, privateKeys querycrypt.Keyring, stream *redact.Stream) (*transcript, error) {
+	// The query key is unwrapped once and used for the body and every
+	// replay chunk.
+	var qc *querycrypt.Cipher
+	if q.Encrypted {
+		if privateKeys == nil {
+			return nil, errors.New("query is encrypted, set SDM_LOG_PRIVATE_KEY_FILE to decrypt it")
+		}
+		var err error
+		qc, err = querycrypt.NewCipher(privateKeys, q.QueryKey, querycrypt.ZeroPadding)
+		if err != nil {
+			return nil, err
+		}
+		q.QueryBody, err = qc.DecryptBase64(q.QueryBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt query body: %w", err)
+		}
+		var capture struct{ Type string }
+		if err := json.Unmarshal([]byte(q.QueryBody), &capture); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal query JSON: %w", err)
+		}
+		q.Replayable = capture.Type == "shell" || kube.CaptureOf(q).IsSession()
+	}
+	if !q.Replayable {
+		return nil, errNotReplayable
+	}
+
+	t := &transcript{}
+	k8s := kube.StreamFor(q)
+	replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan replay: %w", err)
+	}
+	for replayParts.Next() {
+		part := replayParts.Value()
+		if qc != nil {
+			partData, err := qc.Decrypt(part.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt replay data: %w", err)
+			}
+			var events []struct {
+				Data     []byte
+				Duration int64
+			}
+			if err := json.Unmarshal([]byte(partData), &events); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal events JSON: %w", err)
+			}
+			for _, e := range events {
+				part.Events = append(part.Events, &sdm.ReplayChunkEvent{
+					Data:     e.Data,
+					Duration: time.Millisecond * time.Duration(e.Duration),
+				})
+			}
+		}
+		for _, ev := range part.Events {
+			t.Append(&sdm.ReplayChunkEvent{Data: stream.Write(k8s.Write(ev.Data)), Duration: ev.Duration})
+		}
+	}
+	if err := replayParts.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate replay: %w", err)
+	}
+	t.Append(&sdm.ReplayChunkEvent{Data: append(stream.Write(k8s.Flush()), stream.Flush()...)})
+	return t, nil
+}
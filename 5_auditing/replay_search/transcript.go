@@ -0,0 +1,166 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// transcript is the text of a session reassembled from its replay events,
+// with terminal escape sequences and carriage returns removed so that a
+// pattern can match what was displayed rather than how it was drawn. It
+// remembers when each event started so a position in the text can be turned
+// back into an offset into the session.
+type transcript struct {
+	text  []byte
+	marks []mark
+	clock time.Duration
+	state escapeState
+}
+
+type mark struct {
+	pos int
+	at  time.Duration
+}
+
+type escapeState int
+
+const (
+	plain     escapeState = iota
+	escape                // after ESC
+	csi                   // in ESC [ ... final byte
+	osc                   // in ESC ] ... BEL or ESC \
+	oscEscape             // after ESC inside an OSC
+)
+
+// Append adds a replay event. Replay events carry the delay that follows
+// them, so the event starts at the sum of the delays before it.
+func (t *transcript) Append(ev *sdm.ReplayChunkEvent) {
+	t.marks = append(t.marks, mark{pos: len(t.text), at: t.clock})
+	for _, c := range ev.Data {
+		t.feed(c)
+	}
+	t.clock += ev.Duration
+}
+
+func (t *transcript) feed(c byte) {
+	switch t.state {
+	case escape:
+		switch c {
+		case '[':
+			t.state = csi
+		case ']':
+			t.state = osc
+		default:
+			t.state = plain
+		}
+	case csi:
+		if c >= 0x40 && c <= 0x7e {
+			t.state = plain
+		}
+	case osc:
+		switch c {
+		case 0x07:
+			t.state = plain
+		case 0x1b:
+			t.state = oscEscape
+		}
+	case oscEscape:
+		t.state = plain
+	default:
+		switch c {
+		case 0x1b:
+			t.state = escape
+		case '\r', 0x07:
+		case '\b':
+			// Erase the last character of the line, as a terminal would
+			// when the cursor moves back and it is overwritten.
+			if n := len(t.text); n > 0 && t.text[n-1] != '\n' {
+				t.text = t.text[:n-1]
+			}
+		default:
+			t.text = append(t.text, c)
+		}
+	}
+}
+
+// offsetAt returns the offset into the session of the event that produced
+// the text at pos.
+func (t *transcript) offsetAt(pos int) time.Duration {
+	i := sort.Search(len(t.marks), func(i int) bool { return t.marks[i].pos > pos }) - 1
+	if i < 0 {
+		return 0
+	}
+	return t.marks[i].at
+}
+
+// hit is one match of a pattern in a transcript.
+type hit struct {
+	Offset time.Duration
+	// Before and After are the context lines around the matching Lines.
+	Before, Lines, After []string
+}
+
+// Search returns every match of re, with up to context lines before and
+// after each. Several matches on the same lines are reported once.
+func (t *transcript) Search(re *regexp.Regexp, context int) []hit {
+	var hits []hit
+	end := -1
+	for _, loc := range re.FindAllIndex(t.text, -1) {
+		if loc[0] <= end {
+			continue
+		}
+		start := lineStart(t.text, loc[0])
+		end = lineEnd(t.text, loc[1])
+		h := hit{Offset: t.offsetAt(loc[0]), Lines: splitLines(t.text[start:end])}
+
+		before := start
+		for i := 0; i < context && before > 0; i++ {
+			before = lineStart(t.text, before-1)
+		}
+		after := end
+		for i := 0; i < context && after < len(t.text); i++ {
+			after = lineEnd(t.text, after+1)
+		}
+		if before < start {
+			h.Before = splitLines(t.text[before : start-1])
+		}
+		if after > end {
+			h.After = splitLines(t.text[end+1 : after])
+		}
+		hits = append(hits, h)
+	}
+	return hits
+}
+
+func lineStart(text []byte, pos int) int {
+	return bytes.LastIndexByte(text[:pos], '\n') + 1
+}
+
+func lineEnd(text []byte, pos int) int {
+	if i := bytes.IndexByte(text[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+	return len(text)
+}
+
+func splitLines(b []byte) []string {
+	return strings.Split(string(b), "\n")
+}
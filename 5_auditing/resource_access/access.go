@@ -0,0 +1,328 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// org is the part of an organization's state that decides who can reach a
+// resource.
+type org struct {
+	Accounts    map[string]sdm.Account
+	Roles       map[string]*sdm.Role
+	Groups      map[string]*sdm.Group
+	Attachments []*sdm.AccountAttachment
+	Memberships []*sdm.AccountGroup
+	GroupRoles  []*sdm.GroupRole
+	Grants      []*sdm.AccountGrant
+}
+
+// path is one way an account was given access to a resource: a role it was
+// attached to, a role one of its groups was attached to, or a temporary
+// grant.
+type path struct {
+	Via       string    `json:"via"` // "role", "group" or "grant"
+	GroupID   string    `json:"groupId,omitempty"`
+	GroupName string    `json:"groupName,omitempty"`
+	RoleID    string    `json:"roleId,omitempty"`
+	RoleName  string    `json:"roleName,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	GrantID   string    `json:"grantId,omitempty"`
+	From      time.Time `json:"from,omitzero"`
+	Until     time.Time `json:"until,omitzero"`
+}
+
+func (p path) String() string {
+	switch p.Via {
+	case "grant":
+		s := "temporary grant " + p.GrantID
+		if !p.From.IsZero() {
+			s += " from " + p.From.UTC().Format(time.RFC3339)
+		}
+		if !p.Until.IsZero() {
+			s += " until " + p.Until.UTC().Format(time.RFC3339)
+		}
+		if p.Rule != "" {
+			s += ", " + p.Rule
+		}
+		return s
+	case "group":
+		return fmt.Sprintf("group %v (%v) -> role %v (%v), %v", p.GroupName, p.GroupID, p.RoleName, p.RoleID, p.Rule)
+	default:
+		return fmt.Sprintf("role %v (%v), %v", p.RoleName, p.RoleID, p.Rule)
+	}
+}
+
+// access is an account that could reach the resource and how.
+type access struct {
+	AccountID string `json:"accountId"`
+	Account   string `json:"account"`
+	Suspended bool   `json:"suspended,omitempty"`
+	Paths     []path `json:"paths"`
+}
+
+// hasTypeRules reports whether any role or grant selects resources by type.
+func (o *org) hasTypeRules() bool {
+	for _, role := range o.Roles {
+		for _, rule := range role.AccessRules {
+			if len(rule.IDs) == 0 && rule.Type != "" {
+				return true
+			}
+		}
+	}
+	for _, g := range o.Grants {
+		if g.ResourceID == "" && len(g.AccessRule.IDs) == 0 && g.AccessRule.Type != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// whoCanAccess returns every account with a path to resource at time at,
+// ordered by account name. Suspended accounts are included and marked, since
+// a path that was only blocked by suspension is still of interest.
+func whoCanAccess(o *org, resource sdm.Resource, at time.Time, accountName func(sdm.Account) string) []access {
+	// Which roles reach the resource, and through which rule.
+	roleRules := map[string]string{}
+	for id, role := range o.Roles {
+		for i, rule := range role.AccessRules {
+			if ruleMatches(rule, resource) {
+				roleRules[id] = fmt.Sprintf("rule %v: %v", i, describeRule(rule))
+				break
+			}
+		}
+	}
+
+	paths := map[string][]path{}
+	for _, a := range o.Attachments {
+		if rule, ok := roleRules[a.RoleID]; ok {
+			paths[a.AccountID] = append(paths[a.AccountID], path{
+				Via: "role", RoleID: a.RoleID, RoleName: o.Roles[a.RoleID].Name, Rule: rule,
+			})
+		}
+	}
+	rolesOfGroup := map[string][]string{}
+	for _, gr := range o.GroupRoles {
+		if _, ok := roleRules[gr.RoleID]; ok {
+			rolesOfGroup[gr.GroupID] = append(rolesOfGroup[gr.GroupID], gr.RoleID)
+		}
+	}
+	for _, m := range o.Memberships {
+		for _, roleID := range rolesOfGroup[m.GroupID] {
+			groupName := ""
+			if g := o.Groups[m.GroupID]; g != nil {
+				groupName = g.Name
+			}
+			paths[m.AccountID] = append(paths[m.AccountID], path{
+				Via: "group", GroupID: m.GroupID, GroupName: groupName,
+				RoleID: roleID, RoleName: o.Roles[roleID].Name, Rule: roleRules[roleID],
+			})
+		}
+	}
+	for _, g := range o.Grants {
+		if !g.StartFrom.IsZero() && at.Before(g.StartFrom) || !g.ValidUntil.IsZero() && !at.Before(g.ValidUntil) {
+			continue
+		}
+		p := path{Via: "grant", GrantID: g.ID, From: g.StartFrom, Until: g.ValidUntil}
+		switch {
+		case g.ResourceID == resource.GetID():
+		case g.ResourceID == "" && ruleMatches(g.AccessRule, resource):
+			p.Rule = describeRule(g.AccessRule)
+		default:
+			continue
+		}
+		paths[g.AccountID] = append(paths[g.AccountID], p)
+	}
+
+	var result []access
+	for accountID, ps := range paths {
+		a := access{AccountID: accountID, Account: accountID, Paths: ps}
+		if account := o.Accounts[accountID]; account != nil {
+			a.Account = accountName(account)
+			a.Suspended = account.IsSuspended()
+		}
+		sort.Slice(a.Paths, func(i, j int) bool { return a.Paths[i].String() < a.Paths[j].String() })
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Account != result[j].Account {
+			return result[i].Account < result[j].Account
+		}
+		return result[i].AccountID < result[j].AccountID
+	})
+	return result
+}
+
+// ruleMatches reports whether an access rule covers a resource. A static
+// rule lists resource IDs. A dynamic rule selects resources by type, tags or
+// both, and every tag it names must be set to the same value on the
+// resource.
+func ruleMatches(rule sdm.AccessRule, resource sdm.Resource) bool {
+	if len(rule.IDs) > 0 {
+		for _, id := range rule.IDs {
+			if id == resource.GetID() {
+				return true
+			}
+		}
+		return false
+	}
+	if rule.Type == "" && len(rule.Tags) == 0 {
+		return false
+	}
+	if rule.Type != "" {
+		if t, _ := resourceType(resource); rule.Type != t {
+			return false
+		}
+	}
+	tags := resource.GetTags()
+	for k, v := range rule.Tags {
+		if tv, ok := tags[k]; !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func describeRule(rule sdm.AccessRule) string {
+	if len(rule.IDs) > 0 {
+		return "resource IDs " + strings.Join(rule.IDs, ", ")
+	}
+	var parts []string
+	if rule.Type != "" {
+		parts = append(parts, "type "+rule.Type)
+	}
+	if len(rule.Tags) > 0 {
+		var tags []string
+		for k, v := range rule.Tags {
+			tags = append(tags, k+"="+v)
+		}
+		sort.Strings(tags)
+		parts = append(parts, "tags "+strings.Join(tags, ","))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// resourceTypes maps SDK resource type names to the type an access rule
+// uses for them. The names don't follow one rule: *sdm.Mysql is mysql but
+// *sdm.DB2I is db_2_i and *sdm.RabbitMQAMQP091 is rabbitmq_amqp_091.
+var resourceTypes = map[string]string{
+	"AKS":                                "aks",
+	"AKSBasicAuth":                       "aks_basic_auth",
+	"AKSServiceAccount":                  "aks_service_account",
+	"AKSServiceAccountUserImpersonation": "aks_service_account_user_impersonation",
+	"AKSUserImpersonation":               "aks_user_impersonation",
+	"AmazonEKS":                          "amazon_eks",
+	"AmazonEKSInstanceProfile":           "amazon_eks_instance_profile",
+	"AmazonEKSInstanceProfileUserImpersonation": "amazon_eks_instance_profile_user_impersonation",
+	"AmazonEKSUserImpersonation":                "amazon_eks_user_impersonation",
+	"AmazonES":                                  "amazon_es",
+	"AmazonMQAMQP091":                           "amazonmq_amqp_091",
+	"Athena":                                    "athena",
+	"AuroraMysql":                               "aurora_mysql",
+	"AuroraPostgres":                            "aurora_postgres",
+	"AWS":                                       "aws",
+	"AWSConsole":                                "aws_console",
+	"AWSConsoleStaticKeyPair":                   "aws_console_static_key_pair",
+	"Azure":                                     "azure",
+	"AzureCertificate":                          "azure_certificate",
+	"AzureMysql":                                "azure_mysql",
+	"AzurePostgres":                             "azure_postgres",
+	"AzurePostgresManagedIdentity":              "azure_postgres_managed_identity",
+	"BigQuery":                                  "big_query",
+	"Cassandra":                                 "cassandra",
+	"Citus":                                     "citus",
+	"Clustrix":                                  "clustrix",
+	"Cockroach":                                 "cockroach",
+	"CouchbaseDatabase":                         "couchbase_database",
+	"CouchbaseWebUI":                            "couchbase_web_ui",
+	"DB2I":                                      "db_2_i",
+	"DB2LUW":                                    "db_2_luw",
+	"DocumentDBHost":                            "document_db_host",
+	"DocumentDBReplicaSet":                      "document_db_replica_set",
+	"Druid":                                     "druid",
+	"DynamoDB":                                  "dynamo_db",
+	"Elastic":                                   "elastic",
+	"ElasticacheRedis":                          "elasticache_redis",
+	"GCP":                                       "gcp",
+	"GCPConsole":                                "gcp_console",
+	"GCPWIF":                                    "gcpwif",
+	"GoogleGKE":                                 "google_gke",
+	"GoogleGKEUserImpersonation":                "google_gke_user_impersonation",
+	"Greenplum":                                 "greenplum",
+	"HTTPAuth":                                  "http_auth",
+	"HTTPBasicAuth":                             "http_basic_auth",
+	"HTTPNoAuth":                                "http_no_auth",
+	"Kubernetes":                                "kubernetes",
+	"KubernetesBasicAuth":                       "kubernetes_basic_auth",
+	"KubernetesPodIdentity":                     "kubernetes_pod_identity",
+	"KubernetesServiceAccount":                  "kubernetes_service_account",
+	"KubernetesServiceAccountUserImpersonation": "kubernetes_service_account_user_impersonation",
+	"KubernetesUserImpersonation":               "kubernetes_user_impersonation",
+	"Maria":                                     "maria",
+	"Memcached":                                 "memcached",
+	"Memsql":                                    "memsql",
+	"MongoHost":                                 "mongo_host",
+	"MongoLegacyHost":                           "mongo_legacy_host",
+	"MongoLegacyReplicaset":                     "mongo_legacy_replicaset",
+	"MongoReplicaSet":                           "mongo_replica_set",
+	"MongoShardedCluster":                       "mongo_sharded_cluster",
+	"MTLSMysql":                                 "mtls_mysql",
+	"MTLSPostgres":                              "mtls_postgres",
+	"Mysql":                                     "mysql",
+	"Neptune":                                   "neptune",
+	"NeptuneIAM":                                "neptune_iam",
+	"Oracle":                                    "oracle",
+	"Postgres":                                  "postgres",
+	"Presto":                                    "presto",
+	"RabbitMQAMQP091":                           "rabbitmq_amqp_091",
+	"RawTCP":                                    "raw_tcp",
+	"RDP":                                       "rdp",
+	"RDPCert":                                   "rdp_cert",
+	"RDSPostgresIAM":                            "rds_postgres_iam",
+	"Redis":                                     "redis",
+	"Redshift":                                  "redshift",
+	"SingleStore":                               "single_store",
+	"Snowflake":                                 "snowflake",
+	"Snowsight":                                 "snowsight",
+	"SQLServer":                                 "sql_server",
+	"SQLServerAzureAD":                          "sql_server_azure_ad",
+	"SQLServerKerberosAD":                       "sql_server_kerberos_ad",
+	"SSH":                                       "ssh",
+	"SSHCert":                                   "ssh_cert",
+	"SSHCustomerKey":                            "ssh_customer_key",
+	"SSHPassword":                               "ssh_password",
+	"Sybase":                                    "sybase",
+	"SybaseIQ":                                  "sybase_iq",
+	"Teradata":                                  "teradata",
+	"Trino":                                     "trino",
+}
+
+// resourceType returns the type an access rule uses for a resource, such as
+// amazon_eks for *sdm.AmazonEKS. For a type missing from resourceTypes,
+// which may have been added to the SDK since, ok is false and the lowercased
+// name is returned as a guess.
+func resourceType(resource sdm.Resource) (t string, ok bool) {
+	name := reflect.Indirect(reflect.ValueOf(resource)).Type().Name()
+	if t, ok := resourceTypes[name]; ok {
+		return t, true
+	}
+	return strings.ToLower(name), false
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+var testResource = &sdm.Redis{ID: "rs-1", Name: "cache", Tags: sdm.Tags{"env": "prod", "team": "core"}}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule sdm.AccessRule
+		want bool
+	}{
+		{"listed ID", sdm.AccessRule{IDs: []string{"rs-9", "rs-1"}}, true},
+		{"other IDs", sdm.AccessRule{IDs: []string{"rs-9"}}, false},
+		{"IDs win over type", sdm.AccessRule{IDs: []string{"rs-9"}, Type: "redis"}, false},
+		{"type", sdm.AccessRule{Type: "redis"}, true},
+		{"other type", sdm.AccessRule{Type: "postgres"}, false},
+		{"tag", sdm.AccessRule{Tags: sdm.Tags{"env": "prod"}}, true},
+		{"every tag", sdm.AccessRule{Tags: sdm.Tags{"env": "prod", "team": "core"}}, true},
+		{"tag with another value", sdm.AccessRule{Tags: sdm.Tags{"env": "dev"}}, false},
+		{"tag not set", sdm.AccessRule{Tags: sdm.Tags{"region": "eu"}}, false},
+		{"type and tag", sdm.AccessRule{Type: "redis", Tags: sdm.Tags{"team": "core"}}, true},
+		{"type and other tag", sdm.AccessRule{Type: "redis", Tags: sdm.Tags{"team": "web"}}, false},
+		{"empty rule", sdm.AccessRule{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleMatches(tt.rule, testResource); got != tt.want {
+				t.Errorf("ruleMatches(%+v) = %v, want %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhoCanAccess(t *testing.T) {
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	o := &org{
+		Accounts: map[string]sdm.Account{
+			"a-1": &sdm.User{ID: "a-1", Email: "alice@example.com"},
+			"a-2": &sdm.User{ID: "a-2", Email: "bob@example.com"},
+			"a-3": &sdm.User{ID: "a-3", Email: "carol@example.com", Suspended: true},
+			"a-4": &sdm.User{ID: "a-4", Email: "dave@example.com"},
+		},
+		Roles: map[string]*sdm.Role{
+			"r-1": {ID: "r-1", Name: "cache", AccessRules: sdm.AccessRules{{Type: "postgres"}, {Tags: sdm.Tags{"env": "prod"}}}},
+			"r-2": {ID: "r-2", Name: "databases", AccessRules: sdm.AccessRules{{Type: "postgres"}}},
+		},
+		Groups: map[string]*sdm.Group{
+			"g-1": {ID: "g-1", Name: "oncall"},
+		},
+		Attachments: []*sdm.AccountAttachment{
+			{AccountID: "a-1", RoleID: "r-1"},
+			{AccountID: "a-3", RoleID: "r-1"},
+			{AccountID: "a-4", RoleID: "r-2"},
+		},
+		Memberships: []*sdm.AccountGroup{{AccountID: "a-2", GroupID: "g-1"}},
+		GroupRoles:  []*sdm.GroupRole{{GroupID: "g-1", RoleID: "r-1"}},
+		Grants: []*sdm.AccountGrant{
+			{ID: "ag-1", AccountID: "a-4", ResourceID: "rs-1", StartFrom: at.Add(-time.Hour), ValidUntil: at.Add(time.Hour)},
+			{ID: "ag-2", AccountID: "a-4", AccessRule: sdm.AccessRule{Type: "redis"}},
+			// Grants that haven't started or have ended give no access.
+			{ID: "ag-3", AccountID: "a-2", ResourceID: "rs-1", StartFrom: at.Add(time.Minute)},
+			{ID: "ag-4", AccountID: "a-2", ResourceID: "rs-1", ValidUntil: at},
+			// A grant of another resource gives no access to this one.
+			{ID: "ag-5", AccountID: "a-2", ResourceID: "rs-2"},
+		},
+	}
+	accountName := func(a sdm.Account) string { return a.(*sdm.User).Email }
+
+	got := whoCanAccess(o, testResource, at, accountName)
+	roleRule := "rule 1: tags env=prod"
+	want := []access{
+		{AccountID: "a-1", Account: "alice@example.com", Paths: []path{
+			{Via: "role", RoleID: "r-1", RoleName: "cache", Rule: roleRule},
+		}},
+		{AccountID: "a-2", Account: "bob@example.com", Paths: []path{
+			{Via: "group", GroupID: "g-1", GroupName: "oncall", RoleID: "r-1", RoleName: "cache", Rule: roleRule},
+		}},
+		{AccountID: "a-3", Account: "carol@example.com", Suspended: true, Paths: []path{
+			{Via: "role", RoleID: "r-1", RoleName: "cache", Rule: roleRule},
+		}},
+		{AccountID: "a-4", Account: "dave@example.com", Paths: []path{
+			{Via: "grant", GrantID: "ag-1", From: at.Add(-time.Hour), Until: at.Add(time.Hour)},
+			{Via: "grant", GrantID: "ag-2", Rule: "type redis"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("whoCanAccess() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestHasTypeRules(t *testing.T) {
+	tests := []struct {
+		name string
+		o    *org
+		want bool
+	}{
+		{"no rules", &org{}, false},
+		{"static and tag rules", &org{Roles: map[string]*sdm.Role{
+			"r-1": {AccessRules: sdm.AccessRules{{IDs: []string{"rs-1"}}, {Tags: sdm.Tags{"env": "prod"}}}},
+		}}, false},
+		{"role type rule", &org{Roles: map[string]*sdm.Role{
+			"r-1": {AccessRules: sdm.AccessRules{{Type: "redis"}}},
+		}}, true},
+		{"grant type rule", &org{Grants: []*sdm.AccountGrant{{AccessRule: sdm.AccessRule{Type: "redis"}}}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.o.hasTypeRules(); got != tt.want {
+			t.Errorf("%v: hasTypeRules() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Reports which accounts could access a resource at a moment in the past,
+// and the role, group or temporary grant that gave each of them access. The
+// organization is read as it was at that moment with client.SnapshotAt:
+//
+//	resource_access -resource prod-db -at 2025-03-14T02:30:00Z
+func main() {
+	log.SetFlags(0)
+	resourceFlag := flag.String("resource", "", "name or ID of the resource")
+	atFlag := flag.String("at", "", "time to report on, RFC 3339 or a duration ago such as 24h (default now)")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.Parse()
+	if *resourceFlag == "" {
+		log.Fatal("usage: resource_access -resource <name or id> [-at time] [-json]")
+	}
+	now := time.Now()
+	at, err := timeflag.Parse(*atFlag, now, now)
+	if err != nil {
+		log.Fatalf("invalid -at: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	snapshot := client.SnapshotAt(at)
+	resource, err := findResource(ctx, snapshot, *resourceFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	o, err := loadOrg(ctx, snapshot)
+	if err != nil {
+		log.Fatal(err)
+	}
+	typ, known := resourceType(resource)
+	if !known && o.hasTypeRules() {
+		log.Printf("warning: %T is not in resourceTypes, so rules that select resources by type are matched against the guess %q and may be missed", resource, typ)
+	}
+	result := whoCanAccess(o, resource, at, snapshotcache.AccountName)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(struct {
+			ResourceID   string    `json:"resourceId"`
+			ResourceName string    `json:"resourceName"`
+			At           time.Time `json:"at"`
+			Accounts     []access  `json:"accounts"`
+		}{resource.GetID(), resource.GetName(), at.UTC(), result})
+		if err != nil {
+			log.Fatalf("failed to write report: %v", err)
+		}
+		return
+	}
+	fmt.Printf("Accounts with access to %v (%v, %v) at %v:\n", resource.GetName(), resource.GetID(),
+		typ, at.UTC().Format(time.RFC3339))
+	if len(result) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, a := range result {
+		suspended := ""
+		if a.Suspended {
+			suspended = " [suspended, access blocked]"
+		}
+		fmt.Printf("%v (%v)%v\n", a.Account, a.AccountID, suspended)
+		for _, p := range a.Paths {
+			fmt.Printf("    %v\n", p)
+		}
+	}
+}
+
+// findResource looks a resource up by ID, or by name if it doesn't look like
+// an ID.
+func findResource(ctx context.Context, snapshot *sdm.SnapshotClient, nameOrID string) (sdm.Resource, error) {
+	if strings.HasPrefix(nameOrID, "rs-") {
+		resp, err := snapshot.Resources().Get(ctx, nameOrID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get resource %v: %w", nameOrID, err)
+		}
+		return resp.Resource, nil
+	}
+	resources, err := snapshot.Resources().List(ctx, "name:?", nameOrID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+	if !resources.Next() {
+		return nil, fmt.Errorf("couldn't find resource named %v at that time (error: %v)", nameOrID, resources.Err())
+	}
+	return resources.Value(), nil
+}
+
+// loadOrg reads the accounts, roles, groups, attachments and grants of the
+// organization from a snapshot.
+func loadOrg(ctx context.Context, snapshot *sdm.SnapshotClient) (*org, error) {
+	o := &org{
+		Accounts: map[string]sdm.Account{},
+		Roles:    map[string]*sdm.Role{},
+		Groups:   map[string]*sdm.Group{},
+	}
+
+	accounts, err := snapshot.Accounts().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	for accounts.Next() {
+		o.Accounts[accounts.Value().GetID()] = accounts.Value()
+	}
+	if err := accounts.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	roles, err := snapshot.Roles().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	for roles.Next() {
+		o.Roles[roles.Value().ID] = roles.Value()
+	}
+	if err := roles.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	groups, err := snapshot.Groups().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	for groups.Next() {
+		o.Groups[groups.Value().ID] = groups.Value()
+	}
+	if err := groups.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	attachments, err := snapshot.AccountAttachments().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account attachments: %w", err)
+	}
+	for attachments.Next() {
+		o.Attachments = append(o.Attachments, attachments.Value())
+	}
+	if err := attachments.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list account attachments: %w", err)
+	}
+
+	memberships, err := snapshot.AccountsGroups().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group memberships: %w", err)
+	}
+	for memberships.Next() {
+		o.Memberships = append(o.Memberships, memberships.Value())
+	}
+	if err := memberships.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list group memberships: %w", err)
+	}
+
+	groupRoles, err := snapshot.GroupsRoles().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group roles: %w", err)
+	}
+	for groupRoles.Next() {
+		o.GroupRoles = append(o.GroupRoles, groupRoles.Value())
+	}
+	if err := groupRoles.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list group roles: %w", err)
+	}
+
+	grants, err := snapshot.AccountGrants().List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account grants: %w", err)
+	}
+	for grants.Next() {
+		o.Grants = append(o.Grants, grants.Value())
+	}
+	if err := grants.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list account grants: %w", err)
+	}
+	return o, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05 UTC") },
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Second).String()
+	},
+}).Parse(reportHTML))
+
+// report is what the HTML template renders. Recordings are embedded in the
+// page as JSON, keyed by query ID.
+type report struct {
+	Resource   string
+	From, To   time.Time
+	Generated  time.Time
+	Sessions   []*session
+	Recordings map[string]*recording
+	Encrypted  int
+}
+
+// Writes the queries made against a resource in a time range to a single
+// HTML file that can be handed to an auditor: a sortable table of sessions,
+// a terminal player for each replayable session, and the commands run in
+// it. Secrets are masked before anything is written, and the file loads
+// nothing from the network when it is opened:
+//
+//	session_report -resource Example -from 168h -out report.html
+//	session_report -resource Example -from 2025-06-01T00:00:00Z -to 2025-07-01T00:00:00Z
+func main() {
+	log.SetFlags(0)
+	resourceName := flag.String("resource", "Example", "name of the resource to report on")
+	from := flag.String("from", "", "start of the time range, RFC 3339 or a duration ago such as 24h (default 24h)")
+	to := flag.String("to", "", "end of the time range, RFC 3339 or a duration ago (default now)")
+	out := flag.String("out", "session_report.html", "file to write the report to")
+	prompt := flag.String("prompt", `[$#%>] ?$`, "regular expression matching the end of a shell prompt")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+	promptRE, err := regexp.Compile(*prompt)
+	if err != nil {
+		log.Fatalf("invalid -prompt: %v", err)
+	}
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+	now := time.Now()
+	start, err := timeflag.Parse(*from, now, now.Add(-24*time.Hour))
+	if err != nil {
+		log.Fatalf("invalid -from: %v", err)
+	}
+	end, err := timeflag.Parse(*to, now, now)
+	if err != nil {
+		log.Fatalf("invalid -to: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	resources, err := client.Resources().List(ctx, "name:?", *resourceName)
+	if err != nil {
+		log.Fatalf("failed to list resources: %v", err)
+	}
+	if !resources.Next() {
+		log.Fatalf("couldn't find resource named %v (error: %v)", *resourceName, resources.Err())
+	}
+	queries, err := client.Queries().List(ctx, "resource_id:? after:? before:?", resources.Value().GetID(), start, end)
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+
+	r := report{
+		Resource:   *resourceName,
+		From:       start,
+		To:         end,
+		Generated:  now,
+		Recordings: map[string]*recording{},
+	}
+	for queries.Next() {
+		q := queries.Value()
+		if q.Encrypted {
+			r.Encrypted++
+			continue
+		}
+		s := &session{
+			QueryID:    q.ID,
+			Account:    q.AccountEmail,
+			Resource:   q.ResourceName,
+			Start:      q.Timestamp,
+			Duration:   q.Duration,
+			Replayable: q.Replayable,
+		}
+		redactions := redactor.Session()
+		if q.Replayable {
+			rec, commands, err := replay(ctx, client, q, promptRE, redactions)
+			if err != nil {
+				log.Fatalf("failed to replay query %v: %v", q.ID, err)
+			}
+			r.Recordings[q.ID] = rec
+			s.Commands = commands
+		} else {
+			s.Command = redactions.Text(commandOf(q))
+		}
+		if redactions.Total() > 0 {
+			s.Redactions = redactions.String()
+		}
+		r.Sessions = append(r.Sessions, s)
+	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("failed to create report: %v", err)
+	}
+	if err := reportTemplate.Execute(f, r); err != nil {
+		f.Close()
+		log.Fatalf("failed to write report: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("failed to write report: %v", err)
+	}
+	fmt.Printf("Wrote %v sessions to %v\n", len(r.Sessions), *out)
+	if r.Encrypted > 0 {
+		fmt.Printf("Skipped %v encrypted queries. See encrypted_query_replay for an example of query decryption.\n", r.Encrypted)
+	}
+}
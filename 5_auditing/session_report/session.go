@@ -0,0 +1,148 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/shellcmd"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// session is a row of the report: a query against the resource, with its
+// recording and commands if it is replayable.
+type session struct {
+	QueryID    string
+	Account    string
+	Resource   string
+	Start      time.Time
+	Duration   time.Duration
+	Replayable bool
+	// Command is what was run in a query that can't be replayed, such as a
+	// Kubernetes API call.
+	Command    string
+	Commands   []command
+	Redactions string
+}
+
+// command is a reconstructed command with its offset into the recording,
+// so the player can seek to it.
+type command struct {
+	OffsetMs int64
+	*shellcmd.Command
+}
+
+// frame is terminal output at an offset into a recording.
+type frame struct {
+	AtMs int64  `json:"atMs"`
+	Data string `json:"data"`
+}
+
+// recording is the redacted terminal output of a session, as the embedded
+// player reads it.
+type recording struct {
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	LengthMs int64   `json:"lengthMs"`
+	Frames   []frame `json:"frames"`
+
+	clock time.Duration
+	// partial holds the start of a UTF-8 sequence split across events.
+	partial []byte
+}
+
+// Append adds the output of a replay event. Replay events carry the delay
+// that follows them, so each frame starts where the previous delays end.
+func (rec *recording) Append(ev *sdm.ReplayChunkEvent) {
+	data := append(rec.partial, ev.Data...)
+	n := completeUTF8(data)
+	rec.partial = append([]byte(nil), data[n:]...)
+	if n > 0 {
+		rec.Frames = append(rec.Frames, frame{AtMs: rec.clock.Milliseconds(), Data: string(data[:n])})
+	}
+	rec.clock += ev.Duration
+	rec.LengthMs = rec.clock.Milliseconds()
+}
+
+// completeUTF8 returns the length of data without a UTF-8 sequence that is
+// cut off at its end.
+func completeUTF8(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
+}
+
+// replay fetches the replay of a query, masks secrets in it with redactions
+// and returns its recording and the commands run in it.
+func replay(ctx context.Context, client *sdm.Client, q *sdm.Query, promptRE *regexp.Regexp, redactions *redact.Session) (*recording, []command, error) {
+	rec := &recording{Width: 80, Height: 24}
+	if q.Capture != nil && q.Capture.Width > 0 && q.Capture.Height > 0 {
+		rec.Width, rec.Height = int(q.Capture.Width), int(q.Capture.Height)
+	}
+	r := shellcmd.New(rec.Width, rec.Height, promptRE)
+	stream := redactions.Stream()
+
+	k8s := kube.StreamFor(q)
+	replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan replay: %w", err)
+	}
+	for replayParts.Next() {
+		for _, ev := range replayParts.Value().Events {
+			out := &sdm.ReplayChunkEvent{Data: stream.Write(k8s.Write(ev.Data)), Duration: ev.Duration}
+			rec.Append(out)
+			r.Append(out)
+		}
+	}
+	if err := replayParts.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate replay: %w", err)
+	}
+	last := &sdm.ReplayChunkEvent{Data: append(stream.Write(k8s.Flush()), stream.Flush()...)}
+	rec.Append(last)
+	r.Append(last)
+	if k8s != nil && k8s.Width > 0 && k8s.Height > 0 {
+		rec.Width, rec.Height = k8s.Width, k8s.Height
+	}
+
+	var commands []command
+	for _, c := range r.Commands() {
+		commands = append(commands, command{OffsetMs: c.Offset.Milliseconds(), Command: c})
+	}
+	return rec, commands, nil
+}
+
+// commandOf returns what was run in a query that can't be replayed.
+func commandOf(q *sdm.Query) string {
+	if call, ok := kube.DescribeCall(q); ok {
+		return "Kubernetes call: " + call
+	}
+	var capture struct{ Command string }
+	if err := json.Unmarshal([]byte(q.QueryBody), &capture); err == nil && capture.Command != "" {
+		return capture.Command
+	}
+	return q.QueryBody
+}
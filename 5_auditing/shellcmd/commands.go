@@ -0,0 +1,150 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shellcmd reconstructs the command lines typed in a recorded
+// terminal session, such as an SSH session replay, with the output that
+// followed each one.
+//
+// The replay is played through a small VT100 screen emulator, and a command
+// is read off the screen when enter is pressed on a line that starts with a
+// prompt. Output drawn by full-screen programs on the alternate screen is
+// summarized as FullScreenMarker.
+package shellcmd
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Command is a command line the user submitted and the output that
+// followed it, up to the next prompt. Offset is the time since the start of
+// the session.
+type Command struct {
+	Offset time.Duration `json:"-"`
+	Prompt string        `json:"prompt"`
+	Line   string        `json:"command"`
+	Output []string      `json:"output"`
+}
+
+// FullScreenMarker stands in for the output of programs such as vim or top
+// that draw on the alternate screen, which is not a sequence of lines.
+const FullScreenMarker = "[full-screen program]"
+
+// Reconstructor runs replay events through a screen emulator and picks out
+// the command lines. A replay only holds what the terminal displayed, so the
+// command is read off the screen: when the shell leaves the cursor just after
+// something that looks like a prompt, that position is remembered, and when
+// the user presses enter on that line, whatever the line editor left after
+// the prompt is the command that was run. Editing keys, history recall and
+// tab completion are all resolved by the emulator, since they only redraw
+// the line.
+type Reconstructor struct {
+	screen   *screen
+	promptRE *regexp.Regexp
+	clock    time.Duration
+
+	// promptRow and promptCol are where the command line being typed
+	// starts, or -1 while a command is running.
+	promptRow, promptCol int
+	prompt               string
+
+	commands []*Command
+	// current is the command whose output is being collected.
+	current *Command
+}
+
+// New returns a Reconstructor for a terminal of the given size. promptRE
+// matches the end of the shell prompt, such as `[$#%>] ?$`.
+func New(cols, rows int, promptRE *regexp.Regexp) *Reconstructor {
+	r := &Reconstructor{
+		screen:    newScreen(cols, rows),
+		promptRE:  promptRE,
+		promptRow: -1,
+	}
+	r.screen.OnLineFeed = r.lineFeed
+	r.screen.OnScroll = func(n int) {
+		if r.promptRow >= 0 {
+			r.promptRow -= n
+		}
+	}
+	r.screen.OnAltScreen = func(active bool) {
+		if active && r.current != nil {
+			r.current.Output = append(r.current.Output, FullScreenMarker)
+		}
+	}
+	return r
+}
+
+// Append feeds a replay event. Replay events carry the delay that follows
+// them, so the event happens at the sum of the delays before it.
+func (r *Reconstructor) Append(ev *sdm.ReplayChunkEvent) {
+	r.screen.Write(ev.Data)
+	if r.promptRow < 0 && !r.screen.altScreen {
+		r.detectPrompt()
+	}
+	r.clock += ev.Duration
+}
+
+// detectPrompt checks whether the shell has just printed a prompt: the text
+// left of the cursor matches the prompt pattern and nothing follows it.
+func (r *Reconstructor) detectPrompt() {
+	s := r.screen
+	line := s.lines[s.y]
+	before := strings.TrimLeft(string(line[:s.x]), " ")
+	after := strings.TrimSpace(string(line[s.x:]))
+	if before == "" || after != "" || !r.promptRE.MatchString(before) {
+		return
+	}
+	r.promptRow, r.promptCol, r.prompt = s.y, s.x, strings.TrimSpace(before)
+	r.current = nil
+}
+
+// lineFeed is called before the cursor leaves row. If row holds the command
+// line, the command has been submitted; otherwise the row is output of the
+// current command.
+func (r *Reconstructor) lineFeed(row int) {
+	s := r.screen
+	if s.altScreen {
+		return
+	}
+	if r.promptRow >= 0 && row >= r.promptRow {
+		text, start := s.LogicalLine(row)
+		// Skip the prompt, which starts the first row of the command line.
+		// Trailing blanks are trimmed, so an empty command line ends
+		// before the space after the prompt does.
+		runes := []rune(text)
+		text = string(runes[min((r.promptRow-start)*s.cols+r.promptCol, len(runes)):])
+		r.promptRow = -1
+		line := strings.TrimSpace(text)
+		if line == "" || strings.HasSuffix(line, "^C") {
+			// Enter on an empty line, or a line abandoned with Ctrl-C.
+			return
+		}
+		r.current = &Command{Offset: r.clock, Prompt: r.prompt, Line: line, Output: []string{}}
+		r.commands = append(r.commands, r.current)
+		return
+	}
+	if r.current != nil {
+		text, _ := s.LogicalLine(row)
+		r.current.Output = append(r.current.Output, strings.TrimRight(text, " "))
+	}
+}
+
+// Commands returns the commands reconstructed so far.
+func (r *Reconstructor) Commands() []*Command {
+	return r.commands
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package shellcmd
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+func TestReconstructor(t *testing.T) {
+	tests := []struct {
+		name string
+		// events are one second apart, so the one at index i happens
+		// i seconds into the session.
+		events []string
+		want   []Command
+	}{
+		{
+			"command with output",
+			[]string{"$ ", "l", "s", "\r\n", "a.txt  b.txt\r\n", "$ "},
+			[]Command{{Offset: 3 * time.Second, Prompt: "$", Line: "ls", Output: []string{"a.txt  b.txt"}}},
+		},
+		{
+			"backspace",
+			[]string{"$ ", "lx", "\b \b", "s -l", "\r\n", "$ "},
+			[]Command{{Offset: 4 * time.Second, Prompt: "$", Line: "ls -l", Output: []string{}}},
+		},
+		{
+			"history recall redraws the line",
+			[]string{"$ ", "echo one\r\n", "one\r\n", "$ ", "\r$ echo two\x1b[K", "\r\n", "two\r\n", "$ "},
+			[]Command{
+				{Offset: time.Second, Prompt: "$", Line: "echo one", Output: []string{"one"}},
+				{Offset: 5 * time.Second, Prompt: "$", Line: "echo two", Output: []string{"two"}},
+			},
+		},
+		{
+			"empty lines and ctrl-c are skipped",
+			[]string{"user@host:~$ ", "\r\n", "user@host:~$ ", "rm -rf /^C", "\r\n", "user@host:~$ ", "id\r\n", "uid=0\r\n", "user@host:~$ "},
+			[]Command{{Offset: 6 * time.Second, Prompt: "user@host:~$", Line: "id", Output: []string{"uid=0"}}},
+		},
+		{
+			"full-screen program",
+			[]string{"# ", "vim x\r\n", "\x1b[?1049h\x1b[H~\r\n~\r\n", "\x1b[?1049l", "# "},
+			[]Command{{Offset: time.Second, Prompt: "#", Line: "vim x", Output: []string{FullScreenMarker}}},
+		},
+		{
+			"wrapped command line",
+			[]string{"$ ", "echo " + strings.Repeat("x", 20), "\r\n", strings.Repeat("x", 20) + "\r\n", "$ "},
+			[]Command{{Offset: 2 * time.Second, Prompt: "$", Line: "echo " + strings.Repeat("x", 20), Output: []string{strings.Repeat("x", 20)}}},
+		},
+	}
+	promptRE := regexp.MustCompile(`[$#%>] ?$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(16, 6, promptRE)
+			for _, data := range tt.events {
+				r.Append(&sdm.ReplayChunkEvent{Data: []byte(data), Duration: time.Second})
+			}
+			var got []Command
+			for _, c := range r.Commands() {
+				got = append(got, *c)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Commands() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package shellcmd
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// screen emulates the subset of a VT100/xterm terminal that shells and line
+// editors use: cursor movement, erasing and inserting, scroll regions,
+// autowrap and the alternate screen. Colors and other attributes are parsed
+// and ignored, since only the text matters.
+type screen struct {
+	cols, rows int
+	lines      [][]rune
+	// continued marks rows that hold the continuation of the row above,
+	// because the text reached the right margin and wrapped.
+	continued []bool
+
+	x, y         int
+	wrapPending  bool
+	top, bottom  int
+	savedX       int
+	savedY       int
+	altScreen    bool
+	mainLines    [][]rune
+	mainContinue []bool
+
+	state  parserState
+	params []byte
+	utf8   []byte
+
+	// OnLineFeed is called with the cursor row when a line feed is received,
+	// before the cursor moves.
+	OnLineFeed func(row int)
+	// OnScroll is called when n lines scroll off the top of the main screen.
+	OnScroll func(n int)
+	// OnAltScreen is called when a full-screen program switches to or from
+	// the alternate screen.
+	OnAltScreen func(active bool)
+}
+
+type parserState int
+
+const (
+	ground parserState = iota
+	escape
+	escapeIntermediate
+	csi
+	osc
+	oscEscape
+)
+
+func newScreen(cols, rows int) *screen {
+	s := &screen{cols: cols, rows: rows}
+	s.reset()
+	return s
+}
+
+func (s *screen) reset() {
+	s.lines = make([][]rune, s.rows)
+	s.continued = make([]bool, s.rows)
+	for i := range s.lines {
+		s.lines[i] = s.blankLine()
+	}
+	s.x, s.y, s.wrapPending = 0, 0, false
+	s.top, s.bottom = 0, s.rows-1
+}
+
+func (s *screen) blankLine() []rune {
+	line := make([]rune, s.cols)
+	for i := range line {
+		line[i] = ' '
+	}
+	return line
+}
+
+// Text returns the text of a row without trailing blanks.
+func (s *screen) Text(row int) string {
+	return strings.TrimRight(string(s.lines[row]), " ")
+}
+
+// LogicalLine returns the text of the line that ends at row, joining the
+// rows it wrapped over, and the row it starts at.
+func (s *screen) LogicalLine(row int) (string, int) {
+	start := row
+	for start > 0 && s.continued[start] {
+		start--
+	}
+	var b strings.Builder
+	for r := start; r < row; r++ {
+		b.WriteString(string(s.lines[r]))
+	}
+	b.WriteString(s.Text(row))
+	return b.String(), start
+}
+
+// Write feeds output from the terminal session to the emulator.
+func (s *screen) Write(data []byte) {
+	for _, c := range data {
+		s.feed(c)
+	}
+}
+
+func (s *screen) feed(c byte) {
+	switch s.state {
+	case escape:
+		s.escape(c)
+		return
+	case escapeIntermediate:
+		// The final byte of a character set designation such as ESC ( B.
+		s.state = ground
+		return
+	case csi:
+		switch {
+		case c >= 0x40 && c <= 0x7e:
+			s.state = ground
+			s.csi(c)
+		case c == 0x1b:
+			s.state = escape
+		default:
+			s.params = append(s.params, c)
+		}
+		return
+	case osc:
+		switch c {
+		case 0x07:
+			s.state = ground
+		case 0x1b:
+			s.state = oscEscape
+		}
+		return
+	case oscEscape:
+		s.state = ground
+		return
+	}
+
+	if len(s.utf8) > 0 || c >= 0x80 {
+		s.utf8 = append(s.utf8, c)
+		if utf8.FullRune(s.utf8) {
+			r, _ := utf8.DecodeRune(s.utf8)
+			s.utf8 = s.utf8[:0]
+			s.put(r)
+		}
+		return
+	}
+	switch c {
+	case 0x1b:
+		s.state = escape
+	case '\r':
+		s.x, s.wrapPending = 0, false
+	case '\n', 0x0b, 0x0c:
+		if s.OnLineFeed != nil {
+			s.OnLineFeed(s.y)
+		}
+		s.index()
+	case '\b':
+		if s.x > 0 {
+			s.x--
+		}
+		s.wrapPending = false
+	case '\t':
+		s.x = min((s.x/8+1)*8, s.cols-1)
+	default:
+		if c >= 0x20 && c != 0x7f {
+			s.put(rune(c))
+		}
+	}
+}
+
+func (s *screen) put(r rune) {
+	if s.wrapPending {
+		s.x, s.wrapPending = 0, false
+		s.index()
+		s.continued[s.y] = true
+	}
+	s.lines[s.y][s.x] = r
+	if s.x == s.cols-1 {
+		s.wrapPending = true
+	} else {
+		s.x++
+	}
+}
+
+// index moves the cursor down a row, scrolling if it is at the bottom of
+// the scroll region.
+func (s *screen) index() {
+	s.wrapPending = false
+	switch {
+	case s.y == s.bottom:
+		s.scrollUp(s.top, 1)
+	case s.y < s.rows-1:
+		s.y++
+	}
+}
+
+func (s *screen) reverseIndex() {
+	s.wrapPending = false
+	if s.y == s.top {
+		s.scrollDown(s.top, 1)
+	} else if s.y > 0 {
+		s.y--
+	}
+}
+
+// scrollUp removes n rows at from and adds blank rows at the bottom of the
+// scroll region.
+func (s *screen) scrollUp(from, n int) {
+	n = min(n, s.bottom-from+1)
+	copy(s.lines[from:], s.lines[from+n:s.bottom+1])
+	copy(s.continued[from:], s.continued[from+n:s.bottom+1])
+	for r := s.bottom - n + 1; r <= s.bottom; r++ {
+		s.lines[r] = s.blankLine()
+		s.continued[r] = false
+	}
+	s.continued[from] = false
+	if from == 0 && !s.altScreen && s.OnScroll != nil {
+		s.OnScroll(n)
+	}
+}
+
+// scrollDown adds n blank rows at from and removes rows at the bottom of
+// the scroll region.
+func (s *screen) scrollDown(from, n int) {
+	n = min(n, s.bottom-from+1)
+	copy(s.lines[from+n:s.bottom+1], s.lines[from:])
+	copy(s.continued[from+n:s.bottom+1], s.continued[from:])
+	for r := from; r < from+n; r++ {
+		s.lines[r] = s.blankLine()
+		s.continued[r] = false
+	}
+}
+
+func (s *screen) escape(c byte) {
+	s.state = ground
+	switch c {
+	case '[':
+		s.state = csi
+		s.params = s.params[:0]
+	case ']':
+		s.state = osc
+	case '(', ')', '*', '+', '#', '%':
+		s.state = escapeIntermediate
+	case '7':
+		s.savedX, s.savedY = s.x, s.y
+	case '8':
+		s.x, s.y, s.wrapPending = s.savedX, s.savedY, false
+	case 'D':
+		s.index()
+	case 'E':
+		s.x = 0
+		s.index()
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.reset()
+	}
+}
+
+// csi runs a control sequence, ESC [ params final.
+func (s *screen) csi(final byte) {
+	params := string(s.params)
+	private := len(params) > 0 && strings.ContainsRune("?>=<", rune(params[0]))
+	if private {
+		params = params[1:]
+	}
+	var args []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(strings.TrimRight(p, " !\"#$%&'()*+,-./"))
+		args = append(args, n)
+	}
+	// arg returns the ith parameter, or def if it is missing or zero.
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+
+	s.wrapPending = false
+	switch final {
+	case 'A':
+		s.y = max(s.y-arg(0, 1), 0)
+	case 'B', 'e':
+		s.y = min(s.y+arg(0, 1), s.rows-1)
+	case 'C', 'a':
+		s.x = min(s.x+arg(0, 1), s.cols-1)
+	case 'D':
+		s.x = max(s.x-arg(0, 1), 0)
+	case 'E':
+		s.x, s.y = 0, min(s.y+arg(0, 1), s.rows-1)
+	case 'F':
+		s.x, s.y = 0, max(s.y-arg(0, 1), 0)
+	case 'G', '`':
+		s.x = clamp(arg(0, 1)-1, s.cols)
+	case 'd':
+		s.y = clamp(arg(0, 1)-1, s.rows)
+	case 'H', 'f':
+		s.y, s.x = clamp(arg(0, 1)-1, s.rows), clamp(arg(1, 1)-1, s.cols)
+	case 'J':
+		switch arg(0, 0) {
+		case 0:
+			s.erase(s.y, s.x, s.cols)
+			for r := s.y + 1; r < s.rows; r++ {
+				s.erase(r, 0, s.cols)
+			}
+		case 1:
+			for r := 0; r < s.y; r++ {
+				s.erase(r, 0, s.cols)
+			}
+			s.erase(s.y, 0, s.x+1)
+		default:
+			for r := 0; r < s.rows; r++ {
+				s.erase(r, 0, s.cols)
+			}
+		}
+	case 'K':
+		switch arg(0, 0) {
+		case 0:
+			s.erase(s.y, s.x, s.cols)
+		case 1:
+			s.erase(s.y, 0, s.x+1)
+		default:
+			s.erase(s.y, 0, s.cols)
+		}
+	case 'X':
+		s.erase(s.y, s.x, min(s.x+arg(0, 1), s.cols))
+	case 'P':
+		line := s.lines[s.y]
+		n := min(arg(0, 1), s.cols-s.x)
+		copy(line[s.x:], line[s.x+n:])
+		s.erase(s.y, s.cols-n, s.cols)
+	case '@':
+		line := s.lines[s.y]
+		n := min(arg(0, 1), s.cols-s.x)
+		copy(line[s.x+n:], line[s.x:])
+		s.erase(s.y, s.x, s.x+n)
+	case 'L':
+		if s.y >= s.top && s.y <= s.bottom {
+			s.scrollDown(s.y, arg(0, 1))
+		}
+	case 'M':
+		if s.y >= s.top && s.y <= s.bottom {
+			s.scrollUp(s.y, arg(0, 1))
+		}
+	case 'S':
+		s.scrollUp(s.top, arg(0, 1))
+	case 'T':
+		s.scrollDown(s.top, arg(0, 1))
+	case 'r':
+		top, bottom := arg(0, 1)-1, arg(1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+		}
+		s.x, s.y = 0, 0
+	case 's':
+		s.savedX, s.savedY = s.x, s.y
+	case 'u':
+		s.x, s.y = s.savedX, s.savedY
+	case 'h', 'l':
+		if private {
+			for _, mode := range args {
+				if mode == 47 || mode == 1047 || mode == 1049 {
+					s.setAltScreen(final == 'h')
+				}
+			}
+		}
+	}
+}
+
+func (s *screen) erase(row, from, to int) {
+	for i := from; i < to; i++ {
+		s.lines[row][i] = ' '
+	}
+	if from == 0 {
+		s.continued[row] = false
+	}
+}
+
+// setAltScreen switches between the main screen, which scrolls back into the
+// shell's history, and the alternate screen used by full-screen programs.
+func (s *screen) setAltScreen(active bool) {
+	if active == s.altScreen {
+		return
+	}
+	s.altScreen = active
+	if active {
+		s.mainLines, s.mainContinue = s.lines, s.continued
+		s.savedX, s.savedY = s.x, s.y
+		s.lines = make([][]rune, s.rows)
+		s.continued = make([]bool, s.rows)
+		for i := range s.lines {
+			s.lines[i] = s.blankLine()
+		}
+	} else {
+		s.lines, s.continued = s.mainLines, s.mainContinue
+		s.x, s.y = s.savedX, s.savedY
+	}
+	if s.OnAltScreen != nil {
+		s.OnAltScreen(active)
+	}
+}
+
+func clamp(n, limit int) int {
+	return max(0, min(n, limit-1))
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package siem converts StrongDM activities and queries into the event
+// formats that SIEMs ingest: ArcSight CEF, IBM QRadar LEEF and OCSF JSON. It
+// also writes events to files or to a syslog server using RFC 5424.
+//
+// Activities and queries are first mapped to an Event, which callers may
+// adjust, for example to replace the account email recorded on a query with
+// the one resolved from a snapshot, before formatting it with a Format. The
+// formatted lines go to the writer from OpenOutput or DialSyslog.
+package siem
+
+import (
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Kinds of event.
+const (
+	ActivityKind = "activity"
+	QueryKind    = "query"
+)
+
+// Outcomes of an event. Both activities and queries are recorded once the
+// operation has been carried out, so they map to OutcomeSuccess.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is the format-independent form of an activity or query.
+type Event struct {
+	Kind    string
+	ID      string
+	Time    time.Time
+	Action  string // the activity verb, or "query"
+	Message string
+	Outcome string
+
+	Actor     Actor
+	SourceIP  string
+	UserAgent string
+
+	// Targets are the entities acted upon: the entities of an activity, or
+	// the resource a query was made against.
+	Targets []Target
+
+	// Command and Duration are only set for queries.
+	Command  string
+	Duration time.Duration
+}
+
+// Actor is the account that performed the action.
+type Actor struct {
+	ID    string
+	Email string
+	Name  string
+}
+
+// Target is an entity acted upon. Type is the entity type of an activity
+// entity, such as "user" or "resource", or the resource type of a query, such
+// as "postgres".
+type Target struct {
+	ID   string
+	Type string
+	Name string
+}
+
+// ActivityEvent maps an activity to an Event.
+func ActivityEvent(a *sdm.Activity) Event {
+	e := Event{
+		Kind:      ActivityKind,
+		ID:        a.ID,
+		Time:      a.CompletedAt,
+		Action:    a.Verb,
+		Message:   a.Description,
+		Outcome:   OutcomeSuccess,
+		SourceIP:  a.IPAddress,
+		UserAgent: a.UserAgent,
+	}
+	if a.Actor != nil {
+		e.Actor = Actor{
+			ID:    a.Actor.ID,
+			Email: a.Actor.Email,
+			Name:  joinName(a.Actor.FirstName, a.Actor.LastName),
+		}
+	}
+	for _, entity := range a.Entities {
+		name := entity.Name
+		if name == "" {
+			name = entity.Email
+		}
+		e.Targets = append(e.Targets, Target{ID: entity.ID, Type: entity.Type, Name: name})
+	}
+	return e
+}
+
+// QueryEvent maps a query to an Event. The command is left empty, since how
+// it is extracted from the query body depends on the resource type; callers
+// that have it can set it.
+func QueryEvent(q *sdm.Query) Event {
+	sourceIP := q.SourceIP
+	if sourceIP == "" {
+		sourceIP = q.ClientIP
+	}
+	return Event{
+		Kind:     QueryKind,
+		ID:       q.ID,
+		Time:     q.Timestamp,
+		Action:   QueryKind,
+		Message:  "query on " + q.ResourceName,
+		Outcome:  OutcomeSuccess,
+		SourceIP: sourceIP,
+		Actor: Actor{
+			ID:    q.AccountID,
+			Email: q.AccountEmail,
+			Name:  joinName(q.AccountFirstName, q.AccountLastName),
+		},
+		Targets:  []Target{{ID: q.ResourceID, Type: q.ResourceType, Name: q.ResourceName}},
+		Duration: q.Duration,
+	}
+}
+
+func joinName(first, last string) string {
+	switch {
+	case first == "":
+		return last
+	case last == "":
+		return first
+	}
+	return first + " " + last
+}
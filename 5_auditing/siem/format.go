@@ -0,0 +1,188 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format is an event format.
+type Format string
+
+const (
+	CEF  Format = "cef"
+	LEEF Format = "leef"
+	OCSF Format = "ocsf"
+)
+
+// Product identification included in every event.
+const (
+	vendor         = "StrongDM"
+	product        = "strongDM"
+	productVersion = "1.0"
+)
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CEF, LEEF, OCSF:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected cef, leef or ocsf", s)
+}
+
+// Format returns the event as a single line, without a trailing newline.
+func (f Format) Format(e Event) (string, error) {
+	switch f {
+	case CEF:
+		return formatCEF(e), nil
+	case LEEF:
+		return formatLEEF(e), nil
+	case OCSF:
+		data, err := json.Marshal(newOCSFEvent(e))
+		return string(data), err
+	}
+	return "", fmt.Errorf("unknown format %q", string(f))
+}
+
+// targetFields joins a field of every target with commas, for formats that
+// have a single value per key.
+func (e Event) targetFields(field func(Target) string) string {
+	var values []string
+	for _, t := range e.Targets {
+		values = append(values, field(t))
+	}
+	return strings.Join(values, ",")
+}
+
+// CEF severity runs from 0 to 10; audit records are informational.
+const cefSeverity = 3
+
+// formatCEF returns an ArcSight Common Event Format line. Fields without a
+// standard CEF key use the custom string and number keys with labels.
+func formatCEF(e Event) string {
+	name := e.Message
+	if name == "" {
+		name = e.Action
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%v|%v|%v|%v|%v|%v|", cefHeader(vendor), cefHeader(product), cefHeader(productVersion),
+		cefHeader(e.Action), cefHeader(name), cefSeverity)
+
+	ext := []string{"rt", strconv.FormatInt(e.Time.UnixMilli(), 10)}
+	add := func(key, value string) {
+		if value != "" {
+			ext = append(ext, key, value)
+		}
+	}
+	add("externalId", e.ID)
+	add("cat", e.Kind)
+	add("act", e.Action)
+	add("outcome", e.Outcome)
+	add("suid", e.Actor.ID)
+	add("suser", e.Actor.Email)
+	add("src", e.SourceIP)
+	add("requestClientApplication", e.UserAgent)
+	if len(e.Targets) > 0 {
+		ext = append(ext,
+			"cs1Label", "targetIds", "cs1", e.targetFields(func(t Target) string { return t.ID }),
+			"cs2Label", "targetNames", "cs2", e.targetFields(func(t Target) string { return t.Name }),
+			"cs3Label", "targetTypes", "cs3", e.targetFields(func(t Target) string { return t.Type }))
+	}
+	if e.Command != "" {
+		ext = append(ext, "cs4Label", "command", "cs4", e.Command)
+	}
+	if e.Kind == QueryKind {
+		ext = append(ext, "cn1Label", "durationMs", "cn1", strconv.FormatInt(e.Duration.Milliseconds(), 10))
+	}
+	add("msg", e.Message)
+
+	for i := 0; i < len(ext); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%v=%v", ext[i], cefExtension(ext[i+1]))
+	}
+	return b.String()
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string    { return cefHeaderEscaper.Replace(s) }
+func cefExtension(s string) string { return cefExtensionEscaper.Replace(s) }
+
+// leefTimeFormat is the layout of devTime, declared to QRadar by
+// devTimeFormat in every event.
+const (
+	leefTimeFormat     = "2006-01-02T15:04:05.000Z07:00"
+	leefTimeFormatJava = "yyyy-MM-dd'T'HH:mm:ss.SSSXXX"
+)
+
+// formatLEEF returns an IBM QRadar Log Event Extended Format 1.0 line, with
+// attributes separated by tabs.
+func formatLEEF(e Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%v|%v|%v|%v|", leefHeader(vendor), leefHeader(product), leefHeader(productVersion), leefHeader(e.Action))
+
+	attrs := []string{
+		"devTime", e.Time.UTC().Format(leefTimeFormat),
+		"devTimeFormat", leefTimeFormatJava,
+		"cat", e.Kind,
+		"sev", strconv.Itoa(cefSeverity),
+	}
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, key, value)
+		}
+	}
+	add("externalId", e.ID)
+	add("action", e.Action)
+	add("outcome", e.Outcome)
+	add("accountId", e.Actor.ID)
+	add("usrName", e.Actor.Email)
+	add("src", e.SourceIP)
+	add("userAgent", e.UserAgent)
+	if len(e.Targets) > 0 {
+		add("resourceId", e.targetFields(func(t Target) string { return t.ID }))
+		add("resource", e.targetFields(func(t Target) string { return t.Name }))
+		add("resourceType", e.targetFields(func(t Target) string { return t.Type }))
+	}
+	add("command", e.Command)
+	if e.Kind == QueryKind {
+		add("durationMs", strconv.FormatInt(e.Duration.Milliseconds(), 10))
+	}
+	add("msg", e.Message)
+
+	for i := 0; i < len(attrs); i += 2 {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		fmt.Fprintf(&b, "%v=%v", attrs[i], leefValue(attrs[i+1]))
+	}
+	return b.String()
+}
+
+var (
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\t", " ", "\n", " ", "\r", " ")
+	leefValueEscaper  = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+func leefHeader(s string) string { return leefHeaderEscaper.Replace(s) }
+func leefValue(s string) string  { return leefValueEscaper.Replace(s) }
@@ -0,0 +1,138 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package siem
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var (
+	activity = Event{
+		Kind:      ActivityKind,
+		ID:        "ac-1",
+		Time:      time.Date(2025, 3, 4, 5, 6, 7, 89e6, time.UTC),
+		Action:    "resource updated",
+		Message:   "Alice updated web|db = prod\nagain",
+		Outcome:   OutcomeSuccess,
+		Actor:     Actor{ID: "a-1", Email: "alice@example.com", Name: "Alice Smith"},
+		SourceIP:  "10.0.0.1",
+		UserAgent: "sdm-cli/1.0",
+		Targets:   []Target{{ID: "rs-1", Type: "resource", Name: "web"}, {ID: "rs-2", Type: "resource", Name: "db"}},
+	}
+	query = Event{
+		Kind:     QueryKind,
+		ID:       "q-1",
+		Time:     time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+		Action:   QueryKind,
+		Message:  "query on prod",
+		Outcome:  OutcomeSuccess,
+		Actor:    Actor{ID: "a-1", Email: "alice@example.com"},
+		Targets:  []Target{{ID: "rs-2", Type: "postgres", Name: "prod"}},
+		Command:  "SELECT\t1",
+		Duration: 1500 * time.Millisecond,
+	}
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		event  Event
+		want   string
+	}{
+		{
+			"cef activity", CEF, activity,
+			`CEF:0|StrongDM|strongDM|1.0|resource updated|Alice updated web\|db = prod again|3|` +
+				`rt=1741064767089 externalId=ac-1 cat=activity act=resource updated outcome=success suid=a-1 ` +
+				`suser=alice@example.com src=10.0.0.1 requestClientApplication=sdm-cli/1.0 ` +
+				`cs1Label=targetIds cs1=rs-1,rs-2 cs2Label=targetNames cs2=web,db cs3Label=targetTypes cs3=resource,resource ` +
+				`msg=Alice updated web|db \= prod\nagain`,
+		},
+		{
+			"cef query", CEF, query,
+			`CEF:0|StrongDM|strongDM|1.0|query|query on prod|3|` +
+				`rt=1741064767000 externalId=q-1 cat=query act=query outcome=success suid=a-1 suser=alice@example.com ` +
+				`cs1Label=targetIds cs1=rs-2 cs2Label=targetNames cs2=prod cs3Label=targetTypes cs3=postgres ` +
+				"cs4Label=command cs4=SELECT\t1 cn1Label=durationMs cn1=1500 msg=query on prod",
+		},
+		{
+			"leef activity", LEEF, activity,
+			"LEEF:1.0|StrongDM|strongDM|1.0|resource updated|" +
+				"devTime=2025-03-04T05:06:07.089Z\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSXXX\tcat=activity\tsev=3\t" +
+				"externalId=ac-1\taction=resource updated\toutcome=success\taccountId=a-1\tusrName=alice@example.com\t" +
+				"src=10.0.0.1\tuserAgent=sdm-cli/1.0\tresourceId=rs-1,rs-2\tresource=web,db\tresourceType=resource,resource\t" +
+				"msg=Alice updated web|db = prod again",
+		},
+		{
+			"leef query", LEEF, query,
+			"LEEF:1.0|StrongDM|strongDM|1.0|query|" +
+				"devTime=2025-03-04T05:06:07.000Z\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSXXX\tcat=query\tsev=3\t" +
+				"externalId=q-1\taction=query\toutcome=success\taccountId=a-1\tusrName=alice@example.com\t" +
+				"resourceId=rs-2\tresource=prod\tresourceType=postgres\tcommand=SELECT 1\tdurationMs=1500\tmsg=query on prod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.format.Format(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOCSF(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  Event
+		class  int
+		action int
+	}{
+		{"query", query, ocsfDatastoreActivity, ocsfActivityOther},
+		{"added", withAction(activity, "role added"), ocsfAPIActivity, ocsfActivityCreate},
+		{"updated", activity, ocsfAPIActivity, ocsfActivityUpdate},
+		{"deleted", withAction(activity, "Resource Deleted"), ocsfAPIActivity, ocsfActivityDelete},
+		{"unknown verb", withAction(activity, "user logged in"), ocsfAPIActivity, ocsfActivityOther},
+		{"empty verb", withAction(activity, ""), ocsfAPIActivity, ocsfActivityOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := OCSF.Format(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got ocsfEvent
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatalf("Format() returned invalid JSON %v: %v", line, err)
+			}
+			if got.ClassUID != tt.class || got.ActivityID != tt.action || got.TypeUID != tt.class*100+tt.action {
+				t.Errorf("class_uid, activity_id, type_uid = %v, %v, %v, want %v, %v, %v",
+					got.ClassUID, got.ActivityID, got.TypeUID, tt.class, tt.action, tt.class*100+tt.action)
+			}
+			if got.Time != tt.event.Time.UnixMilli() || got.Metadata.UID != tt.event.ID {
+				t.Errorf("time, metadata.uid = %v, %v, want %v, %v", got.Time, got.Metadata.UID, tt.event.Time.UnixMilli(), tt.event.ID)
+			}
+		})
+	}
+}
+
+func withAction(e Event, action string) Event {
+	e.Action = action
+	return e
+}
@@ -0,0 +1,170 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package siem
+
+import "strings"
+
+// OCSF schema identifiers. Activities are API Activity events, since every
+// change in StrongDM is made through its API, and queries are Datastore
+// Activity events. Both belong to the Application Activity category.
+const (
+	ocsfVersion           = "1.1.0"
+	ocsfCategory          = 6
+	ocsfCategoryName      = "Application Activity"
+	ocsfAPIActivity       = 6003
+	ocsfDatastoreActivity = 6005
+
+	ocsfActivityCreate = 1
+	ocsfActivityRead   = 2
+	ocsfActivityUpdate = 3
+	ocsfActivityDelete = 4
+	ocsfActivityOther  = 99
+
+	ocsfSeverityInformational = 1
+	ocsfStatusSuccess         = 1
+	ocsfStatusFailure         = 2
+)
+
+type ocsfEvent struct {
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	TypeUID      int    `json:"type_uid"`
+	Time         int64  `json:"time"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity"`
+	StatusID     int    `json:"status_id,omitempty"`
+	Status       string `json:"status,omitempty"`
+	Message      string `json:"message,omitempty"`
+
+	Metadata    ocsfMetadata      `json:"metadata"`
+	Actor       ocsfActor         `json:"actor"`
+	SrcEndpoint *ocsfEndpoint     `json:"src_endpoint,omitempty"`
+	API         *ocsfAPI          `json:"api,omitempty"`
+	HTTPRequest *ocsfHTTPRequest  `json:"http_request,omitempty"`
+	Resources   []ocsfResource    `json:"resources,omitempty"`
+	Unmapped    map[string]string `json:"unmapped,omitempty"`
+	Duration    *int64            `json:"duration,omitempty"`
+}
+
+type ocsfMetadata struct {
+	Version string      `json:"version"`
+	UID     string      `json:"uid,omitempty"`
+	Product ocsfProduct `json:"product"`
+}
+
+type ocsfProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version"`
+}
+
+type ocsfActor struct {
+	User ocsfUser `json:"user"`
+}
+
+type ocsfUser struct {
+	UID       string `json:"uid,omitempty"`
+	EmailAddr string `json:"email_addr,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+type ocsfEndpoint struct {
+	IP string `json:"ip"`
+}
+
+type ocsfAPI struct {
+	Operation string `json:"operation"`
+}
+
+type ocsfHTTPRequest struct {
+	UserAgent string `json:"user_agent"`
+}
+
+type ocsfResource struct {
+	UID  string `json:"uid,omitempty"`
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"`
+}
+
+func newOCSFEvent(e Event) ocsfEvent {
+	o := ocsfEvent{
+		CategoryUID:  ocsfCategory,
+		CategoryName: ocsfCategoryName,
+		Time:         e.Time.UnixMilli(),
+		SeverityID:   ocsfSeverityInformational,
+		Severity:     "Informational",
+		Message:      e.Message,
+		Metadata: ocsfMetadata{
+			Version: ocsfVersion,
+			UID:     e.ID,
+			Product: ocsfProduct{Name: product, VendorName: vendor, Version: productVersion},
+		},
+		Actor: ocsfActor{User: ocsfUser{UID: e.Actor.ID, EmailAddr: e.Actor.Email, Name: e.Actor.Name}},
+	}
+	switch e.Outcome {
+	case OutcomeSuccess:
+		o.StatusID, o.Status = ocsfStatusSuccess, "Success"
+	case OutcomeFailure:
+		o.StatusID, o.Status = ocsfStatusFailure, "Failure"
+	}
+	if e.SourceIP != "" {
+		o.SrcEndpoint = &ocsfEndpoint{IP: e.SourceIP}
+	}
+	for _, t := range e.Targets {
+		o.Resources = append(o.Resources, ocsfResource{UID: t.ID, Name: t.Name, Type: t.Type})
+	}
+
+	if e.Kind == QueryKind {
+		o.ClassUID, o.ClassName = ocsfDatastoreActivity, "Datastore Activity"
+		o.ActivityID, o.ActivityName = ocsfActivityOther, "Query"
+		duration := e.Duration.Milliseconds()
+		o.Duration = &duration
+		if e.Command != "" {
+			o.Unmapped = map[string]string{"command": e.Command}
+		}
+	} else {
+		o.ClassUID, o.ClassName = ocsfAPIActivity, "API Activity"
+		o.ActivityID, o.ActivityName = ocsfActivityFromVerb(e.Action)
+		o.API = &ocsfAPI{Operation: e.Action}
+		if e.UserAgent != "" {
+			o.HTTPRequest = &ocsfHTTPRequest{UserAgent: e.UserAgent}
+		}
+	}
+	o.TypeUID = o.ClassUID*100 + o.ActivityID
+	return o
+}
+
+// ocsfActivityFromVerb maps an activity verb, such as "resource added" or
+// "role updated", to an API Activity activity.
+func ocsfActivityFromVerb(verb string) (int, string) {
+	words := strings.Fields(strings.ToLower(verb))
+	if len(words) == 0 {
+		return ocsfActivityOther, "Other"
+	}
+	switch words[len(words)-1] {
+	case "added", "created", "granted", "attached", "assigned":
+		return ocsfActivityCreate, "Create"
+	case "updated", "changed", "renamed", "rotated", "suspended", "unsuspended":
+		return ocsfActivityUpdate, "Update"
+	case "deleted", "removed", "revoked", "detached":
+		return ocsfActivityDelete, "Delete"
+	case "read", "viewed", "listed":
+		return ocsfActivityRead, "Read"
+	}
+	return ocsfActivityOther, "Other"
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package siem
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OpenOutput opens where events are written: "-" for standard output,
+// udp://host:514 or tcp://host:514 for a syslog server, unix:///dev/log for
+// the local syslog socket, and anything else is a file opened with fileFlag.
+// Every line written to syslog is sent as a separate RFC 5424 message.
+func OpenOutput(dest string, fileFlag int) (io.WriteCloser, error) {
+	if dest == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	if u, err := url.Parse(dest); err == nil {
+		switch u.Scheme {
+		case "udp", "tcp":
+			return DialSyslog(u.Scheme, u.Host)
+		case "unix":
+			return DialSyslog(u.Scheme, u.Path)
+		}
+	}
+	return os.OpenFile(dest, fileFlag, 0644)
+}
+
+type nopCloser struct{ *os.File }
+
+func (nopCloser) Close() error { return nil }
+
+// Syslog priority of every message: facility 13 (log audit), severity 6
+// (informational).
+const syslogPriority = 13*8 + 6
+
+// SyslogWriter sends each line written to it as an RFC 5424 message.
+type SyslogWriter struct {
+	network, addr string
+	hostname      string
+	appName       string
+	conn          net.Conn
+	// framed is set for stream connections, where messages are prefixed
+	// with their length as described in RFC 6587.
+	framed bool
+	buf    []byte
+}
+
+// DialSyslog connects to a syslog server. network is "udp", "tcp" or "unix";
+// for "unix", addr is the path of the socket, which may be either a datagram
+// or a stream socket.
+func DialSyslog(network, addr string) (*SyslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	w := &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		hostname: hostname,
+		appName:  filepath.Base(os.Args[0]),
+	}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *SyslogWriter) dial() error {
+	network := w.network
+	if network == "unix" {
+		// /dev/log is usually a datagram socket.
+		conn, err := net.Dial("unixgram", w.addr)
+		if err == nil {
+			w.conn, w.framed = conn, false
+			return nil
+		}
+	}
+	conn, err := net.DialTimeout(network, w.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog at %v://%v: %w", w.network, w.addr, err)
+	}
+	w.conn, w.framed = conn, network != "udp"
+	return nil
+}
+
+// Write sends every complete line in p as a message. A partial line is kept
+// until the rest of it is written.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimRight(string(w.buf[:i]), "\r")
+		w.buf = w.buf[i+1:]
+		if line == "" {
+			continue
+		}
+		if err := w.send(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+func (w *SyslogWriter) send(line string) error {
+	msg := fmt.Sprintf("<%d>1 %v %v %v %d - - %v", syslogPriority,
+		time.Now().UTC().Format(time.RFC3339Nano), w.hostname, w.appName, os.Getpid(), line)
+	if err := w.writeMessage(msg); err != nil {
+		// The server may have restarted; reconnect once and retry.
+		w.conn.Close()
+		if err := w.dial(); err != nil {
+			return err
+		}
+		return w.writeMessage(msg)
+	}
+	return nil
+}
+
+func (w *SyslogWriter) writeMessage(msg string) error {
+	if w.framed {
+		msg = fmt.Sprintf("%d %v", len(msg), msg)
+	}
+	_, err := io.WriteString(w.conn, msg)
+	return err
+}
+
+// Close sends any partial line and closes the connection.
+func (w *SyslogWriter) Close() error {
+	if len(w.buf) > 0 {
+		w.Write([]byte{'\n'})
+	}
+	return w.conn.Close()
+}
@@ -0,0 +1,377 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshotcache answers "what did this account or resource look like
+// at time t" from memory, and the same for roles, groups, nodes, policies,
+// workflows and approval workflows.
+//
+// Calling client.SnapshotAt(t).Accounts().Get for every query in an audit
+// costs one API call per row. Instead, the cache loads the full history of an
+// entity once, from AccountsHistory, ResourcesHistory or the history service
+// of its kind, and answers lookups at any time by finding the version whose
+// validity interval contains it. Histories are kept in a size-bounded LRU and
+// the cache is safe for concurrent use.
+//
+// History returns the whole history of an entity, for callers that need
+// every version rather than the one at a time.
+package snapshotcache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// DefaultSize is the number of entity histories kept when New is given a
+// size of zero.
+const DefaultSize = 10000
+
+// refreshAfter is how old a history must be before a lookup at a later time
+// reloads it to pick up versions recorded since.
+const refreshAfter = time.Minute
+
+// NotExistError reports that an entity did not exist at the requested time,
+// either because it had not been created yet or because it had been deleted.
+type NotExistError struct {
+	Kind string
+	ID   string
+	At   time.Time
+	// CreatedAt is when the first version of the entity was recorded. It is
+	// zero when the entity has no history at all.
+	CreatedAt time.Time
+	// DeletedAt is when the entity was deleted, if that was before At.
+	DeletedAt time.Time
+}
+
+func (e *NotExistError) Error() string {
+	switch {
+	case e.CreatedAt.IsZero():
+		return fmt.Sprintf("%v %v has no recorded history", e.Kind, e.ID)
+	case !e.DeletedAt.IsZero():
+		return fmt.Sprintf("%v %v was deleted at %v", e.Kind, e.ID, e.DeletedAt.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("%v %v did not exist yet at %v, it was created at %v",
+			e.Kind, e.ID, e.At.Format(time.RFC3339), e.CreatedAt.Format(time.RFC3339))
+	}
+}
+
+// Stats counts how lookups were answered.
+type Stats struct {
+	Hits      int // answered from a cached history
+	Loads     int // history API calls made
+	Evictions int // histories dropped to stay within the size bound
+}
+
+// Cache memoizes entity histories. The zero value is not usable; create one
+// with New.
+type Cache struct {
+	size int
+	// history loads the history of an entity. It is History, unless a
+	// test replaces it.
+	history func(ctx context.Context, kind, id string) ([]Version, error)
+
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+	stats   Stats
+}
+
+// New returns a cache that keeps the histories of at most size entities.
+func New(client *sdm.Client, size int) *Cache {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Cache{
+		size: size,
+		history: func(ctx context.Context, kind, id string) ([]Version, error) {
+			return History(ctx, client, kind, id)
+		},
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// entry is the history of one entity. ready is closed once the history has
+// loaded, so concurrent lookups of the same entity share a single API call.
+type entry struct {
+	key      string
+	ready    chan struct{}
+	loadedAt time.Time
+	versions []Version
+	err      error
+}
+
+// Version is one entry of an entity's history: the entity as it was from At
+// until the next version, or until DeletedAt if it was deleted.
+type Version struct {
+	Value      interface{}
+	At         time.Time
+	DeletedAt  time.Time
+	ActivityID string
+}
+
+type iterator[H any] interface {
+	Next() bool
+	Value() H
+	Err() error
+}
+
+// versions reads a history iterator into Versions.
+func versions[H any](history iterator[H], err error, version func(H) Version) ([]Version, error) {
+	if err != nil {
+		return nil, err
+	}
+	var result []Version
+	for history.Next() {
+		result = append(result, version(history.Value()))
+	}
+	return result, history.Err()
+}
+
+// historyLoaders lists the history service of each kind of entity.
+var historyLoaders = map[string]func(ctx context.Context, client *sdm.Client, id string) ([]Version, error){
+	"account": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.AccountsHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.AccountHistory) Version {
+			return Version{h.Account, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"resource": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.ResourcesHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.ResourceHistory) Version {
+			return Version{h.Resource, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"role": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.RolesHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.RoleHistory) Version {
+			return Version{h.Role, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"group": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.GroupsHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.GroupHistory) Version {
+			return Version{h.Group, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"node": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.NodesHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.NodeHistory) Version {
+			return Version{h.Node, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"policy": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.PoliciesHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.PolicyHistory) Version {
+			return Version{h.Policy, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"workflow": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.WorkflowsHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.WorkflowHistory) Version {
+			return Version{h.Workflow, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+	"approval-workflow": func(ctx context.Context, client *sdm.Client, id string) ([]Version, error) {
+		history, err := client.ApprovalWorkflowsHistory().List(ctx, "id:?", id)
+		return versions(history, err, func(h *sdm.ApprovalWorkflowHistory) Version {
+			return Version{h.ApprovalWorkflow, h.Timestamp, h.DeletedAt, h.ActivityID}
+		})
+	},
+}
+
+// Kinds returns the kinds of entity that have a history: account, resource,
+// role, group, node, policy, workflow and approval-workflow.
+func Kinds() []string {
+	var names []string
+	for name := range historyLoaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// History returns every recorded version of an entity, oldest first,
+// without caching it. kind is one of Kinds.
+func History(ctx context.Context, client *sdm.Client, kind, id string) ([]Version, error) {
+	load, ok := historyLoaders[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown kind of entity %q", kind)
+	}
+	versions, err := load(ctx, client, id)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].At.Before(versions[j].At) })
+	return versions, nil
+}
+
+// Account returns the account with the given ID as it was at time at.
+func (c *Cache) Account(ctx context.Context, id string, at time.Time) (sdm.Account, error) {
+	v, err := c.lookup(ctx, "account", id, at)
+	account, _ := v.(sdm.Account)
+	return account, err
+}
+
+// Resource returns the resource with the given ID as it was at time at.
+func (c *Cache) Resource(ctx context.Context, id string, at time.Time) (sdm.Resource, error) {
+	v, err := c.lookup(ctx, "resource", id, at)
+	resource, _ := v.(sdm.Resource)
+	return resource, err
+}
+
+// Role returns the role with the given ID as it was at time at.
+func (c *Cache) Role(ctx context.Context, id string, at time.Time) (*sdm.Role, error) {
+	v, err := c.lookup(ctx, "role", id, at)
+	role, _ := v.(*sdm.Role)
+	return role, err
+}
+
+// Group returns the group with the given ID as it was at time at.
+func (c *Cache) Group(ctx context.Context, id string, at time.Time) (*sdm.Group, error) {
+	v, err := c.lookup(ctx, "group", id, at)
+	group, _ := v.(*sdm.Group)
+	return group, err
+}
+
+// Node returns the node with the given ID as it was at time at.
+func (c *Cache) Node(ctx context.Context, id string, at time.Time) (sdm.Node, error) {
+	v, err := c.lookup(ctx, "node", id, at)
+	node, _ := v.(sdm.Node)
+	return node, err
+}
+
+// Policy returns the policy with the given ID as it was at time at.
+func (c *Cache) Policy(ctx context.Context, id string, at time.Time) (*sdm.Policy, error) {
+	v, err := c.lookup(ctx, "policy", id, at)
+	policy, _ := v.(*sdm.Policy)
+	return policy, err
+}
+
+// Workflow returns the workflow with the given ID as it was at time at.
+func (c *Cache) Workflow(ctx context.Context, id string, at time.Time) (*sdm.Workflow, error) {
+	v, err := c.lookup(ctx, "workflow", id, at)
+	workflow, _ := v.(*sdm.Workflow)
+	return workflow, err
+}
+
+// ApprovalWorkflow returns the approval workflow with the given ID as it was at time at.
+func (c *Cache) ApprovalWorkflow(ctx context.Context, id string, at time.Time) (*sdm.ApprovalWorkflow, error) {
+	v, err := c.lookup(ctx, "approval-workflow", id, at)
+	workflow, _ := v.(*sdm.ApprovalWorkflow)
+	return workflow, err
+}
+
+// Stats returns counters for the lookups made so far.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *Cache) lookup(ctx context.Context, kind, id string, at time.Time) (interface{}, error) {
+	e, err := c.entry(ctx, kind+"/"+id, at, func(ctx context.Context) ([]Version, error) {
+		return c.history(ctx, kind, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	versions := e.versions
+	notExist := &NotExistError{Kind: kind, ID: id, At: at}
+	if len(versions) > 0 {
+		notExist.CreatedAt = versions[0].At
+	}
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].At.After(at) }) - 1
+	if i < 0 {
+		return nil, notExist
+	}
+	v := versions[i]
+	if !v.DeletedAt.IsZero() && !at.Before(v.DeletedAt) {
+		notExist.DeletedAt = v.DeletedAt
+		return nil, notExist
+	}
+	return v.Value, nil
+}
+
+// entry returns the loaded history for key. It is loaded if missing, and
+// reloaded if at is later than the last load and that load is more than
+// refreshAfter old, since a newer version may have been recorded by now.
+func (c *Cache) entry(ctx context.Context, key string, at time.Time, load func(context.Context) ([]Version, error)) (*entry, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if e.err == nil && (!at.After(e.loadedAt) || time.Since(e.loadedAt) < refreshAfter) {
+			c.mu.Lock()
+			c.stats.Hits++
+			c.mu.Unlock()
+			return e, nil
+		}
+		c.mu.Lock()
+		// Drop the failed or stale history, unless another lookup already
+		// replaced it.
+		if cur, ok := c.entries[key]; ok && cur == el {
+			c.lru.Remove(el)
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+		return c.entry(ctx, key, at, load)
+	}
+
+	e := &entry{key: key, ready: make(chan struct{})}
+	c.entries[key] = c.lru.PushFront(e)
+	c.stats.Loads++
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+		c.stats.Evictions++
+	}
+	c.mu.Unlock()
+
+	e.loadedAt = time.Now()
+	e.versions, e.err = load(ctx)
+	close(e.ready)
+	if e.err != nil {
+		return nil, fmt.Errorf("failed to load history of %v: %w", key, e.err)
+	}
+	return e, nil
+}
+
+// AccountName returns the email of a user or the name of any other kind of
+// account.
+func AccountName(account sdm.Account) string {
+	switch a := account.(type) {
+	case *sdm.User:
+		return a.Email
+	case *sdm.Service:
+		return a.Name
+	case nil:
+		return ""
+	}
+	return account.GetID()
+}
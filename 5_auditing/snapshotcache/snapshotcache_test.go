@@ -0,0 +1,171 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package snapshotcache
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeCache returns a cache whose histories come from histories, keyed by
+// id, and a count of the loads made for each id.
+func fakeCache(size int, histories map[string][]Version) (*Cache, map[string]int) {
+	loads := map[string]int{}
+	var mu sync.Mutex
+	c := New(nil, size)
+	c.history = func(ctx context.Context, kind, id string) ([]Version, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads[id]++
+		return histories[id], nil
+	}
+	return c, loads
+}
+
+func TestLookup(t *testing.T) {
+	c, _ := fakeCache(0, map[string][]Version{
+		"r-1": {
+			{Value: "v1", At: t0},
+			{Value: "v2", At: t0.Add(2 * time.Hour)},
+			{Value: "v3", At: t0.Add(4 * time.Hour), DeletedAt: t0.Add(6 * time.Hour)},
+		},
+		"r-2": nil,
+	})
+	tests := []struct {
+		name string
+		id   string
+		at   time.Time
+		want interface{}
+		// createdAt and deletedAt are set on the NotExistError expected.
+		createdAt, deletedAt time.Time
+	}{
+		{"before creation", "r-1", t0.Add(-time.Second), nil, t0, time.Time{}},
+		{"at creation", "r-1", t0, "v1", time.Time{}, time.Time{}},
+		{"between versions", "r-1", t0.Add(3 * time.Hour), "v2", time.Time{}, time.Time{}},
+		{"at a new version", "r-1", t0.Add(4 * time.Hour), "v3", time.Time{}, time.Time{}},
+		{"just before deletion", "r-1", t0.Add(6*time.Hour - time.Second), "v3", time.Time{}, time.Time{}},
+		{"at deletion", "r-1", t0.Add(6 * time.Hour), nil, t0, t0.Add(6 * time.Hour)},
+		{"after deletion", "r-1", t0.Add(24 * time.Hour), nil, t0, t0.Add(6 * time.Hour)},
+		{"no history", "r-2", t0, nil, time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.lookup(context.Background(), "resource", tt.id, tt.at)
+			if tt.want != nil {
+				if err != nil || got != tt.want {
+					t.Fatalf("lookup() = %v, %v, want %v", got, err, tt.want)
+				}
+				return
+			}
+			var notExist *NotExistError
+			if !errors.As(err, &notExist) {
+				t.Fatalf("lookup() = %v, %v, want a NotExistError", got, err)
+			}
+			if !notExist.CreatedAt.Equal(tt.createdAt) || !notExist.DeletedAt.Equal(tt.deletedAt) {
+				t.Errorf("lookup() error = %+v, want CreatedAt %v and DeletedAt %v", notExist, tt.createdAt, tt.deletedAt)
+			}
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	history := []Version{{Value: "v1", At: t0}}
+	c, loads := fakeCache(2, map[string][]Version{"a": history, "b": history, "c": history})
+	ctx := context.Background()
+	// a is used again after b, so c evicts b.
+	for _, id := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := c.lookup(ctx, "resource", id, t0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := map[string]int{"a": 1, "b": 2, "c": 1}; !maps.Equal(loads, want) {
+		t.Errorf("loads = %v, want %v", loads, want)
+	}
+	if want := (Stats{Hits: 2, Loads: 4, Evictions: 2}); c.Stats() != want {
+		t.Errorf("Stats() = %+v, want %+v", c.Stats(), want)
+	}
+}
+
+func TestEntrySingleFlight(t *testing.T) {
+	c := New(nil, 0)
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var loads int
+	load := func(context.Context) ([]Version, error) {
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		close(started)
+		<-release
+		return []Version{{Value: "v1", At: t0}}, nil
+	}
+
+	const lookups = 5
+	entries := make(chan *entry, lookups)
+	var wg sync.WaitGroup
+	get := func() {
+		defer wg.Done()
+		e, err := c.entry(ctx, "resource/r-1", t0, load)
+		if err != nil {
+			t.Error(err)
+		}
+		entries <- e
+	}
+	wg.Add(1)
+	go get()
+	// The entry is registered before the load starts, so the other lookups
+	// wait for it rather than loading again.
+	<-started
+	for i := 1; i < lookups; i++ {
+		wg.Add(1)
+		go get()
+	}
+	close(release)
+	wg.Wait()
+	close(entries)
+
+	if loads != 1 {
+		t.Errorf("load was called %v times, want once", loads)
+	}
+	first := <-entries
+	for e := range entries {
+		if e != first {
+			t.Errorf("entry() returned different entries for the same key")
+		}
+	}
+	if want := (Stats{Hits: lookups - 1, Loads: 1}); c.Stats() != want {
+		t.Errorf("Stats() = %+v, want %+v", c.Stats(), want)
+	}
+}
+
+func TestEntryRetriesFailedLoad(t *testing.T) {
+	c := New(nil, 0)
+	ctx := context.Background()
+	fail := errors.New("unavailable")
+	if _, err := c.entry(ctx, "resource/r-1", t0, func(context.Context) ([]Version, error) { return nil, fail }); !errors.Is(err, fail) {
+		t.Fatalf("entry() error = %v, want %v", err, fail)
+	}
+	e, err := c.entry(ctx, "resource/r-1", t0, func(context.Context) ([]Version, error) { return []Version{{Value: "v1", At: t0}}, nil })
+	if err != nil || len(e.versions) != 1 {
+		t.Fatalf("entry() after a failed load = %+v, %v, want the history", e, err)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Summarizes the SQL run against datasources such as Postgres. Each captured
+// statement is classified as SELECT, DML or DDL and the tables it names are
+// pulled out, giving a summary per user and per table, and DROP, TRUNCATE
+// and DELETE without WHERE are listed with when they were run. Queries are
+// read from the API, or from a file written by export_queries:
+//
+//	sql_analytics -from 168h -filter resource_id:rs-1234
+//	sql_analytics -in queries.jsonl -json
+func main() {
+	log.SetFlags(0)
+	from := flag.String("from", "", "start of the time range, RFC 3339 or a duration ago such as 24h (default 24h)")
+	to := flag.String("to", "", "end of the time range, RFC 3339 or a duration ago (default now)")
+	filter := flag.String("filter", "", "additional query filter, for example resource_id:rs-1234")
+	inPath := flag.String("in", "", "JSON lines file written by export_queries to read instead of the API, - for stdin")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+	a := newAnalyzer(redactor)
+	if *inPath != "" {
+		if err := readFile(*inPath, a); err != nil {
+			log.Fatalf("failed to read queries: %v", err)
+		}
+	} else {
+		now := time.Now()
+		start, err := timeflag.Parse(*from, now, now.Add(-24*time.Hour))
+		if err != nil {
+			log.Fatalf("invalid -from: %v", err)
+		}
+		end, err := timeflag.Parse(*to, now, now)
+		if err != nil {
+			log.Fatalf("invalid -to: %v", err)
+		}
+		if err := readAPI(start, end, *filter, a); err != nil {
+			log.Fatal(err)
+		}
+	}
+	r := a.Report()
+	log.Printf("Analyzed %v statements in %v queries, skipped %v that weren't SQL", r.Statements, r.Queries, r.Skipped)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Fatalf("failed to write report: %v", err)
+		}
+		return
+	}
+	printReport(os.Stdout, r)
+}
+
+// readAPI analyzes the queries made between start and end. Shell and
+// Kubernetes captures and encrypted queries are left out.
+func readAPI(start, end time.Time, filter string, a *analyzer) error {
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	queryFilter := strings.TrimSpace("after:? before:? " + filter)
+	queries, err := client.Queries().List(ctx, queryFilter, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to list queries: %w", err)
+	}
+	for queries.Next() {
+		q := queries.Value()
+		if q.Encrypted || q.Capture != nil {
+			continue
+		}
+		a.Add(record{
+			Timestamp:    q.Timestamp,
+			ID:           q.ID,
+			AccountEmail: q.AccountEmail,
+			ResourceName: q.ResourceName,
+			Command:      q.QueryBody,
+		})
+	}
+	if err := queries.Err(); err != nil {
+		return fmt.Errorf("failed to iterate queries: %w", err)
+	}
+	return nil
+}
+
+// readFile analyzes the JSON lines written by export_queries.
+func readFile(path string, a *analyzer) error {
+	in := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("line %v: %w", line, err)
+		}
+		a.Add(r)
+	}
+	return scanner.Err()
+}
+
+// maxStatement is how much of a destructive statement is printed.
+const maxStatement = 200
+
+func printReport(out io.Writer, r report) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tSTATEMENTS\tSELECT\tDML\tDDL\tOTHER\tTABLES\tDESTRUCTIVE")
+	for _, u := range r.Users {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", u.Account, u.Statements, u.Kinds[kindSelect],
+			u.Kinds[kindDML], u.Kinds[kindDDL], u.Kinds[kindOther], u.Tables, u.Destructive)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tTABLE\tSTATEMENTS\tSELECT\tDML\tDDL\tACCOUNTS\tLAST USED")
+	for _, t := range r.Tables {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", t.Resource, t.Table, t.Statements, t.Kinds[kindSelect],
+			t.Kinds[kindDML], t.Kinds[kindDDL], len(t.Accounts), t.LastUsed.UTC().Format(time.RFC3339))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	if len(r.Destructive) == 0 {
+		fmt.Fprintln(out, "No destructive statements.")
+		return
+	}
+	fmt.Fprintf(out, "Destructive statements (%v):\n", len(r.Destructive))
+	for _, d := range r.Destructive {
+		statement := strings.Join(strings.Fields(d.Statement), " ")
+		if runes := []rune(statement); len(runes) > maxStatement {
+			statement = string(runes[:maxStatement]) + "..."
+		}
+		fmt.Fprintf(out, "%v  %v on %v  %v (query %v)\n    %v\n", d.Timestamp.UTC().Format(time.RFC3339),
+			d.Account, d.Resource, d.Reason, d.QueryID, statement)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+)
+
+// record is a query with the SQL it ran, read from the API or from a file
+// written by export_queries.
+type record struct {
+	Timestamp    time.Time `json:"timestamp"`
+	ID           string    `json:"id"`
+	AccountEmail string    `json:"accountEmail"`
+	ResourceName string    `json:"resourceName"`
+	Command      string    `json:"command"`
+}
+
+type userSummary struct {
+	Account     string         `json:"account"`
+	Statements  int            `json:"statements"`
+	Kinds       map[string]int `json:"kinds"`
+	Tables      int            `json:"tables"`
+	Destructive int            `json:"destructive"`
+}
+
+type tableSummary struct {
+	Resource   string         `json:"resource"`
+	Table      string         `json:"table"`
+	Statements int            `json:"statements"`
+	Kinds      map[string]int `json:"kinds"`
+	Accounts   []string       `json:"accounts"`
+	LastUsed   time.Time      `json:"lastUsed"`
+}
+
+type destructiveStatement struct {
+	Timestamp time.Time `json:"timestamp"`
+	QueryID   string    `json:"queryId"`
+	Account   string    `json:"account"`
+	Resource  string    `json:"resource"`
+	Reason    string    `json:"reason"`
+	Statement string    `json:"statement"`
+}
+
+type report struct {
+	Queries     int                    `json:"queries"`
+	Statements  int                    `json:"statements"`
+	Users       []userSummary          `json:"users"`
+	Tables      []tableSummary         `json:"tables"`
+	Destructive []destructiveStatement `json:"destructive"`
+	// Skipped counts queries that weren't SQL.
+	Skipped int `json:"skipped"`
+}
+
+// analyzer builds a report from records one at a time.
+type analyzer struct {
+	redactor *redact.Redactor
+	report   report
+
+	users        map[string]*userSummary
+	userTables   map[string]map[string]bool
+	tables       map[[2]string]*tableSummary
+	tableAccount map[[2]string]map[string]bool
+}
+
+func newAnalyzer(redactor *redact.Redactor) *analyzer {
+	return &analyzer{
+		redactor:     redactor,
+		users:        map[string]*userSummary{},
+		userTables:   map[string]map[string]bool{},
+		tables:       map[[2]string]*tableSummary{},
+		tableAccount: map[[2]string]map[string]bool{},
+	}
+}
+
+// Add parses the SQL of a record and counts its statements. Records that
+// aren't SQL are only counted as skipped.
+func (a *analyzer) Add(r record) {
+	statements := parseSQL(r.Command)
+	isSQL := false
+	for _, s := range statements {
+		isSQL = isSQL || s.Verb != ""
+	}
+	if !isSQL {
+		a.report.Skipped++
+		return
+	}
+	a.report.Queries++
+
+	user := a.users[r.AccountEmail]
+	if user == nil {
+		user = &userSummary{Account: r.AccountEmail, Kinds: map[string]int{}}
+		a.users[r.AccountEmail] = user
+		a.userTables[r.AccountEmail] = map[string]bool{}
+	}
+	for _, s := range statements {
+		a.report.Statements++
+		user.Statements++
+		user.Kinds[s.Kind]++
+		for _, name := range s.Tables {
+			key := [2]string{r.ResourceName, name}
+			a.userTables[r.AccountEmail][r.ResourceName+"/"+name] = true
+			table := a.tables[key]
+			if table == nil {
+				table = &tableSummary{Resource: r.ResourceName, Table: name, Kinds: map[string]int{}}
+				a.tables[key] = table
+				a.tableAccount[key] = map[string]bool{}
+			}
+			table.Statements++
+			table.Kinds[s.Kind]++
+			a.tableAccount[key][r.AccountEmail] = true
+			if r.Timestamp.After(table.LastUsed) {
+				table.LastUsed = r.Timestamp
+			}
+		}
+		if s.Destructive != "" {
+			user.Destructive++
+			a.report.Destructive = append(a.report.Destructive, destructiveStatement{
+				Timestamp: r.Timestamp,
+				QueryID:   r.ID,
+				Account:   r.AccountEmail,
+				Resource:  r.ResourceName,
+				Reason:    s.Destructive,
+				Statement: a.redactor.Session().Text(s.Text),
+			})
+		}
+	}
+}
+
+// Report returns the summaries, busiest users and tables first, and the
+// destructive statements in the order they were run.
+func (a *analyzer) Report() report {
+	r := a.report
+	r.Users = []userSummary{}
+	for account, u := range a.users {
+		u.Tables = len(a.userTables[account])
+		r.Users = append(r.Users, *u)
+	}
+	sort.Slice(r.Users, func(i, j int) bool {
+		if r.Users[i].Statements != r.Users[j].Statements {
+			return r.Users[i].Statements > r.Users[j].Statements
+		}
+		return r.Users[i].Account < r.Users[j].Account
+	})
+
+	r.Tables = []tableSummary{}
+	for key, t := range a.tables {
+		t.Accounts = nil
+		for account := range a.tableAccount[key] {
+			t.Accounts = append(t.Accounts, account)
+		}
+		sort.Strings(t.Accounts)
+		r.Tables = append(r.Tables, *t)
+	}
+	sort.Slice(r.Tables, func(i, j int) bool {
+		if r.Tables[i].Statements != r.Tables[j].Statements {
+			return r.Tables[i].Statements > r.Tables[j].Statements
+		}
+		if r.Tables[i].Resource != r.Tables[j].Resource {
+			return r.Tables[i].Resource < r.Tables[j].Resource
+		}
+		return r.Tables[i].Table < r.Tables[j].Table
+	})
+
+	if r.Destructive == nil {
+		r.Destructive = []destructiveStatement{}
+	}
+	sort.SliceStable(r.Destructive, func(i, j int) bool {
+		return r.Destructive[i].Timestamp.Before(r.Destructive[j].Timestamp)
+	})
+	return r
+}
@@ -0,0 +1,526 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Statement kinds.
+const (
+	kindSelect = "SELECT"
+	kindDML    = "DML"
+	kindDDL    = "DDL"
+	kindOther  = "OTHER"
+)
+
+// verbKinds classifies statements by their first keyword. Access control
+// statements such as GRANT count as DDL, since they change the schema's
+// permissions; transaction control, session settings and maintenance are
+// OTHER. Text that starts with none of these isn't SQL.
+var verbKinds = map[string]string{
+	"SELECT": kindSelect, "SHOW": kindSelect, "VALUES": kindSelect, "TABLE": kindSelect, "DESCRIBE": kindSelect, "DESC": kindSelect,
+	"INSERT": kindDML, "UPDATE": kindDML, "DELETE": kindDML, "MERGE": kindDML, "UPSERT": kindDML, "REPLACE": kindDML, "COPY": kindDML,
+	"CREATE": kindDDL, "ALTER": kindDDL, "DROP": kindDDL, "TRUNCATE": kindDDL, "RENAME": kindDDL, "COMMENT": kindDDL,
+	"GRANT": kindDDL, "REVOKE": kindDDL,
+	"BEGIN": kindOther, "START": kindOther, "COMMIT": kindOther, "ROLLBACK": kindOther, "SAVEPOINT": kindOther,
+	"RELEASE": kindOther, "SET": kindOther, "RESET": kindOther, "USE": kindOther, "CALL": kindOther, "EXEC": kindOther,
+	"EXECUTE": kindOther, "PREPARE": kindOther, "DEALLOCATE": kindOther, "LOCK": kindOther, "VACUUM": kindOther,
+	"ANALYZE": kindOther, "DO": kindOther, "LISTEN": kindOther, "NOTIFY": kindOther, "DISCARD": kindOther,
+	"REFRESH": kindOther, "REINDEX": kindOther, "CLUSTER": kindOther, "CHECKPOINT": kindOther, "KILL": kindOther,
+}
+
+// statement is one parsed SQL statement.
+type statement struct {
+	Text string
+	Kind string
+	// Verb is the statement's main keyword, such as SELECT or DROP, after
+	// any WITH clause or EXPLAIN. A SELECT with a data-modifying WITH
+	// clause takes the verb of that clause. It is empty if the text isn't
+	// SQL.
+	Verb   string
+	Tables []string
+	// Destructive says why a statement destroys data, such as "DELETE
+	// without WHERE", or is empty.
+	Destructive string
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokQuoted
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// upper is the upper case text of a word, to compare with keywords.
+	upper string
+	pos   int
+}
+
+func (t token) is(keyword string) bool {
+	return t.kind == tokWord && t.upper == keyword
+}
+
+func (t token) isPunct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+// parseSQL splits a query body into statements and parses each. Bodies that
+// aren't SQL, such as shell commands, come back as OTHER with no verb.
+func parseSQL(text string) []statement {
+	var statements []statement
+	tokens := tokenize(text)
+	for len(tokens) > 0 {
+		n := 0
+		for n < len(tokens) && !tokens[n].isPunct(";") {
+			n++
+		}
+		if n > 0 {
+			end := len(text)
+			if n < len(tokens) {
+				end = tokens[n].pos
+			}
+			s := statement{Text: strings.TrimSpace(text[tokens[0].pos:end])}
+			parseStatement(&s, tokens[:n])
+			statements = append(statements, s)
+		}
+		tokens = tokens[min(n+1, len(tokens)):]
+	}
+	return statements
+}
+
+// tokenize splits SQL into tokens, dropping comments and whitespace. It
+// understands standard and E'...' strings, "double", `backtick` and [bracket]
+// quoted identifiers and Postgres $tag$ quoting. # is not a comment, since
+// Postgres uses it in operators such as #>.
+func tokenize(text string) []token {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			i++
+		case c == '-' && strings.HasPrefix(text[i:], "--"):
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'':
+			end := quoteEnd(text, i, '\'', false)
+			tokens = append(tokens, token{kind: tokString, text: text[i:end], pos: i})
+			i = end
+		case c == '"' || c == '`':
+			end := quoteEnd(text, i, c, false)
+			tokens = append(tokens, token{kind: tokQuoted, text: unquote(text[i:end]), pos: i})
+			i = end
+		case c == '[':
+			end := strings.IndexByte(text[i:], ']')
+			if end < 0 {
+				end = len(text) - i
+			}
+			tokens = append(tokens, token{kind: tokQuoted, text: text[i+1 : i+end], pos: i})
+			i += min(end+1, len(text)-i)
+		case c == '$' && dollarTag(text[i:]) != "":
+			tag := dollarTag(text[i:])
+			end := len(text)
+			if n := strings.Index(text[i+len(tag):], tag); n >= 0 {
+				end = i + len(tag) + n + len(tag)
+			}
+			tokens = append(tokens, token{kind: tokString, text: text[i:end], pos: i})
+			i = end
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(text) && (text[j] >= '0' && text[j] <= '9' || text[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text[i:j], pos: i})
+			i = j
+		case isWordStart(text[i:]):
+			j := i
+			for j < len(text) {
+				r, n := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '$' {
+					break
+				}
+				j += n
+			}
+			word := text[i:j]
+			// E'...' and N'...' are strings with a prefix.
+			if j < len(text) && text[j] == '\'' && len(word) == 1 && strings.ContainsAny(word, "EeNnBbXx") {
+				end := quoteEnd(text, j, '\'', word == "E" || word == "e")
+				tokens = append(tokens, token{kind: tokString, text: text[i:end], pos: i})
+				i = end
+				continue
+			}
+			tokens = append(tokens, token{kind: tokWord, text: word, upper: strings.ToUpper(word), pos: i})
+			i = j
+		default:
+			_, n := utf8.DecodeRuneInString(text[i:])
+			tokens = append(tokens, token{kind: tokPunct, text: text[i : i+n], pos: i})
+			i += n
+		}
+	}
+	return tokens
+}
+
+// quoteEnd returns the offset just past the quote that closes the one at
+// start, treating a doubled quote as an escaped one. Backslash escapes are
+// only honoured if backslash is set, as for E'...' strings; in standard
+// strings a backslash is an ordinary character, as in 'C:\'.
+func quoteEnd(text string, start int, quote byte, backslash bool) int {
+	for i := start + 1; i < len(text); i++ {
+		if text[i] == '\\' && backslash {
+			i++
+			continue
+		}
+		if text[i] == quote {
+			if i+1 < len(text) && text[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(text)
+}
+
+func unquote(s string) string {
+	q := s[:1]
+	s = strings.TrimPrefix(strings.TrimSuffix(s[1:], q), q)
+	return strings.ReplaceAll(s, q+q, q)
+}
+
+// dollarTag returns the $tag$ that starts s, or "" if it doesn't start one.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// keywords are words that can't be a table name or alias where one is
+// expected.
+var keywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`ALL AND AS BY CROSS DEFAULT DISTINCT EXCEPT EXISTS FETCH FOR FROM FULL
+		GROUP HAVING IF IN INNER INTERSECT INTO IS JOIN LATERAL LEFT LIMIT NATURAL NOT NULL OFFSET ON ONLY
+		OR ORDER OUTER RETURNING RIGHT SELECT SET STRAIGHT_JOIN TABLE UNION USING VALUES WHERE WINDOW WITH`) {
+		keywords[k] = true
+	}
+}
+
+// tableClauses are the keywords after which a statement names tables.
+var tableClauses = map[string]bool{
+	"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true, "TRUNCATE": true,
+	"VIEW": true, "STRAIGHT_JOIN": true,
+}
+
+func parseStatement(s *statement, tokens []token) {
+	s.Kind, s.Verb = kindOther, ""
+	i := 0
+	for i < len(tokens) && tokens[i].isPunct("(") {
+		i++
+	}
+	// EXPLAIN ANALYZE runs the statement, so it is classified as what it
+	// explains.
+	if i < len(tokens) && tokens[i].is("EXPLAIN") {
+		i++
+		if i < len(tokens) && tokens[i].isPunct("(") {
+			i = skipParens(tokens, i)
+		}
+		for i < len(tokens) && (tokens[i].is("ANALYZE") || tokens[i].is("VERBOSE")) {
+			i++
+		}
+	}
+	ctes := map[string]bool{}
+	var cteBodies [][]token
+	if i < len(tokens) && tokens[i].is("WITH") {
+		i, cteBodies = skipWith(tokens, i+1, ctes)
+	}
+	if i >= len(tokens) || tokens[i].kind != tokWord {
+		return
+	}
+	verb := tokens[i].upper
+	kind, ok := verbKinds[verb]
+	if !ok {
+		return
+	}
+	s.Kind, s.Verb = kind, verb
+	if verb == "COPY" && i+1 < len(tokens) && !tokens[i+1].isPunct("(") {
+		// COPY table FROM file: only the first name is a table.
+		if name, _ := qualifiedName(tokens, i+1); name != "" {
+			s.Tables = []string{name}
+		}
+	} else {
+		createIndex := verb == "CREATE" && hasTopLevel(tokens[i:min(len(tokens), i+4)], "INDEX")
+		s.Tables = tablesOf(tokens, ctes, createIndex || verb == "GRANT" || verb == "REVOKE")
+	}
+
+	switch verb {
+	case "DROP":
+		if i+1 < len(tokens) && tokens[i+1].kind == tokWord {
+			s.Destructive = "DROP " + tokens[i+1].upper
+		} else {
+			s.Destructive = "DROP"
+		}
+	case "TRUNCATE":
+		s.Destructive = "TRUNCATE"
+	case "DELETE":
+		if !hasTopLevel(tokens[i:], "WHERE") {
+			s.Destructive = "DELETE without WHERE"
+		}
+	case "ALTER":
+		for j := i; j+1 < len(tokens); j++ {
+			if tokens[j].is("DROP") && (tokens[j+1].is("COLUMN") || tokens[j+1].is("PARTITION")) {
+				s.Destructive = "ALTER ... DROP " + tokens[j+1].upper
+				break
+			}
+		}
+	}
+
+	// A data-modifying WITH clause, as in WITH gone AS (DELETE FROM logs
+	// RETURNING *) SELECT ..., runs whatever the main statement is.
+	for _, body := range cteBodies {
+		var cte statement
+		parseStatement(&cte, body)
+		if cte.Kind != kindDML {
+			continue
+		}
+		if s.Kind == kindSelect {
+			s.Kind, s.Verb = kindDML, cte.Verb
+		}
+		if s.Destructive == "" {
+			s.Destructive = cte.Destructive
+		}
+	}
+}
+
+// skipWith skips the common table expressions after WITH, recording their
+// names, and returns the index of the main statement's first token and the
+// body of each expression.
+func skipWith(tokens []token, i int, ctes map[string]bool) (int, [][]token) {
+	var bodies [][]token
+	if i < len(tokens) && tokens[i].is("RECURSIVE") {
+		i++
+	}
+	for i < len(tokens) {
+		name, next := qualifiedName(tokens, i)
+		if name == "" {
+			return i, bodies
+		}
+		ctes[name] = true
+		i = next
+		if i < len(tokens) && tokens[i].isPunct("(") {
+			i = skipParens(tokens, i) // column names
+		}
+		for i < len(tokens) && (tokens[i].is("AS") || tokens[i].is("NOT") || tokens[i].is("MATERIALIZED")) {
+			i++
+		}
+		end := skipParens(tokens, i)
+		if i < len(tokens) && tokens[i].isPunct("(") {
+			body := tokens[i+1 : end]
+			if len(body) > 0 && body[len(body)-1].isPunct(")") {
+				body = body[:len(body)-1]
+			}
+			bodies = append(bodies, body)
+		}
+		i = end
+		if i >= len(tokens) || !tokens[i].isPunct(",") {
+			return i, bodies
+		}
+		i++
+	}
+	return i, bodies
+}
+
+// skipParens returns the index just past the parenthesis that closes the one
+// at i.
+func skipParens(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch {
+		case tokens[i].isPunct("("):
+			depth++
+		case tokens[i].isPunct(")"):
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func hasTopLevel(tokens []token, keyword string) bool {
+	depth := 0
+	for _, t := range tokens {
+		switch {
+		case t.isPunct("("):
+			depth++
+		case t.isPunct(")"):
+			depth--
+		case depth == 0 && t.is(keyword):
+			return true
+		}
+	}
+	return false
+}
+
+// tablesOf returns the tables a statement names, lower case and without
+// duplicates, leaving out common table expressions. Tables are found after
+// FROM, JOIN, INTO, UPDATE and the like, and after ON if onTable is set, as
+// it is for CREATE INDEX and GRANT. FROM inside a function call, as in
+// EXTRACT(YEAR FROM created), is ignored unless the call holds a subquery.
+func tablesOf(tokens []token, ctes map[string]bool, onTable bool) []string {
+	var tables []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name != "" && !ctes[name] && !seen[name] {
+			seen[name] = true
+			tables = append(tables, name)
+		}
+	}
+
+	// queryLevel records, for each open parenthesis, whether a FROM inside
+	// it introduces tables.
+	queryLevel := []bool{true}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.isPunct("("):
+			isCall := i > 0 && tokens[i-1].kind == tokWord && !keywords[tokens[i-1].upper] && !tableClauses[tokens[i-1].upper]
+			queryLevel = append(queryLevel, !isCall)
+			continue
+		case t.isPunct(")"):
+			if len(queryLevel) > 1 {
+				queryLevel = queryLevel[:len(queryLevel)-1]
+			}
+			continue
+		case t.is("SELECT"):
+			queryLevel[len(queryLevel)-1] = true
+			continue
+		case t.kind != tokWord:
+			continue
+		}
+		clause := tableClauses[t.upper] || t.upper == "ON" && onTable
+		if !clause || (t.upper == "FROM" || t.upper == "JOIN") && !queryLevel[len(queryLevel)-1] {
+			continue
+		}
+		// UPDATE names a table only as a statement, not in FOR UPDATE,
+		// FOR NO KEY UPDATE, ON CONFLICT DO UPDATE or ON DUPLICATE KEY
+		// UPDATE.
+		if t.upper == "UPDATE" && i > 0 && (tokens[i-1].is("FOR") || tokens[i-1].is("DO") || tokens[i-1].is("KEY")) {
+			continue
+		}
+		// TABLE and VIEW name tables only after a verb, not in phrases
+		// such as RETURNS TABLE.
+		if (t.upper == "TABLE" || t.upper == "VIEW") && i > 0 && tokens[i-1].kind == tokWord &&
+			!isDDLVerb(tokens[i-1].upper) && tokens[i-1].upper != "MATERIALIZED" && tokens[i-1].upper != "TEMPORARY" &&
+			tokens[i-1].upper != "TEMP" && tokens[i-1].upper != "UNLOGGED" {
+			continue
+		}
+		list := t.upper == "FROM" || t.upper == "TRUNCATE" || t.upper == "TABLE"
+		j := i + 1
+		for {
+			for j < len(tokens) && (tokens[j].is("ONLY") || tokens[j].is("IF") || tokens[j].is("NOT") ||
+				tokens[j].is("EXISTS") || tokens[j].is("TABLE") || tokens[j].is("LATERAL")) {
+				j++
+			}
+			name, next := qualifiedName(tokens, j)
+			if name == "" {
+				break
+			}
+			// A name followed by ( after FROM or JOIN is a function.
+			if next < len(tokens) && tokens[next].isPunct("(") && (t.upper == "FROM" || t.upper == "JOIN") {
+				j = skipParens(tokens, next)
+			} else {
+				add(name)
+				j = next
+			}
+			if !list {
+				break
+			}
+			// Skip an alias, then continue a comma separated list.
+			if j < len(tokens) && tokens[j].is("AS") {
+				j++
+			}
+			if j < len(tokens) && (tokens[j].kind == tokQuoted || tokens[j].kind == tokWord && !keywords[tokens[j].upper]) {
+				j++
+			}
+			if j >= len(tokens) || !tokens[j].isPunct(",") {
+				break
+			}
+			j++
+		}
+		i = j - 1
+	}
+	return tables
+}
+
+func isDDLVerb(word string) bool {
+	switch word {
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "LOCK", "REPLACE":
+		return true
+	}
+	return false
+}
+
+// qualifiedName reads a possibly schema qualified name, such as
+// public."Users", starting at i. It returns the name in lower case and the
+// index after it, or "" if there is no name at i.
+func qualifiedName(tokens []token, i int) (string, int) {
+	var parts []string
+	for i < len(tokens) {
+		t := tokens[i]
+		if t.kind == tokQuoted {
+			parts = append(parts, t.text)
+		} else if t.kind == tokWord && (len(parts) > 0 || !keywords[t.upper]) {
+			parts = append(parts, strings.ToLower(t.text))
+		} else {
+			break
+		}
+		i++
+		if i+1 < len(tokens) && tokens[i].isPunct(".") {
+			i++
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "."), i
+}
@@ -0,0 +1,142 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []statement
+	}{
+		{
+			"select with join",
+			"SELECT u.email FROM users u JOIN orders AS o ON o.user_id = u.id",
+			[]statement{{Text: "SELECT u.email FROM users u JOIN orders AS o ON o.user_id = u.id", Kind: kindSelect, Verb: "SELECT", Tables: []string{"users", "orders"}}},
+		},
+		{
+			"comma separated tables and quoted names",
+			`select * from public."Users", audit.log l`,
+			[]statement{{Text: `select * from public."Users", audit.log l`, Kind: kindSelect, Verb: "SELECT", Tables: []string{"public.Users", "audit.log"}}},
+		},
+		{
+			"function call is not a table",
+			"SELECT EXTRACT(YEAR FROM created) FROM generate_series(1, 3), events",
+			[]statement{{Text: "SELECT EXTRACT(YEAR FROM created) FROM generate_series(1, 3), events", Kind: kindSelect, Verb: "SELECT", Tables: []string{"events"}}},
+		},
+		{
+			"with clause",
+			"WITH recent AS (SELECT * FROM orders) DELETE FROM archive WHERE id IN (SELECT id FROM recent)",
+			[]statement{{Text: "WITH recent AS (SELECT * FROM orders) DELETE FROM archive WHERE id IN (SELECT id FROM recent)", Kind: kindDML, Verb: "DELETE", Tables: []string{"orders", "archive"}}},
+		},
+		{
+			"several statements",
+			"BEGIN; UPDATE accounts SET balance = 0; COMMIT;",
+			[]statement{
+				{Text: "BEGIN", Kind: kindOther, Verb: "BEGIN"},
+				{Text: "UPDATE accounts SET balance = 0", Kind: kindDML, Verb: "UPDATE", Tables: []string{"accounts"}},
+				{Text: "COMMIT", Kind: kindOther, Verb: "COMMIT"},
+			},
+		},
+		{
+			"semicolons in strings and comments",
+			"INSERT INTO notes VALUES ('a;b') -- trailing; comment\n/* ; */",
+			[]statement{{Text: "INSERT INTO notes VALUES ('a;b') -- trailing; comment\n/* ; */", Kind: kindDML, Verb: "INSERT", Tables: []string{"notes"}}},
+		},
+		{
+			"delete without where",
+			"DELETE FROM sessions",
+			[]statement{{Text: "DELETE FROM sessions", Kind: kindDML, Verb: "DELETE", Tables: []string{"sessions"}, Destructive: "DELETE without WHERE"}},
+		},
+		{
+			"drop table",
+			"DROP TABLE IF EXISTS tmp_import",
+			[]statement{{Text: "DROP TABLE IF EXISTS tmp_import", Kind: kindDDL, Verb: "DROP", Tables: []string{"tmp_import"}, Destructive: "DROP TABLE"}},
+		},
+		{
+			"truncate",
+			"TRUNCATE logs, metrics",
+			[]statement{{Text: "TRUNCATE logs, metrics", Kind: kindDDL, Verb: "TRUNCATE", Tables: []string{"logs", "metrics"}, Destructive: "TRUNCATE"}},
+		},
+		{
+			"alter drop column",
+			"ALTER TABLE users DROP COLUMN ssn",
+			[]statement{{Text: "ALTER TABLE users DROP COLUMN ssn", Kind: kindDDL, Verb: "ALTER", Tables: []string{"users"}, Destructive: "ALTER ... DROP COLUMN"}},
+		},
+		{
+			"grant",
+			"GRANT SELECT ON payments TO analyst",
+			[]statement{{Text: "GRANT SELECT ON payments TO analyst", Kind: kindDDL, Verb: "GRANT", Tables: []string{"payments"}}},
+		},
+		{
+			"explain analyze",
+			"EXPLAIN ANALYZE UPDATE users SET name = 'x' WHERE id = 1",
+			[]statement{{Text: "EXPLAIN ANALYZE UPDATE users SET name = 'x' WHERE id = 1", Kind: kindDML, Verb: "UPDATE", Tables: []string{"users"}}},
+		},
+		{
+			"select for update",
+			"SELECT * FROM jobs FOR UPDATE SKIP LOCKED",
+			[]statement{{Text: "SELECT * FROM jobs FOR UPDATE SKIP LOCKED", Kind: kindSelect, Verb: "SELECT", Tables: []string{"jobs"}}},
+		},
+		{
+			"upsert",
+			"INSERT INTO counters (id, n) VALUES (1, 1) ON CONFLICT (id) DO UPDATE SET n = counters.n + 1",
+			[]statement{{Text: "INSERT INTO counters (id, n) VALUES (1, 1) ON CONFLICT (id) DO UPDATE SET n = counters.n + 1", Kind: kindDML, Verb: "INSERT", Tables: []string{"counters"}}},
+		},
+		{
+			"backslash in a standard string",
+			`SELECT 'C:\' AS p FROM files; DROP TABLE users`,
+			[]statement{
+				{Text: `SELECT 'C:\' AS p FROM files`, Kind: kindSelect, Verb: "SELECT", Tables: []string{"files"}},
+				{Text: "DROP TABLE users", Kind: kindDDL, Verb: "DROP", Tables: []string{"users"}, Destructive: "DROP TABLE"},
+			},
+		},
+		{
+			"backslash escape in an E string",
+			`SELECT E'it\'s; fine' FROM notes`,
+			[]statement{{Text: `SELECT E'it\'s; fine' FROM notes`, Kind: kindSelect, Verb: "SELECT", Tables: []string{"notes"}}},
+		},
+		{
+			"json path operator",
+			"SELECT data #> '{a}', data #>> '{b}' FROM events",
+			[]statement{{Text: "SELECT data #> '{a}', data #>> '{b}' FROM events", Kind: kindSelect, Verb: "SELECT", Tables: []string{"events"}}},
+		},
+		{
+			"data-modifying with clause",
+			"WITH gone AS (DELETE FROM logs RETURNING *) SELECT count(*) FROM gone",
+			[]statement{{Text: "WITH gone AS (DELETE FROM logs RETURNING *) SELECT count(*) FROM gone", Kind: kindDML, Verb: "DELETE", Tables: []string{"logs"}, Destructive: "DELETE without WHERE"}},
+		},
+		{
+			"not sql",
+			"ls -la /var/log",
+			[]statement{{Text: "ls -la /var/log", Kind: kindOther}},
+		},
+		{
+			"empty",
+			"  ;  ",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSQL(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSQL(%q) =\n%+v\nwant\n%+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
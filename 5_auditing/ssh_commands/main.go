@@ -0,0 +1,178 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/shellcmd"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Turns SSH session replays into transcripts of the commands that were run,
+// as the user saw them after editing, with the time each was run and the
+// output that followed. Secrets are masked in the replay before it is read:
+//
+//	ssh_commands -resource Example
+//	ssh_commands -query <query id> -json
+func main() {
+	log.SetFlags(0)
+	queryID := flag.String("query", "", "ID of a single query to reconstruct")
+	resourceName := flag.String("resource", "Example", "when no query is given, reconstruct every session on this resource")
+	prompt := flag.String("prompt", `[$#%>] ?$`, "regular expression matching the end of a shell prompt")
+	asJSON := flag.Bool("json", false, "write one JSON object per command instead of a transcript")
+	outputLines := flag.Int("output-lines", 10, "lines of output to show per command in transcripts, -1 for all")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+	promptRE, err := regexp.Compile(*prompt)
+	if err != nil {
+		log.Fatalf("invalid -prompt: %v", err)
+	}
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var queries sdm.QueryIterator
+	if *queryID != "" {
+		queries, err = client.Queries().List(ctx, "id:?", *queryID)
+	} else {
+		resources, err := client.Resources().List(ctx, "name:?", *resourceName)
+		if err != nil {
+			log.Fatalf("failed to list resources: %v", err)
+		}
+		if !resources.Next() {
+			log.Fatalf("couldn't find resource named %v (error: %v)", *resourceName, resources.Err())
+		}
+		queries, err = client.Queries().List(ctx, "resource_id:?", resources.Value().GetID())
+	}
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	for queries.Next() {
+		q := queries.Value()
+		if q.Encrypted {
+			fmt.Fprintf(os.Stderr, "Skipping encrypted query %v, see encrypted_query_replay for an example of query decryption.\n", q.ID)
+			continue
+		}
+		if !q.Replayable {
+			continue
+		}
+		redactions := redactor.Session()
+		commands, err := reconstruct(ctx, client, q, promptRE, redactions.Stream())
+		if err != nil {
+			log.Fatalf("failed to reconstruct query %v: %v", q.ID, err)
+		}
+		if redactions.Total() > 0 {
+			log.Printf("Session %v: %v", q.ID, redactions)
+		}
+
+		if *asJSON {
+			for _, c := range commands {
+				err := enc.Encode(struct {
+					QueryID      string    `json:"queryId"`
+					AccountEmail string    `json:"accountEmail"`
+					ResourceName string    `json:"resourceName"`
+					Time         time.Time `json:"time"`
+					OffsetMs     int64     `json:"offsetMs"`
+					*shellcmd.Command
+				}{q.ID, q.AccountEmail, q.ResourceName, q.Timestamp.Add(c.Offset), c.Offset.Milliseconds(), c})
+				if err != nil {
+					log.Fatalf("failed to write command: %v", err)
+				}
+			}
+			continue
+		}
+		fmt.Printf("Session %v by %v on %v at %v\n", q.ID, q.AccountEmail, q.ResourceName, q.Timestamp.UTC().Format(time.RFC3339))
+		for _, c := range commands {
+			printCommand(q, c, *outputLines)
+		}
+		fmt.Println()
+	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+}
+
+// reconstruct fetches the replay of a query, masks secrets in it with stream
+// and returns the commands run in it.
+func reconstruct(ctx context.Context, client *sdm.Client, q *sdm.Query, promptRE *regexp.Regexp, stream *redact.Stream) ([]*shellcmd.Command, error) {
+	cols, rows := 80, 24
+	if q.Capture != nil && q.Capture.Width > 0 && q.Capture.Height > 0 {
+		cols, rows = int(q.Capture.Width), int(q.Capture.Height)
+	}
+	r := shellcmd.New(cols, rows, promptRE)
+
+	k8s := kube.StreamFor(q)
+	replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan replay: %w", err)
+	}
+	for replayParts.Next() {
+		for _, ev := range replayParts.Value().Events {
+			r.Append(&sdm.ReplayChunkEvent{Data: stream.Write(k8s.Write(ev.Data)), Duration: ev.Duration})
+		}
+	}
+	if err := replayParts.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate replay: %w", err)
+	}
+	r.Append(&sdm.ReplayChunkEvent{Data: append(stream.Write(k8s.Flush()), stream.Flush()...)})
+	return r.Commands(), nil
+}
+
+func printCommand(q *sdm.Query, c *shellcmd.Command, outputLines int) {
+	fmt.Printf("[%v +%v] %v %v\n", q.Timestamp.Add(c.Offset).UTC().Format(time.RFC3339),
+		c.Offset.Round(time.Second), c.Prompt, c.Line)
+	output := c.Output
+	if outputLines >= 0 && len(output) > outputLines {
+		output = output[:outputLines]
+	}
+	for _, line := range output {
+		fmt.Printf("    %v\n", line)
+	}
+	if n := len(c.Output) - len(output); n > 0 {
+		fmt.Printf("    ... %v more lines\n", n)
+	}
+}
@@ -21,7 +21,10 @@ import (
 	"os"
 	"time"
 
-	sdm "github.com/strongdm/strongdm-sdk-go/v3"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
 )
 
 func main() {
@@ -47,7 +50,8 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// You'll need an SSH resource that has had queries made against it, provide its name:
+	// You'll need an SSH or Kubernetes resource that has had queries made
+	// against it, provide its name:
 	resourceName := "Example"
 	resourceResp, err := client.Resources().List(ctx, "name:?", resourceName)
 	if err != nil {
@@ -59,45 +63,58 @@ func main() {
 	resource := resourceResp.Value()
 
 	fmt.Printf("Queries made against %v:\n", resourceName)
+	// Accounts are looked up as they were when each query was made.
+	accounts := snapshotcache.New(client, 0)
+	// Keys, tokens and passwords that appear in sessions are masked before
+	// they are printed.
+	redactor := redact.New(redact.Builtins()...)
 	queries, err := client.Queries().List(ctx, "resource_id:?", resource.GetID())
 	if err != nil {
 		log.Fatalf("failed to list queries: %v", err)
 	}
 	for queries.Next() {
 		q := queries.Value()
-		accountResp, err := client.SnapshotAt(q.Timestamp).Accounts().Get(ctx, q.AccountID)
+		account, err := accounts.Account(ctx, q.AccountID, q.Timestamp)
 		if err != nil {
 			log.Fatalf("failed to get account: %v", err)
 		}
-		user := accountResp.Account.(*sdm.User)
+		email := snapshotcache.AccountName(account)
+		redactions := redactor.Session()
 
 		if q.Encrypted {
-			fmt.Printf("Skipping encrypted query made by %v at %v\n", user.Email, q.Timestamp)
+			fmt.Printf("Skipping encrypted query made by %v at %v\n", email, q.Timestamp)
 			fmt.Println("See encrypted_query_replay for an example of query decryption.")
 		} else if q.Replayable {
-			fmt.Printf("Replaying query made by %v at %v\n", user.Email, q.Timestamp)
+			fmt.Printf("Replaying query made by %v at %v\n", email, q.Timestamp)
 			replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
 			if err != nil {
 				log.Fatalf("failed to scan replay: %v", err)
 			}
+			stream := redactions.Stream()
+			k8s := kube.StreamFor(q)
 			for replayParts.Next() {
 				next := replayParts.Value()
 				for _, ev := range next.Events {
 					// Some characters may not be printed cleanly by this method
-					fmt.Print(string(ev.Data))
+					fmt.Print(string(stream.Write(k8s.Write(ev.Data))))
 					time.Sleep(ev.Duration)
 				}
 			}
 			if err := replayParts.Err(); err != nil {
 				log.Fatalf("failed to iterate replay: %v", err)
 			}
-			fmt.Println("")
+			fmt.Print(string(stream.Write(k8s.Flush())))
+			fmt.Println(string(stream.Flush()))
+			fmt.Printf("Replay of query %v: %v\n", q.ID, redactions)
+		} else if call, ok := kube.DescribeCall(q); ok {
+			// Other Kubernetes requests are API calls such as kubectl get.
+			fmt.Printf("Kubernetes call by %v at %v: %v\n", email, q.Timestamp, redactions.Text(call))
 		} else {
 			var capture struct{ Command string }
 			if err := json.Unmarshal([]byte(q.QueryBody), &capture); err != nil {
-				fmt.Printf("failed to unmarshal query JSON %v: %v", q.QueryBody, err)
+				fmt.Printf("failed to unmarshal query JSON %v: %v", redactions.Text(q.QueryBody), err)
 			} else {
-				fmt.Printf("Command run by %v at %v: %v\n", user.Email, q.Timestamp, capture.Command)
+				fmt.Printf("Command run by %v at %v: %v\n", email, q.Timestamp, redactions.Text(capture.Command))
 			}
 		}
 	}
@@ -0,0 +1,273 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/kube"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+func main() {
+	log.SetFlags(0)
+	resourceName := flag.String("resource", "Example", "name of the SSH resource whose sessions should be exported")
+	outDir := flag.String("out", ".", "directory to write the .cast files to")
+	redactRules := flag.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	flag.Parse()
+
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("failed to create output directory: %v", err)
+	}
+
+	// You'll need an SSH resource that has had queries made against it, provide its name:
+	resourceResp, err := client.Resources().List(ctx, "name:?", *resourceName)
+	if err != nil {
+		log.Fatalf("failed to list resources: %v", err)
+	}
+	if !resourceResp.Next() {
+		log.Fatalf("couldn't find resource named %v (error: %v)", *resourceName, resourceResp.Err())
+	}
+	resource := resourceResp.Value()
+
+	queries, err := client.Queries().List(ctx, "resource_id:?", resource.GetID())
+	if err != nil {
+		log.Fatalf("failed to list queries: %v", err)
+	}
+	for queries.Next() {
+		q := queries.Value()
+		if q.Encrypted {
+			fmt.Printf("Skipping encrypted query %v made by %v at %v\n", q.ID, q.AccountEmail, q.Timestamp)
+			fmt.Println("See encrypted_query_replay for an example of query decryption.")
+			continue
+		}
+		if !q.Replayable {
+			continue
+		}
+
+		path := filepath.Join(*outDir, q.ID+".cast")
+		redactions := redactor.Session()
+		if err := exportQuery(ctx, client, q, path, redactions); err != nil {
+			log.Fatalf("failed to export query %v: %v", q.ID, err)
+		}
+		fmt.Printf("Exported session by %v at %v to %v with %v\n", q.AccountEmail, q.Timestamp, path, redactions)
+	}
+	if err := queries.Err(); err != nil {
+		log.Fatalf("failed to iterate queries: %v", err)
+	}
+}
+
+// exportQuery writes the replay of a single query to path as an asciicast v2
+// file (https://docs.asciinema.org/manual/asciicast/v2/), with secrets masked
+// and counted in redactions.
+func exportQuery(ctx context.Context, client *sdm.Client, q *sdm.Query, path string, redactions *redact.Session) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := castHeaderFor(q)
+	header.Command = redactions.Text(header.Command)
+	cast, err := newCastWriter(f, header)
+	if err != nil {
+		return err
+	}
+	stream := redactions.Stream()
+
+	k8s := kube.StreamFor(q)
+	replayParts, err := client.Replays().List(ctx, "id:?", q.ID)
+	if err != nil {
+		return fmt.Errorf("failed to scan replay: %w", err)
+	}
+	for replayParts.Next() {
+		for _, ev := range replayParts.Value().Events {
+			redacted := &sdm.ReplayChunkEvent{Data: stream.Write(k8s.Write(ev.Data)), Duration: ev.Duration}
+			if err := cast.WriteEvent(redacted); err != nil {
+				return err
+			}
+		}
+	}
+	if err := replayParts.Err(); err != nil {
+		return fmt.Errorf("failed to iterate replay: %w", err)
+	}
+	if err := cast.WriteEvent(&sdm.ReplayChunkEvent{Data: append(stream.Write(k8s.Flush()), stream.Flush()...)}); err != nil {
+		return err
+	}
+
+	if err := cast.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Duration  float64           `json:"duration,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Terminal size used when the query capture does not record one.
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// castHeaderFor builds the asciicast header for a query, using the terminal
+// size from its capture and the account email and resource name it was
+// recorded against.
+func castHeaderFor(q *sdm.Query) castHeader {
+	header := castHeader{
+		Version:   2,
+		Width:     defaultWidth,
+		Height:    defaultHeight,
+		Timestamp: q.Timestamp.Unix(),
+		Duration:  q.Duration.Seconds(),
+		Title:     fmt.Sprintf("%v on %v", q.AccountEmail, q.ResourceName),
+		Env: map[string]string{
+			"SDM_QUERY_ID":      q.ID,
+			"SDM_ACCOUNT_EMAIL": q.AccountEmail,
+			"SDM_RESOURCE_NAME": q.ResourceName,
+		},
+	}
+	if q.Capture != nil {
+		if q.Capture.Width > 0 && q.Capture.Height > 0 {
+			header.Width = int(q.Capture.Width)
+			header.Height = int(q.Capture.Height)
+		}
+		header.Command = q.Capture.Command
+		if term := q.Capture.Env["TERM"]; term != "" {
+			header.Env["TERM"] = term
+		}
+	}
+	return header
+}
+
+// castWriter converts replay chunk events into asciicast output events.
+//
+// Replay events carry the delay that follows them, while asciicast events
+// carry the time since the start of the recording, so the writer keeps a
+// running total. Chunk boundaries may split a multi-byte UTF-8 sequence; the
+// incomplete tail is held back and prepended to the next event so that the
+// JSON strings stay valid.
+type castWriter struct {
+	w       *bufio.Writer
+	elapsed time.Duration
+	pending []byte
+}
+
+func newCastWriter(w io.Writer, header castHeader) (*castWriter, error) {
+	cw := &castWriter{w: bufio.NewWriter(w)}
+	if err := cw.writeLine(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// WriteEvent appends a single replay event to the recording.
+func (cw *castWriter) WriteEvent(ev *sdm.ReplayChunkEvent) error {
+	data := append(cw.pending, ev.Data...)
+	cut := incompleteSuffix(data)
+	cw.pending = append([]byte(nil), data[len(data)-cut:]...)
+	data = data[:len(data)-cut]
+
+	if len(data) > 0 {
+		if err := cw.writeLine([]interface{}{cw.elapsed.Seconds(), "o", string(data)}); err != nil {
+			return err
+		}
+	}
+	cw.elapsed += ev.Duration
+	return nil
+}
+
+// Close flushes any held back bytes and buffered output.
+func (cw *castWriter) Close() error {
+	if len(cw.pending) > 0 {
+		if err := cw.writeLine([]interface{}{cw.elapsed.Seconds(), "o", string(cw.pending)}); err != nil {
+			return err
+		}
+		cw.pending = nil
+	}
+	return cw.w.Flush()
+}
+
+func (cw *castWriter) writeLine(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := cw.w.Write(line); err != nil {
+		return err
+	}
+	return cw.w.WriteByte('\n')
+}
+
+// incompleteSuffix returns the length of a truncated UTF-8 sequence at the end
+// of b, or zero if b ends on a character boundary.
+func incompleteSuffix(b []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if c < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(b[len(b)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// orgReader is implemented by both the live client and a SnapshotClient, so
+// names can be looked up as they are now or as they were at any time.
+type orgReader interface {
+	Accounts() *sdm.Accounts
+	Resources() *sdm.Resources
+	Roles() *sdm.Roles
+	Groups() *sdm.Groups
+	Nodes() *sdm.Nodes
+	Policies() *sdm.Policies
+	Workflows() *sdm.Workflows
+	ApprovalWorkflows() *sdm.ApprovalWorkflows
+}
+
+type iterator[T any] interface {
+	Next() bool
+	Value() T
+	Err() error
+}
+
+// ids returns the ID of every entity from an iterator.
+func ids[T any](it iterator[T], err error, id func(T) string) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for it.Next() {
+		found = append(found, id(it.Value()))
+	}
+	return found, it.Err()
+}
+
+// kind is a type of entity that can be looked up at a point in time.
+type kind struct {
+	name   string
+	plural string
+	// prefix starts every ID of this kind. Groups have no distinctive
+	// prefix.
+	prefix string
+	// find returns the IDs of the entities with a name.
+	find func(ctx context.Context, r orgReader, name string) ([]string, error)
+	// get returns an entity as it was at a time from its history, or a
+	// *snapshotcache.NotExistError.
+	get func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error)
+}
+
+var kinds = []*kind{
+	{
+		name: "account", plural: "accounts", prefix: "a-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			// Users are named by email and service accounts by name.
+			filter := "name:?"
+			if strings.Contains(name, "@") {
+				filter = "email:?"
+			}
+			it, err := r.Accounts().List(ctx, filter, name)
+			return ids[sdm.Account](it, err, sdm.Account.GetID)
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Account(ctx, id, at)
+		},
+	},
+	{
+		name: "resource", plural: "resources", prefix: "rs-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.Resources().List(ctx, "name:?", name)
+			return ids[sdm.Resource](it, err, sdm.Resource.GetID)
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Resource(ctx, id, at)
+		},
+	},
+	{
+		name: "role", plural: "roles", prefix: "r-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.Roles().List(ctx, "name:?", name)
+			return ids(it, err, func(v *sdm.Role) string { return v.ID })
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Role(ctx, id, at)
+		},
+	},
+	{
+		name: "group", plural: "groups",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.Groups().List(ctx, "name:?", name)
+			return ids(it, err, func(v *sdm.Group) string { return v.ID })
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Group(ctx, id, at)
+		},
+	},
+	{
+		name: "node", plural: "nodes", prefix: "n-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.Nodes().List(ctx, "name:?", name)
+			return ids[sdm.Node](it, err, sdm.Node.GetID)
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Node(ctx, id, at)
+		},
+	},
+	{
+		name: "policy", plural: "policies", prefix: "po-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.Policies().List(ctx, "name:?", name)
+			return ids(it, err, func(v *sdm.Policy) string { return v.ID })
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Policy(ctx, id, at)
+		},
+	},
+	{
+		name: "workflow", plural: "workflows", prefix: "aw-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.Workflows().List(ctx, "name:?", name)
+			return ids(it, err, func(v *sdm.Workflow) string { return v.ID })
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.Workflow(ctx, id, at)
+		},
+	},
+	{
+		name: "approval-workflow", plural: "approval-workflows", prefix: "af-",
+		find: func(ctx context.Context, r orgReader, name string) ([]string, error) {
+			it, err := r.ApprovalWorkflows().List(ctx, "name:?", name)
+			return ids(it, err, func(v *sdm.ApprovalWorkflow) string { return v.ID })
+		},
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			return c.ApprovalWorkflow(ctx, id, at)
+		},
+	},
+}
+
+// kindNamed returns the kind with a singular or plural name.
+func kindNamed(name string) *kind {
+	for _, k := range kinds {
+		if name == k.name || name == k.plural {
+			return k
+		}
+	}
+	return nil
+}
+
+func kindNames() string {
+	var names []string
+	for _, k := range kinds {
+		names = append(names, k.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// resolve returns the ID of the entity an argument names. Arguments that
+// look like an ID of the kind are taken as one. Names are looked up in the
+// organization as it was at the time, and then as it is now, so an entity
+// created or renamed later can still be found by its current name.
+func resolve(ctx context.Context, client *sdm.Client, cache *snapshotcache.Cache, k *kind, nameOrID string, at time.Time) (string, error) {
+	if k.prefix != "" && strings.HasPrefix(nameOrID, k.prefix) {
+		return nameOrID, nil
+	}
+	if k.prefix == "" {
+		// Without a prefix, anything with a history is an ID.
+		_, err := k.get(ctx, cache, nameOrID, at)
+		var notExist *snapshotcache.NotExistError
+		if err == nil || errors.As(err, &notExist) && !notExist.CreatedAt.IsZero() {
+			return nameOrID, nil
+		}
+	}
+
+	for _, source := range []struct {
+		r    orgReader
+		when string
+	}{
+		{client.SnapshotAt(at), "at " + at.UTC().Format(time.RFC3339)},
+		{client, "now"},
+	} {
+		found, err := k.find(ctx, source.r, nameOrID)
+		if err != nil {
+			return "", fmt.Errorf("failed to look up %v %q %v: %w", k.name, nameOrID, source.when, err)
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		default:
+			return "", fmt.Errorf("%v %v are named %q %v; use one of their IDs", k.plural, strings.Join(found, ", "), nameOrID, source.when)
+		}
+	}
+	return "", fmt.Errorf("no %v was named %q at %v and none is now; look up one that was deleted or renamed since by its ID",
+		k.name, nameOrID, at.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/redact"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/timeflag"
+	sdm "github.com/strongdm/strongdm-sdk-go/v15"
+)
+
+// Prints any account, resource, role, group, node, policy, workflow or
+// approval workflow as it was at a point in time, the way audit_resource
+// shows a resource with SnapshotAt. Entities can be named by ID or by name,
+// which is looked up as it was at that time. Secrets are redacted, and an
+// entity that did not exist yet, or had already been deleted, is reported
+// as such:
+//
+//	time_machine get role Engineering --at 2025-01-01T00:00:00Z
+//	time_machine get account alice@example.com --at 720h -format yaml
+//	time_machine get resource rs-1234 --at 2025-06-01T12:00:00Z
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 || os.Args[1] != "get" {
+		log.Fatal("usage: time_machine get <kind> <id-or-name> [-at time] [-format json|yaml] [-redact-rules file]")
+	}
+	getCommand(os.Args[2:])
+}
+
+func getCommand(args []string) {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	atFlag := flags.String("at", "", "time to look the entity up at, RFC 3339 or a duration ago such as 24h (default now)")
+	format := flags.String("format", "json", "output format: json or yaml")
+	redactRules := flags.String("redact-rules", "", "JSON file of redaction rules to add to the built-in ones")
+	// Flags may come before or after the kind and name.
+	var positional []string
+	flags.Parse(args)
+	for flags.NArg() > 0 {
+		positional = append(positional, flags.Arg(0))
+		flags.Parse(flags.Args()[1:])
+	}
+	if len(positional) != 2 {
+		log.Fatal("usage: time_machine get <kind> <id-or-name> [-at time] [-format json|yaml] [-redact-rules file]")
+	}
+	k := kindNamed(positional[0])
+	if k == nil {
+		log.Fatalf("unknown kind %q, expected one of %v", positional[0], kindNames())
+	}
+	write := writeJSON
+	switch *format {
+	case "json":
+	case "yaml":
+		write = writeYAML
+	default:
+		log.Fatalf("unknown format %q, expected json or yaml", *format)
+	}
+	now := time.Now()
+	at, err := timeflag.Parse(*atFlag, now, now)
+	if err != nil {
+		log.Fatalf("invalid -at: %v", err)
+	}
+	redactor, err := redact.Load(*redactRules)
+	if err != nil {
+		log.Fatalf("failed to load redaction rules: %v", err)
+	}
+
+	//	Load the SDM API keys from the environment.
+	//	If these values are not set in your environment,
+	//	please follow the documentation here:
+	//	https://www.strongdm.com/docs/api/api-keys/
+	accessKey := os.Getenv("SDM_API_ACCESS_KEY")
+	secretKey := os.Getenv("SDM_API_SECRET_KEY")
+	if accessKey == "" || secretKey == "" {
+		log.Fatal("SDM_API_ACCESS_KEY and SDM_API_SECRET_KEY must be provided")
+	}
+
+	// Create the client
+	client, err := sdm.New(accessKey, secretKey)
+	if err != nil {
+		log.Fatal("failed to create strongDM client:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// The entity is read from its history, which also tells when it was
+	// created or deleted if it didn't exist at the time.
+	cache := snapshotcache.New(client, 0)
+	id, err := resolve(ctx, client, cache, k, positional[1], at)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entity, err := k.get(ctx, cache, id, at)
+	var notExist *snapshotcache.NotExistError
+	if errors.As(err, &notExist) {
+		log.Fatal(notExist)
+	} else if err != nil {
+		log.Fatalf("failed to get %v %v: %v", k.name, id, err)
+	}
+
+	redactions := redactor.Session()
+	generic, err := redact.Entity(entity, redactions.Text)
+	if err != nil {
+		log.Fatalf("failed to encode %v %v: %v", k.name, id, err)
+	}
+	log.Printf("%v %v as of %v", k.name, id, at.UTC().Format(time.RFC3339))
+	if redactions.Total() > 0 {
+		log.Printf("Redacted: %v", redactions)
+	}
+	if err := write(os.Stdout, generic); err != nil {
+		log.Fatalf("failed to write %v: %v", k.name, err)
+	}
+}
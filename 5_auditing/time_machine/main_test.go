@@ -0,0 +1,58 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/strongdm/strongdm-sdk-go-examples/5_auditing/snapshotcache"
+)
+
+func TestResolveID(t *testing.T) {
+	at := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	// Groups have no ID prefix, so an argument is an ID if it has a history,
+	// even one that ends before or starts after the time asked about.
+	group := &kind{
+		name: "group",
+		get: func(ctx context.Context, c *snapshotcache.Cache, id string, at time.Time) (interface{}, error) {
+			switch id {
+			case "g-live":
+				return struct{}{}, nil
+			case "g-deleted":
+				return nil, &snapshotcache.NotExistError{Kind: "group", ID: id, At: at, CreatedAt: at.Add(-time.Hour), DeletedAt: at.Add(-time.Minute)}
+			case "g-later":
+				return nil, &snapshotcache.NotExistError{Kind: "group", ID: id, At: at, CreatedAt: at.Add(time.Hour)}
+			}
+			return nil, &snapshotcache.NotExistError{Kind: "group", ID: id, At: at}
+		},
+	}
+	tests := []struct {
+		k        *kind
+		nameOrID string
+	}{
+		{kindNamed("role"), "r-123"},
+		{group, "g-live"},
+		{group, "g-deleted"},
+		{group, "g-later"},
+	}
+	for _, tt := range tests {
+		// None of these reach the API, so no client is needed.
+		id, err := resolve(context.Background(), nil, nil, tt.k, tt.nameOrID, at)
+		if err != nil || id != tt.nameOrID {
+			t.Errorf("resolve(%v %q) = %q, %v, want the ID itself", tt.k.name, tt.nameOrID, id, err)
+		}
+	}
+}
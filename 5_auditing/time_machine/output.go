@@ -0,0 +1,155 @@
+// Copyright 2025 StrongDM Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeYAML writes a value from redact.Entity as a YAML document. Only the
+// subset of YAML needed for JSON values is used: block mappings with sorted
+// keys, block sequences, and plain or double-quoted scalars.
+func writeYAML(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		yamlBlock(&buf, v, 0)
+	default:
+		buf.WriteString(yamlScalar(v) + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// yamlBlock writes a non-empty mapping or sequence, each entry on its own
+// line at the given indent.
+func yamlBlock(buf *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteString(pad + yamlString(k) + ":")
+			yamlValue(buf, v[k], indent+1)
+		}
+	case []interface{}:
+		for _, item := range v {
+			// A mapping in a sequence starts on the line of its dash.
+			if m, ok := item.(map[string]interface{}); ok && len(m) > 0 {
+				var nested bytes.Buffer
+				yamlBlock(&nested, m, indent+1)
+				buf.WriteString(pad + "- " + nested.String()[len(pad)+2:])
+				continue
+			}
+			buf.WriteString(pad + "-")
+			yamlValue(buf, item, indent+1)
+		}
+	}
+}
+
+// yamlValue writes the value of a mapping entry or sequence item after its
+// key or dash: nested collections start on the next line, everything else
+// follows on the same line.
+func yamlValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if len(c) > 0 {
+			buf.WriteString("\n")
+			yamlBlock(buf, c, indent)
+			return
+		}
+	case []interface{}:
+		if len(c) > 0 {
+			buf.WriteString("\n")
+			yamlBlock(buf, c, indent)
+			return
+		}
+	}
+	buf.WriteString(" " + yamlScalar(v) + "\n")
+}
+
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return yamlString(v)
+	case map[string]interface{}:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	}
+	return yamlString(fmt.Sprint(v))
+}
+
+// yamlString writes a string plain when YAML would read it back as the same
+// string, and double-quoted otherwise.
+func yamlString(s string) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "\n\r\t\"\\") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'%@`") {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n", ".inf", "+.inf", "-.inf", ".nan":
+		return strconv.Quote(s)
+	}
+	if yamlNumber(s) || yamlTimestamp.MatchString(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// yamlSexagesimal matches the base 60 numbers of YAML 1.1, such as 1:30.
+var yamlSexagesimal = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?$`)
+
+// yamlTimestamp matches the timestamps of YAML 1.1, such as 2025-01-01.
+var yamlTimestamp = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}` +
+	`(([Tt]|[ \t]+)[0-9]{1,2}:[0-9]{2}:[0-9]{2}(\.[0-9]*)?([ \t]*(Z|[-+][0-9]{1,2}(:[0-9]{2})?))?)?$`)
+
+// yamlNumber reports whether YAML 1.1 or 1.2 could read s as a number:
+// decimal, binary, octal or hexadecimal, with underscores, or in base 60.
+func yamlNumber(s string) bool {
+	digits := strings.ReplaceAll(s, "_", "")
+	if _, err := strconv.ParseFloat(digits, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		return true
+	}
+	if _, err := strconv.ParseInt(digits, 0, 64); err == nil || errors.Is(err, strconv.ErrRange) {
+		return true
+	}
+	return yamlSexagesimal.MatchString(s)
+}